@@ -0,0 +1,107 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const (
+	KindAccess = "access"
+
+	// how long an access control change keeps its alert firing
+	DefaultAccessWindow = 24 * time.Hour
+)
+
+// AccessSource provides the access control events of the oracles and the
+// configured updater allowlist that declares the expected ones.
+type AccessSource interface {
+	SelectAccessEvents(chainID string, oracleAddress string, since time.Time) ([]helpers.AccessEvent, error)
+	SelectUpdaterAllowlist() ([]helpers.AllowedUpdater, error)
+}
+
+// AccessChecker alerts on every change of the owner or the updaters of an
+// oracle, firing until the change is older than the window. Changes the
+// updater allowlist does not expect are critical, expected ones warnings.
+type AccessChecker struct {
+	source  AccessSource
+	manager *Manager
+	window  time.Duration
+}
+
+func NewAccessChecker(source AccessSource, manager *Manager, window time.Duration) *AccessChecker {
+	return &AccessChecker{source: source, manager: manager, window: window}
+}
+
+func (c *AccessChecker) Name() string {
+	return KindAccess
+}
+
+// Evaluate alerts on the access control events within the window.
+func (c *AccessChecker) Evaluate(now time.Time) error {
+	events, err := c.source.SelectAccessEvents("", "", now.Add(-c.window))
+	if err != nil {
+		return err
+	}
+	configured, err := c.source.SelectUpdaterAllowlist()
+	if err != nil {
+		return err
+	}
+	// the events authorize their own addresses, only the configured
+	// allowlist tells whether a change was expected
+	allowlist := NewUpdaterAllowlist(nil, configured)
+
+	var firing []helpers.Alert
+	for _, event := range events {
+		severity := SeverityCritical
+		if expectedAccess(allowlist, event) {
+			severity = SeverityWarning
+		}
+		firing = append(firing, helpers.Alert{
+			Key:           fmt.Sprintf("%s:%s:%s:%s:%d", KindAccess, event.ChainID, strings.ToLower(event.OracleAddress), event.TransactionHash, event.LogIndex),
+			Severity:      severity,
+			ChainID:       event.ChainID,
+			OracleAddress: event.OracleAddress,
+			Message:       AccessMessage(event),
+		})
+	}
+
+	return c.manager.Reconcile(KindAccess, firing)
+}
+
+// expectedAccess tells whether the allowlist declares the change: an added
+// updater or new owner it allows, or a removed updater it does not. Nothing
+// is expected of oracles the allowlist does not cover.
+func expectedAccess(allowlist *UpdaterAllowlist, event helpers.AccessEvent) bool {
+	if !allowlist.Covers(event.ChainID, event.OracleAddress) {
+		return false
+	}
+	allowed := allowlist.Allows(event.ChainID, event.OracleAddress, event.NewAddress)
+	if event.Kind == helpers.AccessUpdaterRemoved {
+		return !allowed
+	}
+	return allowed
+}
+
+// AccessMessage describes an access control event.
+func AccessMessage(event helpers.AccessEvent) string {
+	var change string
+	switch event.Kind {
+	case helpers.AccessOwnerChange:
+		change = fmt.Sprintf("owner changed from %s to %s", event.PreviousAddress, event.NewAddress)
+	case helpers.AccessUpdaterRemoved:
+		change = fmt.Sprintf("updater %s removed", event.NewAddress)
+	case helpers.AccessUpdaterSet:
+		change = fmt.Sprintf("only updater set to %s", event.NewAddress)
+	default:
+		change = fmt.Sprintf("updater %s added", event.NewAddress)
+	}
+
+	via := ""
+	if event.Method != "" {
+		via = " via " + event.Method
+	}
+	return fmt.Sprintf("%s%s by %s in transaction %s block %d", change, via, event.Sender, event.TransactionHash, event.BlockNumber)
+}
@@ -0,0 +1,134 @@
+package alerts
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+func TestAccessChecker(t *testing.T) {
+	const (
+		oracle  = "0x1000000000000000000000000000000000000001"
+		updater = "0x2000000000000000000000000000000000000002"
+		other   = "0x3000000000000000000000000000000000000003"
+	)
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	event := func(tx string, logIndex uint, age time.Duration, address string) helpers.AccessEvent {
+		return helpers.AccessEvent{
+			ChainID:         "1",
+			OracleAddress:   oracle,
+			TransactionHash: tx,
+			LogIndex:        logIndex,
+			BlockNumber:     uint64(now.Add(-age).Unix()),
+			BlockTimestamp:  now.Add(-age),
+			Kind:            helpers.AccessUpdaterChange,
+			NewAddress:      address,
+		}
+	}
+	allowlist := `{"updater-allowlist": [{"ChainID": "1", "OracleAddress": "` + oracle + `", "Updater": "` + updater + `"}]}`
+
+	type alert struct {
+		message  string
+		severity string
+	}
+	tests := []struct {
+		name   string
+		seed   string
+		events []helpers.AccessEvent
+		alerts []alert
+	}{
+		{"no events", "", nil, nil},
+		{"recent change", "", []helpers.AccessEvent{event("0xa", 0, time.Hour, updater)}, []alert{{"updater " + updater + " added", SeverityCritical}}},
+		{"change outside the window", "", []helpers.AccessEvent{event("0xa", 0, 25*time.Hour, updater)}, nil},
+		{
+			name:   "removal within the window",
+			events: []helpers.AccessEvent{event("0xa", 0, 30*time.Hour, updater), event("0xb", 0, time.Hour, updater)},
+			alerts: []alert{{"updater " + updater + " removed", SeverityCritical}},
+		},
+		{
+			name:   "two logs of a transaction",
+			events: []helpers.AccessEvent{event("0xa", 0, time.Hour, updater), event("0xa", 1, time.Hour, updater)},
+			alerts: []alert{{"updater " + updater + " added", SeverityCritical}, {"updater " + updater + " removed", SeverityCritical}},
+		},
+		{"expected updater added", allowlist, []helpers.AccessEvent{event("0xa", 0, time.Hour, updater)}, []alert{{"updater " + updater + " added", SeverityWarning}}},
+		{"unexpected updater added", allowlist, []helpers.AccessEvent{event("0xa", 0, time.Hour, other)}, []alert{{"updater " + other + " added", SeverityCritical}}},
+		{
+			name:   "expected updater removed",
+			seed:   allowlist,
+			events: []helpers.AccessEvent{event("0xa", 0, 30*time.Hour, updater), event("0xa", 1, 30*time.Hour, other), event("0xb", 0, time.Hour, other)},
+			alerts: []alert{{"updater " + other + " removed", SeverityWarning}},
+		},
+		{
+			name:   "unexpected updater removed",
+			seed:   allowlist,
+			events: []helpers.AccessEvent{event("0xa", 0, 30*time.Hour, updater), event("0xb", 0, time.Hour, updater)},
+			alerts: []alert{{"updater " + updater + " removed", SeverityCritical}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, tt.seed)
+			if err := db.InsertAccessEvents(tt.events); err != nil {
+				t.Fatalf("InsertAccessEvents: %v", err)
+			}
+			manager := newTestManager(t, db)
+
+			if err := NewAccessChecker(db, manager, DefaultAccessWindow).Evaluate(now); err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			active := manager.Active()
+			if len(active) != len(tt.alerts) {
+				t.Fatalf("got alerts %+v, want %v", active, tt.alerts)
+			}
+			for _, want := range tt.alerts {
+				found := false
+				for _, a := range active {
+					if strings.HasPrefix(a.Message, want.message+" by") {
+						found = true
+						if a.Severity != want.severity {
+							t.Errorf("alert %q has severity %s, want %s", want.message, a.Severity, want.severity)
+						}
+					}
+				}
+				if !found {
+					t.Errorf("no alert for %q in %+v", want.message, active)
+				}
+			}
+		})
+	}
+}
+
+func TestAccessMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		event helpers.AccessEvent
+		want  string
+	}{
+		{
+			name:  "owner change",
+			event: helpers.AccessEvent{Kind: helpers.AccessOwnerChange, PreviousAddress: "0xa", NewAddress: "0xb", Sender: "0xa", TransactionHash: "0x1", BlockNumber: 7},
+			want:  "owner changed from 0xa to 0xb by 0xa in transaction 0x1 block 7",
+		},
+		{
+			name:  "updater set through the oracle",
+			event: helpers.AccessEvent{Kind: helpers.AccessUpdaterSet, NewAddress: "0xb", Method: "setUpdater", Sender: "0xa", TransactionHash: "0x1", BlockNumber: 7},
+			want:  "only updater set to 0xb via setUpdater by 0xa in transaction 0x1 block 7",
+		},
+		{
+			name:  "updater removed",
+			event: helpers.AccessEvent{Kind: helpers.AccessUpdaterRemoved, NewAddress: "0xb", Sender: "0xa", TransactionHash: "0x1", BlockNumber: 7},
+			want:  "updater 0xb removed by 0xa in transaction 0x1 block 7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AccessMessage(tt.event); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Store persists alert state so a restart does not notify firing alerts again.
+type Store interface {
+	SelectActiveAlerts() ([]helpers.Alert, error)
+	UpsertAlert(alert helpers.Alert) error
+}
+
+// Notifier delivers alert transitions to the operators.
+type Notifier interface {
+	Notify(alert helpers.Alert) error
+}
+
+// Check is evaluated periodically and raises or resolves its alerts.
+type Check interface {
+	Name() string
+	Evaluate(now time.Time) error
+}
+
+// Observer inspects every oracle update handed to the writer.
+type Observer interface {
+	Observe(metrics helpers.OracleMetrics)
+}
+
+// Runner does background work until its context is cancelled.
+type Runner interface {
+	Run(ctx context.Context)
+}
+
+// Manager keeps the state of every alert. Notifiers are only called when an
+// alert starts firing or resolves, never while it keeps firing.
+type Manager struct {
+	mu        sync.Mutex
+	store     Store
+	notifiers []Notifier
+	active    map[string]helpers.Alert
+}
+
+// NewManager creates a manager seeded with the alerts still firing in store.
+func NewManager(store Store, notifiers ...Notifier) (*Manager, error) {
+	m := &Manager{
+		store:     store,
+		notifiers: notifiers,
+		active:    make(map[string]helpers.Alert),
+	}
+
+	alerts, err := store.SelectActiveAlerts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the active alerts: %v", err)
+	}
+	for _, alert := range alerts {
+		m.active[alert.Key] = alert
+	}
+
+	return m, nil
+}
+
+// Raise fires the alert unless it is already firing.
+func (m *Manager) Raise(alert helpers.Alert) error {
+	m.mu.Lock()
+	if _, ok := m.active[alert.Key]; ok {
+		m.mu.Unlock()
+		return nil
+	}
+
+	alert.Firing = true
+	if alert.FiredAt.IsZero() {
+		alert.FiredAt = time.Now()
+	}
+	m.active[alert.Key] = alert
+	m.mu.Unlock()
+
+	// an alert that was not stored is raised again on the next evaluation
+	if err := m.transition(alert); err != nil {
+		m.mu.Lock()
+		delete(m.active, alert.Key)
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Resolve resolves the alert with the given key if it is firing.
+func (m *Manager) Resolve(key string) error {
+	m.mu.Lock()
+	alert, ok := m.active[key]
+	if !ok {
+		m.mu.Unlock()
+		return nil
+	}
+	delete(m.active, key)
+	m.mu.Unlock()
+
+	resolved := alert
+	resolved.Firing = false
+	resolved.ResolvedAt = time.Now()
+	if err := m.transition(resolved); err != nil {
+		m.mu.Lock()
+		m.active[key] = alert
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Reconcile raises every alert in firing and resolves the active alerts of
+// kind that are no longer part of it. It suits checks that evaluate their
+// whole scope at once.
+func (m *Manager) Reconcile(kind string, firing []helpers.Alert) error {
+	keep := make(map[string]bool, len(firing))
+	var errs []error
+
+	for _, alert := range firing {
+		alert.Kind = kind
+		keep[alert.Key] = true
+		if err := m.Raise(alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, alert := range m.Active() {
+		if alert.Kind == kind && !keep[alert.Key] {
+			if err := m.Resolve(alert.Key); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to reconcile %s alerts: %v", kind, errs)
+	}
+	return nil
+}
+
+// Get returns the alert with the given key if it is firing.
+func (m *Manager) Get(key string) (helpers.Alert, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alert, ok := m.active[key]
+	return alert, ok
+}
+
+// Active returns the alerts currently firing.
+func (m *Manager) Active() []helpers.Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alerts := make([]helpers.Alert, 0, len(m.active))
+	for _, alert := range m.active {
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+func (m *Manager) transition(alert helpers.Alert) error {
+	if err := m.store.UpsertAlert(alert); err != nil {
+		return err
+	}
+
+	for _, notifier := range m.notifiers {
+		if err := notifier.Notify(alert); err != nil {
+			log.Printf("failed to notify alert %s: %v", alert.Key, err)
+		}
+	}
+	return nil
+}
+
+// Engine runs the registered checks and feeds updates to the observers.
+type Engine struct {
+	Manager   *Manager
+	checks    []Check
+	observers []Observer
+	runners   []Runner
+}
+
+func NewEngine(manager *Manager) *Engine {
+	return &Engine{Manager: manager}
+}
+
+// Register adds c as a check, an observer and a background runner depending
+// on what it implements.
+func (e *Engine) Register(c interface{}) {
+	if check, ok := c.(Check); ok {
+		e.checks = append(e.checks, check)
+	}
+	if observer, ok := c.(Observer); ok {
+		e.observers = append(e.observers, observer)
+	}
+	if runner, ok := c.(Runner); ok {
+		e.runners = append(e.runners, runner)
+	}
+}
+
+// Observe hands an update to every observer.
+func (e *Engine) Observe(metrics helpers.OracleMetrics) {
+	for _, observer := range e.observers {
+		observer.Observe(metrics)
+	}
+}
+
+// Evaluate runs every check once.
+func (e *Engine) Evaluate(now time.Time) {
+	for _, check := range e.checks {
+		if err := check.Evaluate(now); err != nil {
+			log.Printf("failed to evaluate %s alerts: %v", check.Name(), err)
+		}
+	}
+}
+
+// Run starts the background runners and evaluates the checks every interval
+// until ctx is cancelled, then waits for the runners to return.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	var running sync.WaitGroup
+	defer running.Wait()
+	for _, runner := range e.runners {
+		running.Add(1)
+		go func(runner Runner) {
+			defer running.Done()
+			runner.Run(ctx)
+		}(runner)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.Evaluate(now)
+		}
+	}
+}
+
+// LogNotifier writes alert transitions to the standard logger.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(alert helpers.Alert) error {
+	state := "RESOLVED"
+	if alert.Firing {
+		state = "FIRING"
+	}
+	log.Printf("[alert %s] %s %s chain %s oracle %s key %s: %s", alert.Severity, state, alert.Kind, alert.ChainID, alert.OracleAddress, alert.AssetKey, alert.Message)
+	return nil
+}
+
+// WebhookNotifier posts alert transitions as JSON to a URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *WebhookNotifier) Notify(alert helpers.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode the alert: %v", err)
+	}
+
+	resp, err := w.Client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post the alert: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered with status %s", resp.Status)
+	}
+	return nil
+}
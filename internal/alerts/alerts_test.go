@@ -0,0 +1,187 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/database"
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+// newTestDB returns a memory database seeded with the JSON seed.
+func newTestDB(t *testing.T, seed string) database.Database {
+	t.Helper()
+
+	file := ""
+	if seed != "" {
+		file = filepath.Join(t.TempDir(), "seed.json")
+		if err := os.WriteFile(file, []byte(seed), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := database.NewMemoryDB(file, false, 0)
+	if err := db.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return db
+}
+
+func newTestManager(t *testing.T, db database.Database, notifiers ...Notifier) *Manager {
+	t.Helper()
+
+	manager, err := NewManager(db, notifiers...)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return manager
+}
+
+// recordingNotifier keeps the transitions it is notified of.
+type recordingNotifier struct {
+	alerts []helpers.Alert
+}
+
+func (n *recordingNotifier) Notify(alert helpers.Alert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+// failingStore fails every write.
+type failingStore struct {
+	database.Database
+}
+
+func (failingStore) UpsertAlert(helpers.Alert) error {
+	return errors.New("database is down")
+}
+
+func TestManagerReconcile(t *testing.T) {
+	alert := func(key string) helpers.Alert {
+		return helpers.Alert{Key: key, Severity: SeverityWarning, Message: key}
+	}
+
+	tests := []struct {
+		name   string
+		rounds [][]helpers.Alert
+		active []string
+		// transitions notified, resolved ones prefixed with -
+		notified []string
+	}{
+		{
+			name:     "fires once",
+			rounds:   [][]helpers.Alert{{alert("a")}, {alert("a")}},
+			active:   []string{"a"},
+			notified: []string{"a"},
+		},
+		{
+			name:     "resolves when gone",
+			rounds:   [][]helpers.Alert{{alert("a"), alert("b")}, {alert("b")}},
+			active:   []string{"b"},
+			notified: []string{"a", "b", "-a"},
+		},
+		{
+			name:     "fires again after resolving",
+			rounds:   [][]helpers.Alert{{alert("a")}, nil, {alert("a")}},
+			active:   []string{"a"},
+			notified: []string{"a", "-a", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &recordingNotifier{}
+			db := newTestDB(t, "")
+			manager := newTestManager(t, db, notifier)
+			for _, firing := range tt.rounds {
+				if err := manager.Reconcile(KindStale, firing); err != nil {
+					t.Fatalf("Reconcile: %v", err)
+				}
+			}
+
+			if active := manager.Active(); len(active) != len(tt.active) {
+				t.Errorf("got %d active alerts, want %v", len(active), tt.active)
+			}
+			for _, key := range tt.active {
+				if alert, ok := manager.Get(key); !ok || alert.Kind != KindStale || !alert.Firing {
+					t.Errorf("alert %s is not firing: %+v", key, alert)
+				}
+			}
+
+			var notified []string
+			for _, alert := range notifier.alerts {
+				if alert.Firing {
+					notified = append(notified, alert.Key)
+				} else {
+					notified = append(notified, "-"+alert.Key)
+				}
+			}
+			if len(notified) != len(tt.notified) {
+				t.Fatalf("notified %v, want %v", notified, tt.notified)
+			}
+			for i := range notified {
+				if notified[i] != tt.notified[i] {
+					t.Errorf("notified %v, want %v", notified, tt.notified)
+					break
+				}
+			}
+
+			// a restarted manager does not notify the firing alerts again
+			restarted := newTestManager(t, db, notifier)
+			if len(restarted.Active()) != len(tt.active) {
+				t.Errorf("restarted manager has %d active alerts, want %d", len(restarted.Active()), len(tt.active))
+			}
+		})
+	}
+}
+
+func TestManagerStoreFailure(t *testing.T) {
+	notifier := &recordingNotifier{}
+	manager := newTestManager(t, failingStore{newTestDB(t, "")}, notifier)
+
+	if err := manager.Reconcile(KindStale, []helpers.Alert{{Key: "a"}}); err == nil {
+		t.Error("Reconcile succeeded although the store failed")
+	}
+	if len(notifier.alerts) != 0 {
+		t.Errorf("notified %d alerts that were not stored", len(notifier.alerts))
+	}
+	if _, ok := manager.Get("a"); ok {
+		t.Error("an alert that was not stored is left active")
+	}
+}
+
+// testRunner counts the runs that returned.
+type testRunner struct {
+	delay    time.Duration
+	finished *atomic.Int32
+}
+
+func (r testRunner) Run(ctx context.Context) {
+	<-ctx.Done()
+	time.Sleep(r.delay)
+	r.finished.Add(1)
+}
+
+func TestEngineRunWaitsForRunners(t *testing.T) {
+	var finished atomic.Int32
+	engine := NewEngine(newTestManager(t, newTestDB(t, "")))
+	engine.Register(testRunner{delay: 10 * time.Millisecond, finished: &finished})
+	engine.Register(testRunner{delay: 20 * time.Millisecond, finished: &finished})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		engine.Run(ctx, time.Hour)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	if n := finished.Load(); n != 2 {
+		t.Errorf("Run returned with %d of 2 runners finished", n)
+	}
+}
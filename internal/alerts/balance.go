@@ -0,0 +1,195 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
+)
+
+const KindRunway = "runway"
+
+const (
+	// time between two balance samples of every feeder
+	balancePollInterval = 10 * time.Minute
+	// spend history used to compute the burn rate
+	burnRateWindow = 7 * 24 * time.Hour
+	// balances read at the same time
+	balanceConcurrency = 8
+	// time allowed for reading a single balance
+	balanceTimeout = 30 * time.Second
+)
+
+// BalanceReader reads the balance of an account on a chain.
+type BalanceReader interface {
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// BalanceSource lists the feeder wallets, records their balances and returns
+// what they spent on updates.
+type BalanceSource interface {
+	SelectFeeders() ([]helpers.Feeder, error)
+	InsertFeederBalance(balance helpers.FeederBalance) error
+	SelectFeederSpend(feeder helpers.Feeder, since time.Time) (string, error)
+}
+
+// BalanceTracker samples the balance of every feeder wallet and alerts when
+// the balance will not cover the current spend for minRunway. It runs on its
+// own goroutine so slow nodes do not hold up the other checks.
+type BalanceTracker struct {
+	source    BalanceSource
+	manager   *Manager
+	clients   map[string]BalanceReader
+	minRunway time.Duration
+}
+
+func NewBalanceTracker(source BalanceSource, manager *Manager, clients map[string]BalanceReader, minRunway time.Duration) *BalanceTracker {
+	return &BalanceTracker{
+		source:    source,
+		manager:   manager,
+		clients:   clients,
+		minRunway: minRunway,
+	}
+}
+
+func (t *BalanceTracker) Name() string {
+	return KindRunway
+}
+
+// Runway returns how long balance lasts when spent spent over window. The
+// runway is unbounded when nothing was spent.
+func Runway(balance, spent *big.Int, window time.Duration) (time.Duration, bool) {
+	if spent.Sign() <= 0 {
+		return 0, false
+	}
+
+	seconds, _ := new(big.Float).Quo(
+		new(big.Float).Mul(new(big.Float).SetInt(balance), big.NewFloat(window.Seconds())),
+		new(big.Float).SetInt(spent),
+	).Float64()
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// Run samples the balances every balancePollInterval until ctx is cancelled.
+func (t *BalanceTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(balancePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.poll(ctx, time.Now()); err != nil {
+			log.Printf("failed to evaluate %s alerts: %v", t.Name(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readBalances reads the balance of every feeder with a known chain,
+// balanceConcurrency at a time. Balances that could not be read are nil.
+func (t *BalanceTracker) readBalances(ctx context.Context, feeders []helpers.Feeder) []*big.Int {
+	balances := make([]*big.Int, len(feeders))
+	slots := make(chan struct{}, balanceConcurrency)
+
+	var wg sync.WaitGroup
+	for i, feeder := range feeders {
+		client, ok := t.clients[feeder.ChainID]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, feeder helpers.Feeder) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			callCtx, cancel := context.WithTimeout(ctx, balanceTimeout)
+			defer cancel()
+			balance, err := client.BalanceAt(callCtx, common.HexToAddress(feeder.Address), nil)
+			if err != nil {
+				log.Printf("failed to get balance of feeder %s chain %s: %v", feeder.Address, feeder.ChainID, err)
+				return
+			}
+			balances[i] = balance
+		}(i, feeder)
+	}
+	wg.Wait()
+	return balances
+}
+
+// poll samples the balances once and reconciles the runway alerts.
+func (t *BalanceTracker) poll(ctx context.Context, now time.Time) error {
+	feeders, err := t.source.SelectFeeders()
+	if err != nil {
+		return err
+	}
+	balances := t.readBalances(ctx, feeders)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	var firing []helpers.Alert
+	for i, feeder := range feeders {
+		if _, ok := t.clients[feeder.ChainID]; !ok {
+			continue
+		}
+		key := fmt.Sprintf("%s:%s:%s", KindRunway, feeder.ChainID, feeder.Address)
+
+		balance := balances[i]
+		if balance == nil {
+			// an unknown balance does not resolve the alert
+			if alert, ok := t.manager.Get(key); ok {
+				firing = append(firing, alert)
+			}
+			continue
+		}
+
+		metrics.ObserveBalance(feeder.ChainID, feeder.Address, balance)
+
+		err = t.source.InsertFeederBalance(helpers.FeederBalance{Feeder: feeder, Balance: balance.String(), RecordedAt: now})
+		if err != nil {
+			return err
+		}
+
+		spentValue, err := t.source.SelectFeederSpend(feeder, now.Add(-burnRateWindow))
+		if err != nil {
+			return err
+		}
+		spent, ok := new(big.Int).SetString(spentValue, 10)
+		if !ok {
+			return fmt.Errorf("invalid spend %q for feeder %s", spentValue, feeder.Address)
+		}
+
+		runway, ok := Runway(balance, spent, burnRateWindow)
+		if !ok || runway >= t.minRunway {
+			continue
+		}
+
+		severity := SeverityWarning
+		if runway < 24*time.Hour {
+			severity = SeverityCritical
+		}
+
+		firing = append(firing, helpers.Alert{
+			Key:      key,
+			Severity: severity,
+			ChainID:  feeder.ChainID,
+			Message:  fmt.Sprintf("feeder %s has %.1f days of runway left with balance %s wei", feeder.Address, runway.Hours()/24, balance),
+		})
+	}
+
+	return t.manager.Reconcile(KindRunway, firing)
+}
@@ -0,0 +1,129 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const KindCatalogue = "catalogue"
+
+// Catalogue status of an asset key
+const (
+	// declared and updated
+	AssetListed = "listed"
+	// declared but never updated
+	AssetMissing = "missing"
+	// updated but not declared by an oracle with a catalogue
+	AssetUnlisted = "unlisted"
+	// updated by an oracle without a catalogue
+	AssetDiscovered = "discovered"
+)
+
+// AssetStatus is an asset key of an oracle as declared in the catalogue and
+// found in the stored updates. LastUpdate is zero for keys never updated.
+type AssetStatus struct {
+	helpers.CatalogueEntry
+	Status     string
+	LastBlock  uint64
+	LastUpdate time.Time
+}
+
+// CompareCatalogue matches the declared keys with the keys found in the
+// stored updates, declared keys first in their order.
+func CompareCatalogue(entries []helpers.CatalogueEntry, updates []helpers.AssetUpdate) []AssetStatus {
+	oracleKey := func(chainID, oracle string) string {
+		return chainID + ":" + strings.ToLower(oracle)
+	}
+
+	catalogued := make(map[string]bool)
+	declared := make(map[string]int)
+	statuses := make([]AssetStatus, 0, len(entries))
+	for _, entry := range entries {
+		catalogued[oracleKey(entry.ChainID, entry.OracleAddress)] = true
+		declared[oracleKey(entry.ChainID, entry.OracleAddress)+":"+entry.AssetKey] = len(statuses)
+		statuses = append(statuses, AssetStatus{CatalogueEntry: entry, Status: AssetMissing})
+	}
+
+	for _, update := range updates {
+		if i, ok := declared[oracleKey(update.ChainID, update.OracleAddress)+":"+update.AssetKey]; ok {
+			statuses[i].Status = AssetListed
+			statuses[i].LastBlock = update.UpdateBlock
+			statuses[i].LastUpdate = update.UpdateTime
+			continue
+		}
+
+		status := AssetDiscovered
+		if catalogued[oracleKey(update.ChainID, update.OracleAddress)] {
+			status = AssetUnlisted
+		}
+		statuses = append(statuses, AssetStatus{
+			CatalogueEntry: helpers.CatalogueEntry{ChainID: update.ChainID, OracleAddress: update.OracleAddress, AssetKey: update.AssetKey},
+			Status:         status,
+			LastBlock:      update.UpdateBlock,
+			LastUpdate:     update.UpdateTime,
+		})
+	}
+	return statuses
+}
+
+// CatalogueSource provides the declared asset keys and the latest update of
+// every stored key.
+type CatalogueSource interface {
+	SelectAssetCatalogue(chainID string, oracleAddress string) ([]helpers.CatalogueEntry, error)
+	SelectLatestUpdates() ([]helpers.AssetUpdate, error)
+}
+
+// CatalogueChecker warns about keys an oracle is expected to serve but never
+// updated, and keys it updated without declaring them. Oracles without a
+// catalogue are not judged.
+type CatalogueChecker struct {
+	source  CatalogueSource
+	manager *Manager
+}
+
+func NewCatalogueChecker(source CatalogueSource, manager *Manager) *CatalogueChecker {
+	return &CatalogueChecker{source: source, manager: manager}
+}
+
+func (c *CatalogueChecker) Name() string {
+	return KindCatalogue
+}
+
+// Evaluate compares the catalogue with the stored keys.
+func (c *CatalogueChecker) Evaluate(now time.Time) error {
+	entries, err := c.source.SelectAssetCatalogue("", "")
+	if err != nil {
+		return err
+	}
+	updates, err := c.source.SelectLatestUpdates()
+	if err != nil {
+		return err
+	}
+
+	var firing []helpers.Alert
+	for _, status := range CompareCatalogue(entries, updates) {
+		var message string
+		switch status.Status {
+		case AssetMissing:
+			message = fmt.Sprintf("expected key %s was never updated", status.AssetKey)
+		case AssetUnlisted:
+			message = fmt.Sprintf("key %s is updated but not in the catalogue, last in block %d", status.AssetKey, status.LastBlock)
+		default:
+			continue
+		}
+
+		firing = append(firing, helpers.Alert{
+			Key:           fmt.Sprintf("%s:%s:%s:%s", KindCatalogue, status.ChainID, strings.ToLower(status.OracleAddress), status.AssetKey),
+			Severity:      SeverityWarning,
+			ChainID:       status.ChainID,
+			OracleAddress: status.OracleAddress,
+			AssetKey:      status.AssetKey,
+			Message:       message,
+		})
+	}
+
+	return c.manager.Reconcile(KindCatalogue, firing)
+}
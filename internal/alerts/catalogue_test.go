@@ -0,0 +1,73 @@
+package alerts
+
+import (
+	"testing"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+func TestCompareCatalogue(t *testing.T) {
+	const (
+		listed   = "0x1000000000000000000000000000000000000001"
+		unlisted = "0x3000000000000000000000000000000000000003"
+	)
+	entries := []helpers.CatalogueEntry{
+		{ChainID: "1", OracleAddress: listed, AssetKey: "BTC/USD"},
+		{ChainID: "1", OracleAddress: listed, AssetKey: "ETH/USD"},
+	}
+
+	tests := []struct {
+		name    string
+		updates []helpers.AssetUpdate
+		want    map[string]string
+	}{
+		{
+			name:    "nothing updated",
+			updates: nil,
+			want:    map[string]string{"BTC/USD": AssetMissing, "ETH/USD": AssetMissing},
+		},
+		{
+			name: "declared and undeclared keys",
+			updates: []helpers.AssetUpdate{
+				{ChainID: "1", OracleAddress: "0x1000000000000000000000000000000000000001", AssetKey: "BTC/USD", UpdateBlock: 5},
+				{ChainID: "1", OracleAddress: listed, AssetKey: "XAU/USD"},
+			},
+			want: map[string]string{"BTC/USD": AssetListed, "ETH/USD": AssetMissing, "XAU/USD": AssetUnlisted},
+		},
+		{
+			name:    "oracle without a catalogue",
+			updates: []helpers.AssetUpdate{{ChainID: "1", OracleAddress: unlisted, AssetKey: "XAU/USD"}},
+			want:    map[string]string{"BTC/USD": AssetMissing, "ETH/USD": AssetMissing, "XAU/USD": AssetDiscovered},
+		},
+		{
+			name:    "same oracle on another chain",
+			updates: []helpers.AssetUpdate{{ChainID: "2", OracleAddress: listed, AssetKey: "BTC/USD"}},
+			want:    map[string]string{"BTC/USD": AssetMissing, "ETH/USD": AssetMissing},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses := CompareCatalogue(entries, tt.updates)
+			if statuses[0].AssetKey != "BTC/USD" || statuses[1].AssetKey != "ETH/USD" {
+				t.Errorf("declared keys are not listed first in their order")
+			}
+			got := make(map[string]string)
+			for _, status := range statuses {
+				if status.ChainID == "1" {
+					got[status.AssetKey] = status.Status
+				} else if status.Status != AssetDiscovered {
+					t.Errorf("key %s of chain %s is %s, want %s", status.AssetKey, status.ChainID, status.Status, AssetDiscovered)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for key, status := range tt.want {
+				if got[key] != status {
+					t.Errorf("key %s is %s, want %s", key, got[key], status)
+				}
+			}
+		})
+	}
+}
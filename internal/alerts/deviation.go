@@ -0,0 +1,294 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
+)
+
+const (
+	KindSanity    = "sanity"
+	KindJump      = "jump"
+	KindReference = "reference"
+)
+
+const (
+	// number of updates waiting for the deviation checks before the writers
+	// wait for them
+	deviationQueueSize = 1024
+	// age beyond which an update is not compared with the current reference
+	// price, historical and backfilled updates would be compared with a price
+	// of another time
+	referenceMaxAge = 10 * time.Minute
+)
+
+var maxUint128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// DeviationSource provides the value limits, the asset catalogue and the
+// previous value of a key and stores the findings.
+type DeviationSource interface {
+	SelectDeviationRules() ([]helpers.DeviationRule, error)
+	SelectAssetCatalogue(chainID string, oracleAddress string) ([]helpers.CatalogueEntry, error)
+	SelectPreviousPrice(chainID string, oracleAddress string, assetKey string, block uint64) (string, error)
+	InsertFinding(finding helpers.Finding) error
+}
+
+// DeviationChecker judges every pushed value: it must be a positive uint128,
+// must not jump too far from the previous value of the key and must stay
+// close to the reference price when a provider is configured. Keys declared in
+// the asset catalogue are read with their declared decimals and, unless a rule
+// sets a limit, must stay within their declared deviation of the reference.
+type DeviationChecker struct {
+	source    DeviationSource
+	manager   *Manager
+	reference ReferencePriceProvider
+	queue     chan helpers.OracleMetrics
+	stopped   chan struct{}
+
+	mu        sync.Mutex
+	rules     []helpers.DeviationRule
+	catalogue map[string]helpers.CatalogueEntry
+	// block of the latest checked update of every key, older updates do not
+	// move its alerts
+	latest map[string]uint64
+}
+
+// NewDeviationChecker creates the checker, reference may be nil.
+func NewDeviationChecker(source DeviationSource, manager *Manager, reference ReferencePriceProvider) (*DeviationChecker, error) {
+	c := &DeviationChecker{
+		source:    source,
+		manager:   manager,
+		reference: reference,
+		queue:     make(chan helpers.OracleMetrics, deviationQueueSize),
+		stopped:   make(chan struct{}),
+		latest:    make(map[string]uint64),
+	}
+	if err := c.Evaluate(time.Now()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *DeviationChecker) Name() string {
+	return "deviation"
+}
+
+// Evaluate reloads the value limits and the catalogue.
+func (c *DeviationChecker) Evaluate(now time.Time) error {
+	rules, err := c.source.SelectDeviationRules()
+	if err != nil {
+		return err
+	}
+	entries, err := c.source.SelectAssetCatalogue("", "")
+	if err != nil {
+		return err
+	}
+	catalogue := make(map[string]helpers.CatalogueEntry, len(entries))
+	for _, entry := range entries {
+		catalogue[updateKey(entry.ChainID, entry.OracleAddress, entry.AssetKey)] = entry
+	}
+
+	c.mu.Lock()
+	c.rules = rules
+	c.catalogue = catalogue
+	c.mu.Unlock()
+	return nil
+}
+
+// Observe queues the update, the checks run on their own goroutine so the
+// writer is never slowed down by the reference provider. Updates arriving
+// while the queue is full or once the checker stopped are counted and skipped.
+func (c *DeviationChecker) Observe(update helpers.OracleMetrics) {
+	if update.Removed || update.AssetKey == "" {
+		return
+	}
+
+	select {
+	case c.queue <- update:
+		return
+	case <-c.stopped:
+	default:
+	}
+	metrics.SkippedChecks.WithLabelValues("deviation").Inc()
+}
+
+// Run checks queued updates until ctx is cancelled.
+func (c *DeviationChecker) Run(ctx context.Context) {
+	defer close(c.stopped)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case metrics := <-c.queue:
+			if err := c.check(ctx, metrics); err != nil {
+				log.Printf("failed to check update %s: %v", metrics.TransactionHash, err)
+			}
+		}
+	}
+}
+
+func (c *DeviationChecker) rule(metrics helpers.OracleMetrics) helpers.DeviationRule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rule, _ := matchRule(c.rules, func(r helpers.DeviationRule) (string, string, string) {
+		return r.ChainID, r.OracleAddress, r.AssetKey
+	}, metrics.ChainID, metrics.TransactionTo.Hex(), metrics.AssetKey)
+	// a feeder pushes once the value moved by the declared deviation, the
+	// value it pushed cannot be further off
+	if entry, ok := c.catalogue[updateKey(metrics.ChainID, metrics.TransactionTo.Hex(), metrics.AssetKey)]; ok {
+		if rule.Decimals == 0 {
+			rule.Decimals = entry.Decimals
+		}
+		if rule.MaxReferenceDeviationPercent == 0 {
+			rule.MaxReferenceDeviationPercent = entry.DeviationPercent
+		}
+	}
+	if rule.Decimals == 0 {
+		rule.Decimals = metrics.AssetDecimals
+	}
+	return rule
+}
+
+// isLatest records the block of an update and tells whether no later update
+// of its key was checked before. Replayed and backfilled updates still store
+// their findings but leave the alerts to the latest update.
+func (c *DeviationChecker) isLatest(update helpers.OracleMetrics) bool {
+	block, _ := strconv.ParseUint(update.BlockNumber, 10, 64)
+	key := updateKey(update.ChainID, update.TransactionTo.Hex(), update.AssetKey)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if block < c.latest[key] {
+		return false
+	}
+	c.latest[key] = block
+	return true
+}
+
+// SanityFinding describes why a value cannot be a valid price, or returns an
+// empty string when it can.
+func SanityFinding(value *big.Int) string {
+	switch {
+	case value == nil:
+		return "value is missing"
+	case value.Sign() == 0:
+		return "value is zero"
+	case value.Sign() < 0:
+		return fmt.Sprintf("value %s is negative", value)
+	case value.Cmp(maxUint128) > 0:
+		return fmt.Sprintf("value %s overflows uint128", value)
+	}
+	return ""
+}
+
+// JumpPercent returns how far value moved from previous, in percent of previous.
+func JumpPercent(previous, value *big.Int) float64 {
+	diff := new(big.Float).SetInt(new(big.Int).Sub(value, previous))
+	ratio, _ := new(big.Float).Quo(diff, new(big.Float).SetInt(previous)).Float64()
+	return math.Abs(ratio) * 100
+}
+
+func (c *DeviationChecker) check(ctx context.Context, metrics helpers.OracleMetrics) error {
+	rule := c.rule(metrics)
+	findings := make(map[string]string)
+	checked := []string{KindSanity}
+
+	if msg := SanityFinding(metrics.AssetPrice); msg != "" {
+		findings[KindSanity] = msg
+	} else {
+		value := metrics.AssetPrice
+
+		if rule.MaxJumpPercent > 0 {
+			block, _ := strconv.ParseUint(metrics.BlockNumber, 10, 64)
+			previous, err := c.source.SelectPreviousPrice(metrics.ChainID, metrics.TransactionTo.Hex(), metrics.AssetKey, block)
+			if err != nil {
+				return err
+			}
+
+			checked = append(checked, KindJump)
+			if prev, ok := new(big.Int).SetString(previous, 10); ok && prev.Sign() > 0 {
+				if jump := JumpPercent(prev, value); jump > rule.MaxJumpPercent {
+					findings[KindJump] = fmt.Sprintf("value moved %.2f%% from %s to %s, limit is %.2f%%", jump, previous, metrics.AssetPrice, rule.MaxJumpPercent)
+				}
+			}
+		}
+
+		if rule.MaxReferenceDeviationPercent > 0 && c.reference != nil && time.Since(metrics.BlockTimestamp) <= referenceMaxAge {
+			reference, err := c.reference.Price(ctx, metrics.AssetKey)
+			if err != nil {
+				log.Printf("failed to get the reference price of %s: %v", metrics.AssetKey, err)
+			} else if reference > 0 {
+				checked = append(checked, KindReference)
+
+				scale := new(big.Float).SetFloat64(math.Pow10(rule.Decimals))
+				onchain, _ := new(big.Float).Quo(new(big.Float).SetInt(value), scale).Float64()
+				deviation := math.Abs(onchain-reference) / reference * 100
+				if deviation > rule.MaxReferenceDeviationPercent {
+					findings[KindReference] = fmt.Sprintf("value %g deviates %.2f%% from reference %g, limit is %.2f%%", onchain, deviation, reference, rule.MaxReferenceDeviationPercent)
+				}
+			}
+		}
+	}
+
+	current := c.isLatest(metrics)
+	for _, kind := range checked {
+		key := fmt.Sprintf("%s:%s", kind, updateKey(metrics.ChainID, metrics.TransactionTo.Hex(), metrics.AssetKey))
+
+		msg, found := findings[kind]
+		if !found {
+			if !current {
+				continue
+			}
+			if err := c.manager.Resolve(key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		severity := SeverityWarning
+		if kind == KindSanity {
+			severity = SeverityCritical
+		}
+
+		finding := helpers.Finding{
+			ChainID:         metrics.ChainID,
+			OracleAddress:   metrics.TransactionTo.Hex(),
+			TransactionHash: metrics.TransactionHash,
+			AssetKey:        metrics.AssetKey,
+			Kind:            kind,
+			Severity:        severity,
+			Message:         msg,
+			CreatedAt:       time.Now(),
+		}
+		if err := c.source.InsertFinding(finding); err != nil {
+			return err
+		}
+		if !current {
+			continue
+		}
+
+		err := c.manager.Raise(helpers.Alert{
+			Key:           key,
+			Kind:          kind,
+			Severity:      severity,
+			ChainID:       metrics.ChainID,
+			OracleAddress: metrics.TransactionTo.Hex(),
+			AssetKey:      metrics.AssetKey,
+			Message:       fmt.Sprintf("%s in transaction %s", msg, metrics.TransactionHash),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package alerts
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const deviationSeed = `{
+	"deviation-rules": [
+		{"MaxJumpPercent": 10},
+		{"ChainID": "1", "AssetKey": "ETH/USD", "MaxJumpPercent": 50, "Decimals": 6}
+	],
+	"asset-catalogue": [
+		{"ChainID": "1", "OracleAddress": "0x1000000000000000000000000000000000000001", "AssetKey": "BTC/USD", "Decimals": 18, "DeviationPercent": 1},
+		{"ChainID": "1", "OracleAddress": "0x1000000000000000000000000000000000000001", "AssetKey": "ETH/USD", "Decimals": 18}
+	]
+}`
+
+var deviationOracle = common.HexToAddress("0x1000000000000000000000000000000000000001")
+
+// fixedReference prices every key at price.
+type fixedReference float64
+
+func (r fixedReference) Price(ctx context.Context, assetKey string) (float64, error) {
+	return float64(r), nil
+}
+
+func deviationUpdate(tx string, key string, block uint64, value *big.Int) helpers.OracleMetrics {
+	return helpers.OracleMetrics{
+		TransactionMetadata: helpers.TransactionMetadata{
+			BlockNumber:     strconv.FormatUint(block, 10),
+			ChainID:         "1",
+			BlockTimestamp:  time.Now(),
+			TransactionTo:   deviationOracle,
+			TransactionHash: tx,
+		},
+		AssetKey:      key,
+		AssetPrice:    value,
+		AssetDecimals: 8,
+	}
+}
+
+func TestSanityFinding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *big.Int
+		ok    bool
+	}{
+		{"missing", nil, false},
+		{"zero", big.NewInt(0), false},
+		{"negative", big.NewInt(-1), false},
+		{"max uint128", maxUint128, true},
+		{"overflow", new(big.Int).Add(maxUint128, big.NewInt(1)), false},
+		{"price", big.NewInt(4200000000000), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if msg := SanityFinding(tt.value); (msg == "") != tt.ok {
+				t.Errorf("SanityFinding(%v) = %q", tt.value, msg)
+			}
+		})
+	}
+}
+
+func TestJumpPercent(t *testing.T) {
+	tests := []struct {
+		previous, value int64
+		want            float64
+	}{
+		{100, 100, 0},
+		{100, 110, 10},
+		{100, 90, 10},
+		{100, 250, 150},
+	}
+
+	for _, tt := range tests {
+		if got := JumpPercent(big.NewInt(tt.previous), big.NewInt(tt.value)); got != tt.want {
+			t.Errorf("JumpPercent(%d, %d) = %g, want %g", tt.previous, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDeviationRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		decimals  int
+		jump      float64
+		reference float64
+	}{
+		{"catalogue decimals and deviation", "BTC/USD", 18, 10, 1},
+		{"rule decimals win over the catalogue", "ETH/USD", 6, 50, 0},
+		{"decoded decimals without a catalogue entry", "XAU/USD", 8, 10, 0},
+	}
+
+	checker, err := NewDeviationChecker(newTestDB(t, deviationSeed), newTestManager(t, newTestDB(t, "")), nil)
+	if err != nil {
+		t.Fatalf("NewDeviationChecker: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := checker.rule(deviationUpdate("0xa", tt.key, 1, big.NewInt(1)))
+			if rule.Decimals != tt.decimals || rule.MaxJumpPercent != tt.jump || rule.MaxReferenceDeviationPercent != tt.reference {
+				t.Errorf("got rule %+v", rule)
+			}
+		})
+	}
+}
+
+func TestDeviationCheck(t *testing.T) {
+	e18 := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	price := func(units int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(units), e18)
+	}
+
+	tests := []struct {
+		name     string
+		previous *big.Int
+		update   helpers.OracleMetrics
+		findings []string
+	}{
+		{"valid update", price(100), deviationUpdate("0xb", "BTC/USD", 2, price(100)), nil},
+		{"zero value", price(100), deviationUpdate("0xb", "BTC/USD", 2, big.NewInt(0)), []string{KindSanity}},
+		{"jump", price(100), deviationUpdate("0xb", "BTC/USD", 2, price(120)), []string{KindJump, KindReference}},
+		{"jump within the key limit", price(100), deviationUpdate("0xb", "ETH/USD", 2, price(120)), nil},
+		{"first update", nil, deviationUpdate("0xb", "BTC/USD", 2, price(100)), nil},
+		{"off the reference", price(102), deviationUpdate("0xb", "BTC/USD", 2, price(103)), []string{KindReference}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, deviationSeed)
+			if tt.previous != nil {
+				if err := db.InsertOracleMetricsBatch([]helpers.OracleMetrics{deviationUpdate("0xa", tt.update.AssetKey, 1, tt.previous)}); err != nil {
+					t.Fatalf("InsertOracleMetricsBatch: %v", err)
+				}
+			}
+			manager := newTestManager(t, db)
+			checker, err := NewDeviationChecker(db, manager, fixedReference(100))
+			if err != nil {
+				t.Fatalf("NewDeviationChecker: %v", err)
+			}
+
+			if err := checker.check(context.Background(), tt.update); err != nil {
+				t.Fatalf("check: %v", err)
+			}
+
+			active := manager.Active()
+			if len(active) != len(tt.findings) {
+				t.Fatalf("got alerts %+v, want %v", active, tt.findings)
+			}
+			for _, kind := range tt.findings {
+				severity := SeverityWarning
+				if kind == KindSanity {
+					severity = SeverityCritical
+				}
+				alert, ok := manager.Get(kind + ":" + updateKey("1", deviationOracle.Hex(), tt.update.AssetKey))
+				if !ok {
+					t.Errorf("no %s alert", kind)
+				} else if alert.Severity != severity {
+					t.Errorf("%s alert has severity %s, want %s", kind, alert.Severity, severity)
+				}
+			}
+
+			// a later valid update resolves the alerts
+			if err := checker.check(context.Background(), deviationUpdate("0xc", tt.update.AssetKey, 3, price(100))); err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			if active := manager.Active(); len(active) != 0 {
+				t.Errorf("alerts %+v still firing after a valid update", active)
+			}
+		})
+	}
+}
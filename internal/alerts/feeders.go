@@ -0,0 +1,222 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const (
+	KindUnknownFeeder = "unknown_feeder"
+	KindNewFeeder     = "new_feeder"
+
+	// how long after its latest update an unexpected sender keeps its alert
+	// firing, and after its first update a new wallet
+	DefaultFeederWindow = 24 * time.Hour
+)
+
+// FeederSource provides the expected updaters of the oracles and the wallets
+// that actually updated them.
+type FeederSource interface {
+	SelectAuthorizedUpdaters(chainID string, oracleAddress string) ([]helpers.AuthorizedUpdater, error)
+	SelectUpdaterAllowlist() ([]helpers.AllowedUpdater, error)
+	SelectOracleSenders(chainID string, oracleAddress string) ([]helpers.OracleSender, error)
+}
+
+// UpdaterAllowlist holds the expected updaters of the oracles: those their
+// access control events authorized together with the configured ones.
+type UpdaterAllowlist struct {
+	updaters map[string]map[string]bool
+	shared   []helpers.AllowedUpdater
+}
+
+func NewUpdaterAllowlist(authorized []helpers.AuthorizedUpdater, configured []helpers.AllowedUpdater) *UpdaterAllowlist {
+	allowlist := &UpdaterAllowlist{updaters: make(map[string]map[string]bool)}
+	add := func(chainID, oracle, updater string) {
+		key := chainID + ":" + strings.ToLower(oracle)
+		if allowlist.updaters[key] == nil {
+			allowlist.updaters[key] = make(map[string]bool)
+		}
+		allowlist.updaters[key][strings.ToLower(updater)] = true
+	}
+
+	for _, u := range authorized {
+		add(u.ChainID, u.OracleAddress, u.Updater)
+	}
+	for _, u := range configured {
+		if u.ChainID == "" || u.OracleAddress == "" {
+			allowlist.shared = append(allowlist.shared, u)
+			continue
+		}
+		add(u.ChainID, u.OracleAddress, u.Updater)
+	}
+	return allowlist
+}
+
+// Covers tells whether any updater is expected for the oracle. Senders of
+// oracles it does not cover cannot be judged.
+func (a *UpdaterAllowlist) Covers(chainID, oracle string) bool {
+	if len(a.updaters[chainID+":"+strings.ToLower(oracle)]) > 0 {
+		return true
+	}
+	for _, u := range a.shared {
+		if u.ChainID == "" || u.ChainID == chainID {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows tells whether sender is an expected updater of the oracle.
+func (a *UpdaterAllowlist) Allows(chainID, oracle, sender string) bool {
+	if a.updaters[chainID+":"+strings.ToLower(oracle)][strings.ToLower(sender)] {
+		return true
+	}
+	for _, u := range a.shared {
+		if (u.ChainID == "" || u.ChainID == chainID) && (u.OracleAddress == "" || strings.EqualFold(u.OracleAddress, oracle)) && strings.EqualFold(u.Updater, sender) {
+			return true
+		}
+	}
+	return false
+}
+
+// FeederChecker compares the sender of every update with the expected
+// updaters of the oracle. It alerts on updates from an address outside the
+// allowlist and, as a warning, on an oracle whose updates start coming from
+// a wallet it never saw before.
+type FeederChecker struct {
+	source  FeederSource
+	manager *Manager
+	window  time.Duration
+
+	mu      sync.Mutex
+	senders map[string]map[string]*helpers.OracleSender
+}
+
+// NewFeederChecker loads the senders seen so far, later ones are observed.
+func NewFeederChecker(source FeederSource, manager *Manager, window time.Duration) (*FeederChecker, error) {
+	senders, err := source.SelectOracleSenders("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &FeederChecker{
+		source:  source,
+		manager: manager,
+		window:  window,
+		senders: make(map[string]map[string]*helpers.OracleSender),
+	}
+	for i := range senders {
+		c.add(senders[i])
+	}
+	return c, nil
+}
+
+func (c *FeederChecker) Name() string {
+	return "feeders"
+}
+
+// add records a sender. The caller holds the lock or owns the checker.
+func (c *FeederChecker) add(sender helpers.OracleSender) {
+	oracle := fmt.Sprintf("%s:%s", sender.ChainID, strings.ToLower(sender.OracleAddress))
+	if c.senders[oracle] == nil {
+		c.senders[oracle] = make(map[string]*helpers.OracleSender)
+	}
+
+	known, ok := c.senders[oracle][strings.ToLower(sender.Sender)]
+	if !ok {
+		c.senders[oracle][strings.ToLower(sender.Sender)] = &sender
+		return
+	}
+	known.Updates += sender.Updates
+	if sender.FirstUpdate.Before(known.FirstUpdate) {
+		known.FirstUpdate = sender.FirstUpdate
+	}
+	if sender.LastUpdate.After(known.LastUpdate) {
+		known.LastUpdate = sender.LastUpdate
+	}
+}
+
+// Observe records the sender of a live update.
+func (c *FeederChecker) Observe(metrics helpers.OracleMetrics) {
+	if metrics.Removed || metrics.AssetKey == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(helpers.OracleSender{
+		ChainID:       metrics.ChainID,
+		OracleAddress: metrics.TransactionTo.Hex(),
+		Sender:        metrics.TransactionFrom.Hex(),
+		Updates:       1,
+		FirstUpdate:   metrics.BlockTimestamp,
+		LastUpdate:    metrics.BlockTimestamp,
+	})
+}
+
+// Evaluate reloads the allowlist and judges the senders active within the
+// window. Oracles without any expected updater are not judged.
+func (c *FeederChecker) Evaluate(now time.Time) error {
+	authorized, err := c.source.SelectAuthorizedUpdaters("", "")
+	if err != nil {
+		return err
+	}
+	configured, err := c.source.SelectUpdaterAllowlist()
+	if err != nil {
+		return err
+	}
+	allowlist := NewUpdaterAllowlist(authorized, configured)
+	since := now.Add(-c.window)
+
+	var unknown, changed []helpers.Alert
+	c.mu.Lock()
+	for _, senders := range c.senders {
+		for _, sender := range senders {
+			if sender.LastUpdate.Before(since) {
+				continue
+			}
+			key := fmt.Sprintf("%s:%s:%s", sender.ChainID, strings.ToLower(sender.OracleAddress), strings.ToLower(sender.Sender))
+
+			if allowlist.Covers(sender.ChainID, sender.OracleAddress) && !allowlist.Allows(sender.ChainID, sender.OracleAddress, sender.Sender) {
+				unknown = append(unknown, helpers.Alert{
+					Key:           KindUnknownFeeder + ":" + key,
+					Severity:      SeverityCritical,
+					ChainID:       sender.ChainID,
+					OracleAddress: sender.OracleAddress,
+					Message:       fmt.Sprintf("%d updates from %s which is no expected updater, latest at %s", sender.Updates, sender.Sender, sender.LastUpdate.UTC().Format(time.RFC3339)),
+				})
+			}
+
+			if !sender.FirstUpdate.Before(since) && hasEarlierSender(senders, sender) {
+				changed = append(changed, helpers.Alert{
+					Key:           KindNewFeeder + ":" + key,
+					Severity:      SeverityWarning,
+					ChainID:       sender.ChainID,
+					OracleAddress: sender.OracleAddress,
+					Message:       fmt.Sprintf("updates come from new wallet %s since %s", sender.Sender, sender.FirstUpdate.UTC().Format(time.RFC3339)),
+				})
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if err := c.manager.Reconcile(KindUnknownFeeder, unknown); err != nil {
+		return err
+	}
+	return c.manager.Reconcile(KindNewFeeder, changed)
+}
+
+// hasEarlierSender tells whether another wallet updated the oracle before
+// sender did.
+func hasEarlierSender(senders map[string]*helpers.OracleSender, sender *helpers.OracleSender) bool {
+	for _, other := range senders {
+		if other != sender && other.FirstUpdate.Before(sender.FirstUpdate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,354 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
+)
+
+const KindStateMismatch = "state_mismatch"
+
+const (
+	// time between two reconciliations unless RECONCILE_INTERVAL is set
+	DefaultReconcileInterval = 15 * time.Minute
+	// largest block range a single targeted backfill covers
+	maxRepairRange = 100000
+	// time allowed for the view calls of one chain
+	reconcileTimeout = 5 * time.Minute
+	// repairs waiting for a backfill before new ones are dropped
+	repairQueueSize = 64
+)
+
+// ContractReader calls the views of contracts on a chain.
+type ContractReader interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// StateSource provides the latest stored value of every key of an oracle and
+// the checkpoint up to which the scanner stored every update of a chain.
+type StateSource interface {
+	SelectLatestValues(chainID string, oracleAddress string) ([]helpers.OracleMetrics, error)
+	GetState(chainID string) (helpers.OracleMetricsState, error)
+}
+
+// OracleLister returns the monitored oracles of a chain.
+type OracleLister func(chainID string) ([]helpers.Oracle, error)
+
+// Repair is a block range of an oracle that should hold missing updates and
+// has to be scanned again.
+type Repair struct {
+	ChainID  string
+	Oracle   common.Address
+	From, To uint64
+}
+
+// stateMismatch is a difference between an oracle and the stored updates,
+// together with the block range that should hold the missing updates.
+type stateMismatch struct {
+	alert    helpers.Alert
+	oracle   common.Address
+	from, to uint64
+}
+
+// StateChecker reconciles the stored updates with the state of the oracle
+// contracts. Every interval it reads the block of the latest update and the
+// value of every known key at the scanner checkpoint, or confirmations below
+// the head when the checkpoint is ahead, and compares them with the latest
+// stored rows. Differences mean missed events, reorgs or scraper bugs. A
+// difference alerts once it is seen by two consecutive reconciliations, so
+// updates still on their way to the database do not fire, and when backfill
+// is set the block range that should hold the missing updates is sent to
+// Repairs.
+type StateChecker struct {
+	source        StateSource
+	manager       *Manager
+	oracles       OracleLister
+	clients       map[string]ContractReader
+	confirmations map[string]uint64
+	interval      time.Duration
+	backfill      bool
+	pending       map[string]bool
+	repairs       chan Repair
+}
+
+func NewStateChecker(source StateSource, manager *Manager, oracles OracleLister, clients map[string]ContractReader, confirmations map[string]uint64, interval time.Duration, backfill bool) *StateChecker {
+	return &StateChecker{
+		source:        source,
+		manager:       manager,
+		oracles:       oracles,
+		clients:       clients,
+		confirmations: confirmations,
+		interval:      interval,
+		backfill:      backfill,
+		pending:       make(map[string]bool),
+		repairs:       make(chan Repair, repairQueueSize),
+	}
+}
+
+// Repairs receives the block ranges to scan again when backfill is set.
+func (c *StateChecker) Repairs() <-chan Repair {
+	return c.repairs
+}
+
+// Run reconciles every oracle right away and then every interval until ctx
+// is cancelled.
+func (c *StateChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.reconcile(ctx); err != nil {
+			log.Printf("failed to evaluate %s alerts: %v", KindStateMismatch, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *StateChecker) reconcile(ctx context.Context) error {
+	var mismatches []stateMismatch
+	var errs []error
+	for chainID, client := range c.clients {
+		found, err := c.reconcileChain(ctx, chainID, client)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chain %s: %v", chainID, err))
+		}
+		mismatches = append(mismatches, found...)
+	}
+	if ctx.Err() != nil {
+		return errors.Join(errs...)
+	}
+
+	var firing []helpers.Alert
+	repairs := make(map[string]stateMismatch)
+	pending := make(map[string]bool, len(mismatches))
+	for _, m := range mismatches {
+		pending[m.alert.Key] = true
+		if !c.pending[m.alert.Key] {
+			continue
+		}
+
+		firing = append(firing, m.alert)
+		metrics.StateMismatches.WithLabelValues(m.alert.ChainID, strings.ToLower(m.alert.OracleAddress)).Inc()
+		log.Printf("state mismatch on oracle %s chainid %s: %s", m.alert.OracleAddress, m.alert.ChainID, m.alert.Message)
+
+		key := m.alert.ChainID + ":" + strings.ToLower(m.alert.OracleAddress)
+		if repair, ok := repairs[key]; ok {
+			if m.from < repair.from {
+				repair.from = m.from
+			}
+			if m.to > repair.to {
+				repair.to = m.to
+			}
+			m = repair
+		}
+		repairs[key] = m
+	}
+	c.pending = pending
+
+	if c.backfill {
+		for _, m := range repairs {
+			c.requestRepair(m)
+		}
+	}
+
+	if err := c.manager.Reconcile(KindStateMismatch, firing); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// reconcileChain compares every oracle of a chain at the block its stored
+// updates are complete up to, within reconcileTimeout.
+func (c *StateChecker) reconcileChain(ctx context.Context, chainID string, client ContractReader) ([]stateMismatch, error) {
+	oracles, err := c.oracles(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the head: %v", err)
+	}
+	state, err := c.source.GetState(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the checkpoint: %v", err)
+	}
+
+	// the listener stores updates past the checkpoint before they are
+	// confirmed, compare where both scanners are done
+	at := state.LastBlock
+	if confirmations := c.confirmations[chainID]; head < confirmations {
+		at = 0
+	} else if head-confirmations < at {
+		at = head - confirmations
+	}
+	if at == 0 {
+		return nil, nil
+	}
+
+	var mismatches []stateMismatch
+	for _, oracle := range oracles {
+		found, err := c.reconcileOracle(ctx, chainID, client, oracle, at)
+		if err != nil {
+			log.Printf("failed to reconcile oracle %s chainid %s: %v", oracle.ContractAddress.Hex(), chainID, err)
+			if ctx.Err() != nil {
+				return mismatches, err
+			}
+			continue
+		}
+		mismatches = append(mismatches, found...)
+	}
+	return mismatches, nil
+}
+
+// reconcileOracle compares an oracle at block at with its latest stored rows.
+// Oracles without stored updates are left to the scanner.
+func (c *StateChecker) reconcileOracle(ctx context.Context, chainID string, client ContractReader, oracle helpers.Oracle, at uint64) ([]stateMismatch, error) {
+	contract := oracle.Contract
+	if contract.ValueMethod == "" && contract.LastUpdateBlockMethod == "" {
+		return nil, nil
+	}
+
+	stored, err := c.source.SelectLatestValues(chainID, oracle.ContractAddress.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 {
+		return nil, nil
+	}
+
+	address := oracle.ContractAddress.Hex()
+	alertKey := fmt.Sprintf("%s:%s:%s", KindStateMismatch, chainID, strings.ToLower(address))
+	block := new(big.Int).SetUint64(at)
+
+	var latest uint64
+	blocks := make([]uint64, len(stored))
+	for i, row := range stored {
+		blocks[i], _ = strconv.ParseUint(row.BlockNumber, 10, 64)
+		if blocks[i] > latest && blocks[i] <= at {
+			latest = blocks[i]
+		}
+	}
+
+	var mismatches []stateMismatch
+	if contract.LastUpdateBlockMethod != "" {
+		out, err := callView(ctx, client, oracle, contract.LastUpdateBlockMethod, block)
+		if err != nil {
+			return nil, err
+		}
+		onchain, ok := out[0].(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %s result", contract.LastUpdateBlockMethod)
+		}
+		if onchain.IsUint64() && onchain.Uint64() > latest {
+			mismatches = append(mismatches, stateMismatch{
+				alert: helpers.Alert{
+					Key:           alertKey,
+					Severity:      SeverityCritical,
+					ChainID:       chainID,
+					OracleAddress: address,
+					Message:       fmt.Sprintf("oracle was last updated in block %d, the latest stored update is in block %d", onchain.Uint64(), latest),
+				},
+				oracle: oracle.ContractAddress,
+				from:   latest + 1,
+				to:     onchain.Uint64(),
+			})
+		}
+	}
+
+	if contract.ValueMethod != "" {
+		for i, row := range stored {
+			if blocks[i] > at {
+				// stored after the block the views were read at
+				continue
+			}
+
+			out, err := callView(ctx, client, oracle, contract.ValueMethod, block, row.AssetKey)
+			if err != nil {
+				return nil, err
+			}
+			onchain, ok := out[0].(*big.Int)
+			if !ok {
+				return nil, fmt.Errorf("unexpected %s result", contract.ValueMethod)
+			}
+			if row.AssetPrice != nil && onchain.Cmp(row.AssetPrice) == 0 {
+				continue
+			}
+
+			storedValue := "none"
+			if row.AssetPrice != nil {
+				storedValue = row.AssetPrice.String()
+			}
+			mismatches = append(mismatches, stateMismatch{
+				alert: helpers.Alert{
+					Key:           alertKey + ":" + row.AssetKey,
+					Severity:      SeverityCritical,
+					ChainID:       chainID,
+					OracleAddress: address,
+					AssetKey:      row.AssetKey,
+					Message:       fmt.Sprintf("on-chain value %s differs from the value %s stored in block %d", onchain, storedValue, blocks[i]),
+				},
+				oracle: oracle.ContractAddress,
+				from:   blocks[i],
+				to:     at,
+			})
+		}
+	}
+
+	return mismatches, nil
+}
+
+// requestRepair queues the block range of a mismatch, dropping it when the
+// queue is full as the next reconciliation finds it again.
+func (c *StateChecker) requestRepair(m stateMismatch) {
+	if m.to-m.from+1 > maxRepairRange {
+		log.Printf("limiting backfill of oracle %s chainid %s to the last %d blocks before %d", m.alert.OracleAddress, m.alert.ChainID, maxRepairRange, m.to)
+		m.from = m.to - maxRepairRange + 1
+	}
+
+	select {
+	case c.repairs <- Repair{ChainID: m.alert.ChainID, Oracle: m.oracle, From: m.from, To: m.to}:
+	default:
+		log.Printf("repair queue full, skipping backfill of oracle %s chainid %s", m.alert.OracleAddress, m.alert.ChainID)
+	}
+}
+
+// callView calls a view of an oracle at a block and decodes its outputs.
+func callView(ctx context.Context, client ContractReader, oracle helpers.Oracle, method string, block *big.Int, args ...interface{}) ([]interface{}, error) {
+	data, err := oracle.Contract.ABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %v", method, err)
+	}
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &oracle.ContractAddress, Data: data}, block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %v", method, err)
+	}
+	values, err := oracle.Contract.ABI.Unpack(method, out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %v", method, err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s returned nothing", method)
+	}
+	return values, nil
+}
@@ -0,0 +1,92 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReferencePriceProvider returns an independent price for an asset key.
+type ReferencePriceProvider interface {
+	Price(ctx context.Context, assetKey string) (float64, error)
+}
+
+type cachedPrice struct {
+	price     float64
+	fetchedAt time.Time
+}
+
+// HTTPReferenceProvider reads prices from a JSON HTTP endpoint. The {key}
+// placeholder of URLTemplate is replaced by the escaped asset key and the
+// price is read from the top level field Field, as a number or a string.
+type HTTPReferenceProvider struct {
+	URLTemplate string
+	Field       string
+	TTL         time.Duration
+	Client      *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedPrice
+}
+
+func NewHTTPReferenceProvider(urlTemplate, field string) *HTTPReferenceProvider {
+	return &HTTPReferenceProvider{
+		URLTemplate: urlTemplate,
+		Field:       field,
+		TTL:         30 * time.Second,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		cache:       make(map[string]cachedPrice),
+	}
+}
+
+func (p *HTTPReferenceProvider) Price(ctx context.Context, assetKey string) (float64, error) {
+	p.mu.Lock()
+	cached, ok := p.cache[assetKey]
+	p.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < p.TTL {
+		return cached.price, nil
+	}
+
+	endpoint := strings.ReplaceAll(p.URLTemplate, "{key}", url.PathEscape(assetKey))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build the reference request: %v", err)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch the reference price: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("reference endpoint answered with status %s", resp.Status)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode the reference price: %v", err)
+	}
+
+	raw, ok := body[p.Field]
+	if !ok {
+		return 0, fmt.Errorf("reference response has no field %s", p.Field)
+	}
+
+	price, err := strconv.ParseFloat(strings.Trim(string(raw), `"`), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the reference price: %v", err)
+	}
+
+	p.mu.Lock()
+	p.cache[assetKey] = cachedPrice{price: price, fetchedAt: time.Now()}
+	p.mu.Unlock()
+
+	return price, nil
+}
@@ -0,0 +1,73 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const (
+	KindReverts = "reverts"
+
+	// reverts of one feeder to one oracle within DefaultRevertWindow that fire
+	// an alert unless REVERT_ALERT_THRESHOLD is set
+	DefaultRevertThreshold = 3
+	DefaultRevertWindow    = time.Hour
+)
+
+// FailureSource provides the reverted transactions sent to the oracles.
+type FailureSource interface {
+	SelectFailureSummary(since time.Time) ([]helpers.FailureSummary, error)
+}
+
+// RevertChecker alerts when a feeder keeps sending transactions that revert,
+// usually because its key was removed from the updaters or its nonce is stuck.
+// Such feeds go stale while the feeder still burns gas.
+type RevertChecker struct {
+	source    FailureSource
+	manager   *Manager
+	threshold int
+	window    time.Duration
+}
+
+func NewRevertChecker(source FailureSource, manager *Manager, threshold int, window time.Duration) *RevertChecker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &RevertChecker{source: source, manager: manager, threshold: threshold, window: window}
+}
+
+func (c *RevertChecker) Name() string {
+	return KindReverts
+}
+
+// Evaluate counts the reverts of every feeder and oracle within the window.
+func (c *RevertChecker) Evaluate(now time.Time) error {
+	summaries, err := c.source.SelectFailureSummary(now.Add(-c.window))
+	if err != nil {
+		return err
+	}
+
+	var firing []helpers.Alert
+	for _, summary := range summaries {
+		if summary.Failures < uint64(c.threshold) {
+			continue
+		}
+
+		reason := summary.LastReason
+		if reason == "" {
+			reason = "unknown reason"
+		}
+		firing = append(firing, helpers.Alert{
+			Key:           fmt.Sprintf("%s:%s:%s:%s", KindReverts, summary.ChainID, strings.ToLower(summary.OracleAddress), strings.ToLower(summary.Sender)),
+			Severity:      SeverityCritical,
+			ChainID:       summary.ChainID,
+			OracleAddress: summary.OracleAddress,
+			Message:       fmt.Sprintf("%d transactions from %s reverted in the last %s, latest: %s", summary.Failures, summary.Sender, c.window, reason),
+		})
+	}
+
+	return c.manager.Reconcile(KindReverts, firing)
+}
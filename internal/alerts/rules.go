@@ -0,0 +1,37 @@
+package alerts
+
+import "strings"
+
+// matchRule returns the most specific rule whose scope matches the chain,
+// oracle and asset key, the first one of equally specific rules. An empty
+// scope field matches anything.
+func matchRule[T any](rules []T, scope func(T) (string, string, string), chainID, oracle, assetKey string) (T, bool) {
+	var match T
+	best := -1
+
+	for _, rule := range rules {
+		ruleChain, ruleOracle, ruleKey := scope(rule)
+		if ruleChain != "" && ruleChain != chainID {
+			continue
+		}
+		if ruleOracle != "" && !strings.EqualFold(ruleOracle, oracle) {
+			continue
+		}
+		if ruleKey != "" && ruleKey != assetKey {
+			continue
+		}
+
+		specificity := 0
+		for _, field := range []string{ruleChain, ruleOracle, ruleKey} {
+			if field != "" {
+				specificity++
+			}
+		}
+		if specificity > best {
+			best = specificity
+			match = rule
+		}
+	}
+
+	return match, best >= 0
+}
@@ -0,0 +1,50 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+func TestMatchHeartbeat(t *testing.T) {
+	const (
+		oracle = "0xAbC0000000000000000000000000000000000001"
+		other  = "0x3000000000000000000000000000000000000003"
+	)
+
+	rules := []helpers.HeartbeatRule{
+		{Interval: time.Hour},
+		{ChainID: "1", Interval: 30 * time.Minute},
+		{ChainID: "1", OracleAddress: oracle, Interval: 10 * time.Minute},
+		{ChainID: "1", OracleAddress: oracle, AssetKey: "BTC/USD", Interval: time.Minute},
+		// a catalogue rule as specific as the configured one above
+		{ChainID: "1", OracleAddress: oracle, AssetKey: "BTC/USD", Interval: 2 * time.Minute},
+		{ChainID: "2", AssetKey: "ETH/USD", Interval: 0},
+	}
+
+	tests := []struct {
+		name   string
+		rules  []helpers.HeartbeatRule
+		update helpers.AssetUpdate
+		want   time.Duration
+		ok     bool
+	}{
+		{"asset rule", rules, helpers.AssetUpdate{ChainID: "1", OracleAddress: oracle, AssetKey: "BTC/USD"}, time.Minute, true},
+		{"address case", rules, helpers.AssetUpdate{ChainID: "1", OracleAddress: "0xabc0000000000000000000000000000000000001", AssetKey: "BTC/USD"}, time.Minute, true},
+		{"oracle rule", rules, helpers.AssetUpdate{ChainID: "1", OracleAddress: oracle, AssetKey: "ETH/USD"}, 10 * time.Minute, true},
+		{"chain rule", rules, helpers.AssetUpdate{ChainID: "1", OracleAddress: other, AssetKey: "BTC/USD"}, 30 * time.Minute, true},
+		{"default rule", rules, helpers.AssetUpdate{ChainID: "3", OracleAddress: other, AssetKey: "BTC/USD"}, time.Hour, true},
+		{"disabled by a zero interval", rules, helpers.AssetUpdate{ChainID: "2", OracleAddress: other, AssetKey: "ETH/USD"}, 0, false},
+		{"no rules", nil, helpers.AssetUpdate{ChainID: "1", OracleAddress: oracle, AssetKey: "BTC/USD"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := MatchHeartbeat(tt.rules, tt.update)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got %s %t, want %s %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const KindStale = "stale"
+
+// UpdateSource provides the latest update of every asset key together with
+// the heartbeat configuration.
+type UpdateSource interface {
+	SelectLatestUpdates() ([]helpers.AssetUpdate, error)
+	SelectHeartbeatRules() ([]helpers.HeartbeatRule, error)
+}
+
+// StalenessChecker alerts on asset keys whose last on-chain update is older
+// than their heartbeat interval.
+type StalenessChecker struct {
+	source   UpdateSource
+	manager  *Manager
+	mu       sync.Mutex
+	observed map[string]helpers.AssetUpdate
+}
+
+func NewStalenessChecker(source UpdateSource, manager *Manager) *StalenessChecker {
+	return &StalenessChecker{
+		source:   source,
+		manager:  manager,
+		observed: make(map[string]helpers.AssetUpdate),
+	}
+}
+
+func (c *StalenessChecker) Name() string {
+	return KindStale
+}
+
+func updateKey(chainID, oracle, assetKey string) string {
+	return fmt.Sprintf("%s:%s:%s", chainID, strings.ToLower(oracle), assetKey)
+}
+
+// Observe records live updates so a recovered feed resolves on the next
+// evaluation without waiting for the database.
+func (c *StalenessChecker) Observe(metrics helpers.OracleMetrics) {
+	if metrics.Removed || metrics.AssetKey == "" {
+		return
+	}
+
+	update := helpers.AssetUpdate{
+		ChainID:       metrics.ChainID,
+		OracleAddress: metrics.TransactionTo.Hex(),
+		AssetKey:      metrics.AssetKey,
+		UpdateTime:    metrics.BlockTimestamp,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := updateKey(update.ChainID, update.OracleAddress, update.AssetKey)
+	if update.UpdateTime.After(c.observed[key].UpdateTime) {
+		c.observed[key] = update
+	}
+}
+
+// MatchHeartbeat returns the interval of the most specific rule matching the
+// update.
+func MatchHeartbeat(rules []helpers.HeartbeatRule, update helpers.AssetUpdate) (time.Duration, bool) {
+	rule, ok := matchRule(rules, func(r helpers.HeartbeatRule) (string, string, string) {
+		return r.ChainID, r.OracleAddress, r.AssetKey
+	}, update.ChainID, update.OracleAddress, update.AssetKey)
+
+	return rule.Interval, ok && rule.Interval > 0
+}
+
+// Evaluate compares every asset key against its heartbeat.
+func (c *StalenessChecker) Evaluate(now time.Time) error {
+	updates, err := c.source.SelectLatestUpdates()
+	if err != nil {
+		return err
+	}
+	rules, err := c.source.SelectHeartbeatRules()
+	if err != nil {
+		return err
+	}
+
+	latest := make(map[string]helpers.AssetUpdate, len(updates))
+	for _, update := range updates {
+		latest[updateKey(update.ChainID, update.OracleAddress, update.AssetKey)] = update
+	}
+
+	c.mu.Lock()
+	for key, update := range c.observed {
+		if update.UpdateTime.After(latest[key].UpdateTime) {
+			latest[key] = update
+		}
+	}
+	c.mu.Unlock()
+
+	var firing []helpers.Alert
+	for key, update := range latest {
+		heartbeat, ok := MatchHeartbeat(rules, update)
+		if !ok {
+			continue
+		}
+
+		age := now.Sub(update.UpdateTime)
+		if age <= heartbeat {
+			continue
+		}
+
+		firing = append(firing, helpers.Alert{
+			Key:           KindStale + ":" + key,
+			Severity:      SeverityCritical,
+			ChainID:       update.ChainID,
+			OracleAddress: update.OracleAddress,
+			AssetKey:      update.AssetKey,
+			Message:       fmt.Sprintf("no update for %s, heartbeat is %s", age.Truncate(time.Second), heartbeat),
+		})
+	}
+
+	return c.manager.Reconcile(KindStale, firing)
+}
@@ -0,0 +1,74 @@
+package alerts
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const stalenessSeed = `{
+	"heartbeat-rules": [{"ChainID": "1", "Interval": 600000000000}],
+	"asset-catalogue": [
+		{"ChainID": "1", "OracleAddress": "0x1000000000000000000000000000000000000001", "AssetKey": "BTC/USD", "Heartbeat": 60000000000}
+	]
+}`
+
+func TestStalenessChecker(t *testing.T) {
+	oracle := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	update := func(key string, age time.Duration) helpers.OracleMetrics {
+		return helpers.OracleMetrics{
+			TransactionMetadata: helpers.TransactionMetadata{
+				BlockNumber:     strconv.FormatInt(now.Add(-age).Unix(), 10),
+				ChainID:         "1",
+				BlockTimestamp:  now.Add(-age),
+				TransactionTo:   oracle,
+				TransactionHash: key + age.String(),
+			},
+			AssetKey: key,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		stored   []helpers.OracleMetrics
+		observed []helpers.OracleMetrics
+		stale    []string
+	}{
+		{"fresh", []helpers.OracleMetrics{update("BTC/USD", 30*time.Second), update("ETH/USD", 5*time.Minute)}, nil, nil},
+		{"past the catalogue heartbeat", []helpers.OracleMetrics{update("BTC/USD", 2*time.Minute)}, nil, []string{"BTC/USD"}},
+		{"past the chain heartbeat", []helpers.OracleMetrics{update("ETH/USD", 11*time.Minute)}, nil, []string{"ETH/USD"}},
+		{"recovered by an observed update", []helpers.OracleMetrics{update("BTC/USD", 2*time.Minute)}, []helpers.OracleMetrics{update("BTC/USD", 10*time.Second)}, nil},
+		{"observed removal ignored", []helpers.OracleMetrics{update("BTC/USD", 2*time.Minute)}, []helpers.OracleMetrics{{TransactionMetadata: update("BTC/USD", 0).TransactionMetadata, AssetKey: "BTC/USD", Removed: true}}, []string{"BTC/USD"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, stalenessSeed)
+			if err := db.InsertOracleMetricsBatch(tt.stored); err != nil {
+				t.Fatalf("InsertOracleMetricsBatch: %v", err)
+			}
+			manager := newTestManager(t, db)
+			checker := NewStalenessChecker(db, manager)
+			for _, metrics := range tt.observed {
+				checker.Observe(metrics)
+			}
+
+			if err := checker.Evaluate(now); err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if active := manager.Active(); len(active) != len(tt.stale) {
+				t.Fatalf("got alerts %+v, want %v stale", active, tt.stale)
+			}
+			for _, key := range tt.stale {
+				if _, ok := manager.Get(KindStale + ":" + updateKey("1", oracle.Hex(), key)); !ok {
+					t.Errorf("%s is not stale", key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/alerts"
+	"github.com/diadata-org/oracle-monitoring/internal/database"
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const (
+	prefix = "/api/v1/chains"
+	// page size of the update history unless the request sets limit
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// StatusReporter is a running scraper whose health the API reports.
+type StatusReporter interface {
+	Reconnects() uint64
+	EndpointStatus() []helpers.EndpointStatus
+}
+
+type reporter struct {
+	kind     string
+	reporter StatusReporter
+}
+
+// Server answers read-only queries over the collected oracle metrics.
+type Server struct {
+	db database.Database
+
+	mu        sync.Mutex
+	reporters map[string][]reporter
+}
+
+func NewServer(db database.Database) *Server {
+	return &Server{db: db, reporters: make(map[string][]reporter)}
+}
+
+// Register adds a scraper of the given kind to the status of its chain.
+func (s *Server) Register(chainID string, kind string, r StatusReporter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reporters[chainID] = append(s.reporters[chainID], reporter{kind: kind, reporter: r})
+}
+
+// HTTPServer returns a server answering the API at addr.
+func (s *Server) HTTPServer(addr string) *http.Server {
+	return &http.Server{Addr: addr, Handler: s}
+}
+
+// ServeHTTP routes
+//
+//	GET /api/v1/chains
+//	GET /api/v1/chains/{chain}/status
+//	GET /api/v1/chains/{chain}/oracles
+//	GET /api/v1/chains/{chain}/oracles/{address}/values
+//	GET /api/v1/chains/{chain}/oracles/{address}/updates
+//	GET /api/v1/chains/{chain}/oracles/{address}/cost
+//	GET /api/v1/chains/{chain}/oracles/{address}/access
+//	GET /api/v1/chains/{chain}/oracles/{address}/assets
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported")
+		return
+	}
+
+	if r.URL.Path != prefix && !strings.HasPrefix(r.URL.Path, prefix+"/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	switch {
+	case len(parts) == 0:
+		s.chains(w, r)
+	case len(parts) == 2 && parts[1] == "status":
+		s.status(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "oracles":
+		s.oracles(w, r, parts[0])
+	case len(parts) == 4 && parts[1] == "oracles":
+		if !common.IsHexAddress(parts[2]) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid oracle address %q", parts[2]))
+			return
+		}
+		switch parts[3] {
+		case "values":
+			s.values(w, r, parts[0], parts[2])
+		case "updates":
+			s.updates(w, r, parts[0], parts[2])
+		case "cost":
+			s.cost(w, r, parts[0], parts[2])
+		case "access":
+			s.access(w, r, parts[0], parts[2])
+		case "assets":
+			s.assets(w, r, parts[0], parts[2])
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) chains(w http.ResponseWriter, r *http.Request) {
+	configs, err := s.db.GetChainConfigs([]string{})
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	chainIDs := make([]string, 0, len(configs))
+	for chainID := range configs {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Strings(chainIDs)
+
+	statuses := make([]ChainStatus, 0, len(chainIDs))
+	for _, chainID := range chainIDs {
+		status, err := s.chainStatus(chainID)
+		if err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		statuses = append(statuses, status)
+	}
+
+	writeJSON(w, ChainList{Chains: statuses})
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request, chainID string) {
+	status, err := s.chainStatus(chainID)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, status)
+}
+
+func (s *Server) chainStatus(chainID string) (ChainStatus, error) {
+	state, err := s.db.GetState(chainID)
+	if err != nil {
+		return ChainStatus{}, err
+	}
+	lastUpdate, err := s.db.SelectChainLastUpdate(chainID)
+	if err != nil {
+		return ChainStatus{}, err
+	}
+
+	status := ChainStatus{
+		ChainID:         chainID,
+		CheckpointBlock: state.LastBlock,
+		CheckpointHash:  state.LastBlockHash,
+		LastUpdate:      optionalTime(lastUpdate),
+		Scrapers:        []ScraperStatus{},
+	}
+
+	s.mu.Lock()
+	reporters := append([]reporter(nil), s.reporters[chainID]...)
+	s.mu.Unlock()
+
+	for _, r := range reporters {
+		status.Scrapers = append(status.Scrapers, newScraperStatus(r.kind, r.reporter))
+	}
+	return status, nil
+}
+
+func (s *Server) oracles(w http.ResponseWriter, r *http.Request, chainID string) {
+	targets, err := s.db.SelectOracles(chainID)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	oracles := make([]Oracle, 0, len(targets))
+	for _, target := range targets {
+		oracles = append(oracles, Oracle{
+			ChainID:            target.ChainId,
+			Address:            common.HexToAddress(target.ContractAddress).Hex(),
+			CreationBlock:      target.CreationBlock,
+			LatestScrapedBlock: target.LatestScrapedBlock,
+		})
+	}
+	writeJSON(w, OracleList{Oracles: oracles})
+}
+
+func (s *Server) values(w http.ResponseWriter, r *http.Request, chainID, address string) {
+	updates, err := s.db.SelectLatestValues(chainID, address)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, ValueList{Values: newUpdates(updates)})
+}
+
+func (s *Server) updates(w http.ResponseWriter, r *http.Request, chainID, address string) {
+	query := r.URL.Query()
+	filter := helpers.UpdateFilter{
+		ChainID:       chainID,
+		OracleAddress: address,
+		AssetKey:      query.Get("key"),
+		Limit:         defaultPageSize,
+	}
+
+	var err error
+	if filter.From, filter.To, err = timeRange(r); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.Limit, err = intParam(r, "limit", defaultPageSize); err != nil || filter.Limit < 1 || filter.Limit > maxPageSize {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+		return
+	}
+	if filter.Offset, err = intParam(r, "offset", 0); err != nil || filter.Offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must be a positive number")
+		return
+	}
+
+	updates, err := s.db.SelectUpdates(filter)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, UpdatePage{Updates: newUpdates(updates), Limit: filter.Limit, Offset: filter.Offset})
+}
+
+func (s *Server) cost(w http.ResponseWriter, r *http.Request, chainID, address string) {
+	from, to, err := timeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cost, err := s.db.SelectOracleCost(chainID, address, from, to)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, newCost(cost, from, to))
+}
+
+// access reports the current owner and updaters of an oracle together with
+// the access control events since the optional from parameter and the wallets
+// that updated it, flagged when they are not expected.
+func (s *Server) access(w http.ResponseWriter, r *http.Request, chainID, address string) {
+	from, _, err := timeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the owner is the latest transfer even when it predates from
+	history, err := s.db.SelectAccessEvents(chainID, address, time.Time{})
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	updaters, err := s.db.SelectAuthorizedUpdaters(chainID, address)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	configured, err := s.db.SelectUpdaterAllowlist()
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	senders, err := s.db.SelectOracleSenders(chainID, address)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	var events []helpers.AccessEvent
+	for _, event := range history {
+		if !event.BlockTimestamp.Before(from) {
+			events = append(events, event)
+		}
+	}
+	access := newAccess(chainID, common.HexToAddress(address).Hex(), history, updaters, events)
+	access.Feeders = newFeeders(senders, alerts.NewUpdaterAllowlist(updaters, configured))
+	writeJSON(w, access)
+}
+
+// assets reports the declared and the discovered asset keys of an oracle with
+// their catalogue status.
+func (s *Server) assets(w http.ResponseWriter, r *http.Request, chainID, address string) {
+	entries, err := s.db.SelectAssetCatalogue(chainID, address)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	latest, err := s.db.SelectLatestValues(chainID, address)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	updates := make([]helpers.AssetUpdate, 0, len(latest))
+	for _, u := range latest {
+		block, _ := strconv.ParseUint(u.BlockNumber, 10, 64)
+		updates = append(updates, helpers.AssetUpdate{
+			ChainID:       u.ChainID,
+			OracleAddress: u.TransactionTo.Hex(),
+			AssetKey:      u.AssetKey,
+			UpdateBlock:   block,
+			UpdateTime:    u.BlockTimestamp,
+		})
+	}
+	writeJSON(w, AssetList{Assets: newAssets(alerts.CompareCatalogue(entries, updates))})
+}
+
+// timeRange reads the optional RFC 3339 from and to parameters.
+func timeRange(r *http.Request) (from, to time.Time, err error) {
+	query := r.URL.Query()
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("invalid from %q, expected RFC 3339", v)
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("invalid to %q, expected RFC 3339", v)
+		}
+	}
+	// update_time is stored without a zone, in UTC
+	return from.UTC(), to.UTC(), nil
+}
+
+func intParam(r *http.Request, name string, fallback int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("failed to write the response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Error{Error: msg})
+}
+
+// writeInternalError logs err and hides it from the client.
+func writeInternalError(w http.ResponseWriter, err error) {
+	log.Printf("api: %v", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/database"
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const (
+	testOracle = "0x1000000000000000000000000000000000000001"
+	testFeeder = "0x2000000000000000000000000000000000000002"
+)
+
+const testSeed = `{
+	"chains": [{"chain-id": "1", "rpc": ["http://localhost:8545"]}],
+	"oracles": [{"contract-address": "0x1000000000000000000000000000000000000001", "chain-id": "1", "creation-block": 5}],
+	"updater-allowlist": [{"ChainID": "1", "Updater": "0x2000000000000000000000000000000000000002"}],
+	"asset-catalogue": [
+		{"ChainID": "1", "OracleAddress": "0x1000000000000000000000000000000000000001", "AssetKey": "BTC/USD", "Symbol": "BTC", "Decimals": 8},
+		{"ChainID": "1", "OracleAddress": "0x1000000000000000000000000000000000000001", "AssetKey": "ETH/USD"}
+	]
+}`
+
+// newTestServer serves the API from a memory database holding three updates
+// of BTC/USD and one of XAU/USD and an updater added to the oracle.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	seed := filepath.Join(t.TempDir(), "seed.json")
+	if err := os.WriteFile(seed, []byte(testSeed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db := database.NewMemoryDB(seed, false, 0)
+	if err := db.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	var updates []helpers.OracleMetrics
+	for i, key := range []string{"BTC/USD", "BTC/USD", "BTC/USD", "XAU/USD"} {
+		block := uint64(10 + i)
+		updates = append(updates, helpers.OracleMetrics{
+			TransactionMetadata: helpers.TransactionMetadata{
+				BlockNumber:     strconv.FormatUint(block, 10),
+				ChainID:         "1",
+				BlockTimestamp:  time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC),
+				TransactionFrom: common.HexToAddress(testFeeder),
+				TransactionTo:   common.HexToAddress(testOracle),
+				TransactionHash: "0x" + strconv.Itoa(i),
+				TransactionCost: big.NewInt(1000),
+				GasUsed:         100,
+			},
+			AssetKey:      key,
+			AssetPrice:    big.NewInt(int64(block)),
+			AssetDecimals: 8,
+		})
+	}
+	if err := db.InsertOracleMetricsBatch(updates); err != nil {
+		t.Fatalf("InsertOracleMetricsBatch: %v", err)
+	}
+	err := db.InsertAccessEvents([]helpers.AccessEvent{{
+		ChainID:         "1",
+		OracleAddress:   testOracle,
+		TransactionHash: "0xaccess",
+		BlockNumber:     6,
+		BlockTimestamp:  time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+		Kind:            helpers.AccessUpdaterChange,
+		NewAddress:      testFeeder,
+	}})
+	if err != nil {
+		t.Fatalf("InsertAccessEvents: %v", err)
+	}
+	if err := db.SetState(helpers.OracleMetricsState{ChainID: "1", LastBlock: 13, LastBlockHash: "0xhead"}); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	return NewServer(db)
+}
+
+func TestServeHTTP(t *testing.T) {
+	oracle := prefix + "/1/oracles/" + testOracle
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		status   int
+		contains []string
+	}{
+		{"chains", http.MethodGet, prefix, http.StatusOK, []string{`"chain_id":"1"`, `"checkpoint_block":13`}},
+		{"status", http.MethodGet, prefix + "/1/status", http.StatusOK, []string{`"checkpoint_hash":"0xhead"`, `"last_update":"2024-01-01T00:03:00Z"`}},
+		{"unknown chain status", http.MethodGet, prefix + "/2/status", http.StatusOK, []string{`"last_update":null`}},
+		{"oracles", http.MethodGet, prefix + "/1/oracles", http.StatusOK, []string{`"creation_block":5`, `"latest_scraped_block":13`}},
+		{"values", http.MethodGet, oracle + "/values", http.StatusOK, []string{`"asset_key":"BTC/USD","value":"12"`, `"asset_key":"XAU/USD"`}},
+		{"updates", http.MethodGet, oracle + "/updates?key=BTC/USD&limit=1&offset=1", http.StatusOK, []string{`"transaction_hash":"0x1"`, `"limit":1,"offset":1`}},
+		{"updates in a range", http.MethodGet, oracle + "/updates?from=2024-01-01T00:01:00Z&to=2024-01-01T00:02:00Z", http.StatusOK, []string{`"transaction_hash":"0x1"`}},
+		{"limit too large", http.MethodGet, oracle + "/updates?limit=5000", http.StatusBadRequest, []string{"limit must be between 1 and 1000"}},
+		{"negative offset", http.MethodGet, oracle + "/updates?offset=-1", http.StatusBadRequest, []string{"offset must be a positive number"}},
+		{"invalid from", http.MethodGet, oracle + "/updates?from=yesterday", http.StatusBadRequest, []string{"expected RFC 3339"}},
+		{"cost", http.MethodGet, oracle + "/cost", http.StatusOK, []string{`"updates":4`, `"transactions":4`, `"total_cost":"4000"`, `"average_cost":"1000"`}},
+		{"access", http.MethodGet, oracle + "/access", http.StatusOK, []string{`"address":"` + testFeeder + `"`, `"kind":"updater_change"`, `"expected":true`}},
+		{"assets", http.MethodGet, oracle + "/assets", http.StatusOK, []string{`"asset_key":"BTC/USD","status":"listed"`, `"asset_key":"ETH/USD","status":"missing"`, `"asset_key":"XAU/USD","status":"unlisted"`}},
+		{"invalid address", http.MethodGet, prefix + "/1/oracles/0x12/values", http.StatusBadRequest, []string{"invalid oracle address"}},
+		{"unknown resource", http.MethodGet, oracle + "/owners", http.StatusNotFound, []string{"not found"}},
+		{"outside the API", http.MethodGet, "/metrics", http.StatusNotFound, []string{"not found"}},
+		{"write method", http.MethodPost, prefix, http.StatusMethodNotAllowed, []string{"only GET is supported"}},
+	}
+
+	server := newTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("got content type %q", ct)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body %s does not contain %s", rec.Body, want)
+				}
+			}
+		})
+	}
+}
+
+type testReporter struct{}
+
+func (testReporter) Reconnects() uint64 { return 2 }
+
+func (testReporter) EndpointStatus() []helpers.EndpointStatus {
+	return []helpers.EndpointStatus{{URL: "https://node.example/secret-key", Healthy: true, Latency: 1500 * time.Microsecond}}
+}
+
+func TestStatusReporters(t *testing.T) {
+	server := newTestServer(t)
+	server.Register("1", "events", testReporter{})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, prefix+"/1/status", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{`"kind":"events"`, `"ws_reconnects":2`, `"host":"node.example"`, `"latency_ms":1.5`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body %s does not contain %s", body, want)
+		}
+	}
+	if strings.Contains(body, "secret-key") {
+		t.Errorf("body %s leaks the node URL", body)
+	}
+}
@@ -0,0 +1,314 @@
+package api
+
+import (
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/alerts"
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
+)
+
+// Response bodies. Field names are part of the API and must not change,
+// amounts are decimal strings so they never lose precision, null when the
+// amount is unknown.
+
+type Error struct {
+	Error string `json:"error"`
+}
+
+type ChainList struct {
+	Chains []ChainStatus `json:"chains"`
+}
+
+type ChainStatus struct {
+	ChainID         string          `json:"chain_id"`
+	CheckpointBlock uint64          `json:"checkpoint_block"`
+	CheckpointHash  string          `json:"checkpoint_hash"`
+	LastUpdate      *time.Time      `json:"last_update"`
+	Scrapers        []ScraperStatus `json:"scrapers"`
+}
+
+type ScraperStatus struct {
+	Kind       string           `json:"kind"`
+	Reconnects uint64           `json:"ws_reconnects"`
+	Endpoints  []EndpointStatus `json:"endpoints"`
+}
+
+// EndpointStatus names endpoints by host only, their URLs may carry API keys.
+type EndpointStatus struct {
+	Host      string  `json:"host"`
+	Priority  int     `json:"priority"`
+	Healthy   bool    `json:"healthy"`
+	Head      uint64  `json:"head"`
+	HeadLag   uint64  `json:"head_lag"`
+	Calls     uint64  `json:"calls"`
+	Errors    uint64  `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type OracleList struct {
+	Oracles []Oracle `json:"oracles"`
+}
+
+type Oracle struct {
+	ChainID            string `json:"chain_id"`
+	Address            string `json:"address"`
+	CreationBlock      uint64 `json:"creation_block"`
+	LatestScrapedBlock uint64 `json:"latest_scraped_block"`
+}
+
+type ValueList struct {
+	Values []Update `json:"values"`
+}
+
+type UpdatePage struct {
+	Updates []Update `json:"updates"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+}
+
+type Update struct {
+	ChainID         string    `json:"chain_id"`
+	OracleAddress   string    `json:"oracle_address"`
+	AssetKey        string    `json:"asset_key"`
+	Value           *string   `json:"value"`
+	Decimals        int       `json:"decimals"`
+	BlockNumber     uint64    `json:"block_number"`
+	BlockTime       time.Time `json:"block_time"`
+	TransactionHash string    `json:"transaction_hash"`
+	Sender          string    `json:"sender"`
+	SenderBalance   *string   `json:"sender_balance"`
+	TransactionCost *string   `json:"transaction_cost"`
+	GasCost         *string   `json:"gas_cost"`
+	GasUsed         string    `json:"gas_used"`
+}
+
+type Cost struct {
+	ChainID       string     `json:"chain_id"`
+	OracleAddress string     `json:"oracle_address"`
+	From          *time.Time `json:"from"`
+	To            *time.Time `json:"to"`
+	Updates       uint64     `json:"updates"`
+	Transactions  uint64     `json:"transactions"`
+	TotalCost     string     `json:"total_cost"`
+	AverageCost   string     `json:"average_cost"`
+	TotalGasUsed  string     `json:"total_gas_used"`
+	FirstUpdate   *time.Time `json:"first_update"`
+	LastUpdate    *time.Time `json:"last_update"`
+}
+
+type Access struct {
+	ChainID       string        `json:"chain_id"`
+	OracleAddress string        `json:"oracle_address"`
+	Owner         *string       `json:"owner"`
+	Updaters      []Updater     `json:"updaters"`
+	Events        []AccessEvent `json:"events"`
+	Feeders       []Feeder      `json:"feeders"`
+}
+
+type Updater struct {
+	Address    string    `json:"address"`
+	SinceBlock uint64    `json:"since_block"`
+	SinceTime  time.Time `json:"since_time"`
+}
+
+// Feeder is a wallet that updated the oracle. Expected is null when the
+// oracle has no expected updaters to compare with.
+type Feeder struct {
+	Address     string    `json:"address"`
+	Updates     uint64    `json:"updates"`
+	FirstUpdate time.Time `json:"first_update"`
+	LastUpdate  time.Time `json:"last_update"`
+	Expected    *bool     `json:"expected"`
+}
+
+type AccessEvent struct {
+	Kind            string    `json:"kind"`
+	BlockNumber     uint64    `json:"block_number"`
+	BlockTime       time.Time `json:"block_time"`
+	TransactionHash string    `json:"transaction_hash"`
+	LogIndex        uint      `json:"log_index"`
+	Method          *string   `json:"method"`
+	Sender          string    `json:"sender"`
+	PreviousAddress *string   `json:"previous_address"`
+	NewAddress      string    `json:"new_address"`
+}
+
+type AssetList struct {
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a key of an oracle. Status is listed, missing, unlisted or
+// discovered, the metadata is null for keys missing from the catalogue.
+type Asset struct {
+	AssetKey         string     `json:"asset_key"`
+	Status           string     `json:"status"`
+	Symbol           *string    `json:"symbol"`
+	Decimals         *int       `json:"decimals"`
+	HeartbeatSeconds *int64     `json:"heartbeat_seconds"`
+	DeviationPercent *float64   `json:"deviation_percent"`
+	LastBlock        *uint64    `json:"last_block"`
+	LastUpdate       *time.Time `json:"last_update"`
+}
+
+func newScraperStatus(kind string, r StatusReporter) ScraperStatus {
+	status := ScraperStatus{Kind: kind, Reconnects: r.Reconnects(), Endpoints: []EndpointStatus{}}
+	for _, e := range r.EndpointStatus() {
+		status.Endpoints = append(status.Endpoints, EndpointStatus{
+			Host:      metrics.EndpointLabel(e.URL),
+			Priority:  e.Priority,
+			Healthy:   e.Healthy,
+			Head:      e.Head,
+			HeadLag:   e.HeadLag,
+			Calls:     e.Calls,
+			Errors:    e.Errors,
+			ErrorRate: e.ErrorRate,
+			LatencyMs: float64(e.Latency) / float64(time.Millisecond),
+		})
+	}
+	return status
+}
+
+func newUpdates(updates []helpers.OracleMetrics) []Update {
+	result := make([]Update, 0, len(updates))
+	for _, u := range updates {
+		block, _ := strconv.ParseUint(u.BlockNumber, 10, 64)
+		result = append(result, Update{
+			ChainID:         u.ChainID,
+			OracleAddress:   u.TransactionTo.Hex(),
+			AssetKey:        u.AssetKey,
+			Value:           amount(u.AssetPrice),
+			Decimals:        u.AssetDecimals,
+			BlockNumber:     block,
+			BlockTime:       u.BlockTimestamp.UTC(),
+			TransactionHash: u.TransactionHash,
+			Sender:          u.TransactionFrom.Hex(),
+			SenderBalance:   amount(u.SenderBalance),
+			TransactionCost: amount(u.TransactionCost),
+			GasCost:         amount(u.GasCost),
+			GasUsed:         strconv.FormatUint(u.GasUsed, 10),
+		})
+	}
+	return result
+}
+
+func newCost(cost helpers.OracleCost, from, to time.Time) Cost {
+	return Cost{
+		ChainID:       cost.ChainID,
+		OracleAddress: cost.OracleAddress,
+		From:          optionalTime(from),
+		To:            optionalTime(to),
+		Updates:       cost.Updates,
+		Transactions:  cost.Transactions,
+		TotalCost:     cost.TotalCost,
+		AverageCost:   cost.AverageCost,
+		TotalGasUsed:  cost.TotalGasUsed,
+		FirstUpdate:   optionalTime(cost.FirstUpdate),
+		LastUpdate:    optionalTime(cost.LastUpdate),
+	}
+}
+
+// newAccess takes the owner from the latest ownership transfer in history,
+// null when the oracle never emitted one.
+func newAccess(chainID, address string, history []helpers.AccessEvent, updaters []helpers.AuthorizedUpdater, events []helpers.AccessEvent) Access {
+	access := Access{ChainID: chainID, OracleAddress: address, Updaters: []Updater{}, Events: []AccessEvent{}, Feeders: []Feeder{}}
+	for _, e := range history {
+		if e.Kind == helpers.AccessOwnerChange {
+			owner := e.NewAddress
+			access.Owner = &owner
+		}
+	}
+	for _, u := range updaters {
+		access.Updaters = append(access.Updaters, Updater{Address: u.Updater, SinceBlock: u.SinceBlock, SinceTime: u.SinceTime.UTC()})
+	}
+	for _, e := range events {
+		access.Events = append(access.Events, AccessEvent{
+			Kind:            e.Kind,
+			BlockNumber:     e.BlockNumber,
+			BlockTime:       e.BlockTimestamp.UTC(),
+			TransactionHash: e.TransactionHash,
+			LogIndex:        e.LogIndex,
+			Method:          optionalString(e.Method),
+			Sender:          e.Sender,
+			PreviousAddress: optionalString(e.PreviousAddress),
+			NewAddress:      e.NewAddress,
+		})
+	}
+	return access
+}
+
+func newFeeders(senders []helpers.OracleSender, allowlist *alerts.UpdaterAllowlist) []Feeder {
+	feeders := make([]Feeder, 0, len(senders))
+	for _, sender := range senders {
+		feeder := Feeder{
+			Address:     sender.Sender,
+			Updates:     sender.Updates,
+			FirstUpdate: sender.FirstUpdate.UTC(),
+			LastUpdate:  sender.LastUpdate.UTC(),
+		}
+		if allowlist.Covers(sender.ChainID, sender.OracleAddress) {
+			expected := allowlist.Allows(sender.ChainID, sender.OracleAddress, sender.Sender)
+			feeder.Expected = &expected
+		}
+		feeders = append(feeders, feeder)
+	}
+	return feeders
+}
+
+func newAssets(statuses []alerts.AssetStatus) []Asset {
+	assets := make([]Asset, 0, len(statuses))
+	for _, status := range statuses {
+		asset := Asset{AssetKey: status.AssetKey, Status: status.Status, LastUpdate: optionalTime(status.LastUpdate)}
+		if status.Status == alerts.AssetListed || status.Status == alerts.AssetMissing {
+			symbol := status.Symbol
+			asset.Symbol = &symbol
+			if status.Decimals > 0 {
+				decimals := status.Decimals
+				asset.Decimals = &decimals
+			}
+			if status.Heartbeat > 0 {
+				seconds := int64(status.Heartbeat / time.Second)
+				asset.HeartbeatSeconds = &seconds
+			}
+			if status.DeviationPercent > 0 {
+				deviation := status.DeviationPercent
+				asset.DeviationPercent = &deviation
+			}
+		}
+		if status.Status != alerts.AssetMissing {
+			block := status.LastBlock
+			asset.LastBlock = &block
+		}
+		assets = append(assets, asset)
+	}
+	return assets
+}
+
+// amount formats a number as a decimal string, null when it is unknown.
+func amount(v *big.Int) *string {
+	if v == nil {
+		return nil
+	}
+	s := v.String()
+	return &s
+}
+
+// optionalTime maps the zero time and the Unix epoch, which the queries
+// return when nothing matched, to null.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() || t.Unix() == 0 {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+// DefaultContractABI is the ABI of oracles whose configuration names none.
+const DefaultContractABI = "oracle-v2"
+
+// contractVersions describes how updates are made and decoded for each known
+// oracle contract version, keyed by ABI name. Every ABI file needs an entry.
+var contractVersions = map[string]helpers.OracleContract{
+	"oracle-v1": {
+		UpdateMethod:   "setValue",
+		UpdateEvent:    "OracleUpdate",
+		KeyField:       "key",
+		ValueField:     "value",
+		TimestampField: "timestamp",
+		UpdaterEvent:   "UpdaterAddressChange",
+		UpdaterField:   "newUpdater",
+		SingleUpdater:  true,
+		ValueMethod:    "getValue",
+	},
+	"oracle-v2": {
+		UpdateMethod:          "setValue",
+		BatchUpdateMethod:     "setMultipleValues",
+		UpdateEvent:           "OracleUpdate",
+		KeyField:              "key",
+		ValueField:            "value",
+		TimestampField:        "timestamp",
+		UpdaterEvent:          "UpdaterAddressChange",
+		UpdaterField:          "newUpdater",
+		OwnerEvent:            "OwnershipTransferred",
+		PreviousOwnerField:    "previousOwner",
+		NewOwnerField:         "newOwner",
+		ValueMethod:           "getValue",
+		LastUpdateBlockMethod: "lastUpdateBlockNumber",
+	},
+}
+
+// ABIRegistry holds the oracle contract versions found in the ABI directory,
+// each parsed once.
+type ABIRegistry struct {
+	contracts map[string]*helpers.OracleContract
+}
+
+// LoadABIRegistry parses every <name>.json file in dir.
+func LoadABIRegistry(dir string) (*ABIRegistry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ABI files: %v", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no ABI files in %s", dir)
+	}
+
+	registry := &ABIRegistry{contracts: make(map[string]*helpers.OracleContract)}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+
+		contractABI, err := LoadContractAbi(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ABI %s: %v", name, err)
+		}
+
+		contract, ok := contractVersions[name]
+		if !ok {
+			return nil, fmt.Errorf("ABI %s is no known contract version", name)
+		}
+		contract.Name = name
+		contract.ABI = contractABI
+
+		if _, ok := contractABI.Methods[contract.UpdateMethod]; !ok {
+			return nil, fmt.Errorf("ABI %s has no update method %s", name, contract.UpdateMethod)
+		}
+		if _, ok := contractABI.Methods[contract.BatchUpdateMethod]; contract.BatchUpdateMethod != "" && !ok {
+			// older deployments of the version only update one key at a time
+			contract.BatchUpdateMethod = ""
+		}
+		if _, ok := contractABI.Events[contract.UpdaterEvent]; !ok {
+			contract.UpdaterEvent = ""
+		}
+		if _, ok := contractABI.Events[contract.OwnerEvent]; !ok {
+			contract.OwnerEvent = ""
+		}
+		if _, ok := contractABI.Methods[contract.ValueMethod]; !ok {
+			contract.ValueMethod = ""
+		}
+		if _, ok := contractABI.Methods[contract.LastUpdateBlockMethod]; !ok {
+			contract.LastUpdateBlockMethod = ""
+		}
+		if _, ok := contractABI.Events[contract.UpdateEvent]; !ok {
+			return nil, fmt.Errorf("ABI %s has no update event %s", name, contract.UpdateEvent)
+		}
+		registry.contracts[name] = &contract
+	}
+
+	return registry, nil
+}
+
+// LoadABIRegistryFromEnv loads the ABIs from ABI_DIR, internal/abi unless set.
+func LoadABIRegistryFromEnv() (*ABIRegistry, error) {
+	dir := os.Getenv("ABI_DIR")
+	if dir == "" {
+		dir = "internal/abi"
+	}
+	return LoadABIRegistry(dir)
+}
+
+// Contract returns the contract version named by an oracle configuration,
+// the default version when the name is empty.
+func (r *ABIRegistry) Contract(name string) (*helpers.OracleContract, error) {
+	if name == "" {
+		name = DefaultContractABI
+	}
+	contract, ok := r.contracts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown contract ABI %q, known are %s", name, strings.Join(r.Names(), ", "))
+	}
+	return contract, nil
+}
+
+// Names lists the loaded ABIs.
+func (r *ABIRegistry) Names() []string {
+	names := make([]string, 0, len(r.contracts))
+	for name := range r.contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
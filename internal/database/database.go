@@ -2,14 +2,16 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
 	"os"
-	"strings"
+	"strconv"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/lib/pq"
 
 	"github.com/diadata-org/oracle-monitoring/internal/helpers"
 )
@@ -18,14 +20,54 @@ const (
 	oraclesTable       = "oracles"
 	feederupdatesTable = "feederupdates"
 	chainconfig        = "chainconfig"
+	chainendpoints     = "chainendpoints"
+	heartbeatconfig    = "heartbeatconfig"
+	alertsTable        = "alerts"
 )
 
 const (
 	updateOraclesCreationQuery = "UPDATE oracleconfig SET creation_block = $2, creation_block_time=$3 WHERE address = $1 and chainid =$4"
-	selectOraclesQuery         = `SELECT address, chainid,  COALESCE(latest.scraped_block, 0) AS latest_scraped_block FROM oracleconfig LEFT JOIN (SELECT oracle_address, chain_id, MAX(update_block) AS scraped_block FROM feederupdates GROUP BY oracle_address,chain_id) latest ON (oracleconfig.address = latest.oracle_address and oracleconfig.chainid = latest.chain_id) WHERE  oracleconfig.chainid = '%s'`
-	selectLatestOraclesQuery   = `SELECT address, chainid,  createddate,COALESCE(latest.scraped_block, 0) AS latest_scraped_block FROM oracleconfig LEFT JOIN (SELECT oracle_address, chain_id, MAX(update_block) AS scraped_block FROM feederupdates GROUP BY oracle_address,chain_id) latest ON (oracleconfig.address = latest.oracle_address and oracleconfig.chainid = latest.chain_id) WHERE  oracleconfig.chainid = '%s' and oracleconfig.createddate > '%s'`
-	selectState                = `SELECT chain_id, last_block FROM feederupdatestate WHERE chain_id=$1`
-	updateState                = `UPDATE feederupdatestate SET chain_id=$1, last_block=$2 where chain_id=$1 `
+	selectOraclesQuery         = `SELECT address, chainid, COALESCE(oracleconfig.creation_block, 0), createddate, COALESCE(latest.scraped_block, 0) AS latest_scraped_block, oracleconfig.decimals, oracleconfig.contract_abi FROM oracleconfig LEFT JOIN (SELECT oracle_address, chain_id, MAX(update_block) AS scraped_block FROM feederupdates WHERE chain_id = $1 GROUP BY oracle_address,chain_id) latest ON (oracleconfig.address = latest.oracle_address and oracleconfig.chainid = latest.chain_id) WHERE oracleconfig.chainid = $1 AND ($2::timestamptz IS NULL OR oracleconfig.createddate > $2)`
+	selectRPCQuery             = `SELECT rpcurl, chainid FROM chainconfig WHERE $1::text[] IS NULL OR chainid = ANY($1)`
+	selectWSQuery              = `SELECT wsurl, chainid FROM chainconfig WHERE $1::text[] IS NULL OR chainid = ANY($1)`
+	selectChainConfigsQuery    = `SELECT chainid, rpcurl, wsurl, confirmations, head_quorum FROM chainconfig WHERE $1::text[] IS NULL OR chainid = ANY($1)`
+	selectChainEndpointsQuery  = `SELECT chainid, kind, url, priority FROM chainendpoints WHERE $1::text[] IS NULL OR chainid = ANY($1) ORDER BY priority`
+	selectState                = `SELECT chain_id, last_block, last_block_hash FROM feederupdatestate WHERE chain_id=$1`
+	updateState                = `UPDATE feederupdatestate SET last_block=$2, last_block_hash=$3 WHERE chain_id=$1`
+	insertState                = `INSERT INTO feederupdatestate (chain_id, last_block, last_block_hash) VALUES ($1, $2, $3)`
+	rollbackMetricsQuery       = `DELETE FROM feederupdates WHERE chain_id=$1 AND update_block > $2`
+	rollbackFindingsQuery      = `DELETE FROM updatefindings WHERE chain_id=$1 AND transaction_hash IN (SELECT transaction_hash FROM feederupdates WHERE chain_id=$1 AND update_block > $2)`
+	deleteMetricsQuery         = `DELETE FROM feederupdates WHERE chain_id=$1 AND transaction_hash=$2`
+	selectLatestUpdatesQuery   = `SELECT chain_id, oracle_address, asset_key, MAX(update_block), MAX(update_time) FROM feederupdates GROUP BY chain_id, oracle_address, asset_key`
+	selectHeartbeatRulesQuery  = `SELECT chain_id, oracle_address, asset_key, heartbeat_seconds FROM (SELECT 0 AS priority, id, chain_id, oracle_address, asset_key, heartbeat_seconds FROM heartbeatconfig UNION ALL SELECT 1, id, chain_id, oracle_address, asset_key, heartbeat_seconds FROM assetcatalogue WHERE heartbeat_seconds > 0) rules ORDER BY priority, id`
+	selectActiveAlertsQuery    = `SELECT alert_key, kind, severity, chain_id, oracle_address, asset_key, message, fired_at FROM alerts WHERE firing`
+	selectDeviationRulesQuery  = `SELECT chain_id, oracle_address, asset_key, max_jump_percent, max_reference_deviation_percent, COALESCE(decimals, 0) FROM deviationconfig`
+	selectPreviousPriceQuery   = `SELECT asset_price::text FROM feederupdates WHERE chain_id=$1 AND oracle_address=$2 AND asset_key=$3 AND update_block < $4 AND asset_price IS NOT NULL ORDER BY update_block DESC LIMIT 1`
+	insertFindingQuery         = `INSERT INTO updatefindings (chain_id, oracle_address, transaction_hash, asset_key, kind, severity, message, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (chain_id, transaction_hash, asset_key, kind) DO NOTHING`
+	selectFeedersQuery         = `SELECT DISTINCT chain_id, update_from FROM feederupdates WHERE chain_id IS NOT NULL`
+	insertFeederBalanceQuery   = `INSERT INTO feederbalances (chain_id, address, balance, recorded_at) VALUES ($1, $2, $3, $4)`
+	selectFeederSpendQuery     = `SELECT (COALESCE((SELECT SUM(transaction_cost) FROM feederupdates WHERE chain_id=$1 AND update_from=$2 AND update_time >= $3), 0) + COALESCE((SELECT SUM(transaction_cost) FROM failedupdates WHERE chain_id=$1 AND update_from=$2 AND update_time >= $3), 0))::text`
+	insertFailedUpdateQuery    = `INSERT INTO failedupdates (chain_id, oracle_address, transaction_hash, update_block, update_time, update_from, method, asset_keys, gas_used, gas_cost, transaction_cost, revert_reason) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::numeric, $10::numeric, $11::numeric, $12) ON CONFLICT (chain_id, transaction_hash) DO NOTHING`
+	insertAccessEventQuery     = `INSERT INTO accessevents (chain_id, oracle_address, transaction_hash, log_index, block_number, event_time, kind, method, previous_address, new_address, sender) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) ON CONFLICT (chain_id, transaction_hash, log_index) DO NOTHING`
+	insertUncoveredRangeQuery  = `INSERT INTO uncoveredranges (chain_id, from_block, to_block, recorded_at) VALUES ($1, $2, $3, $4) ON CONFLICT (chain_id, from_block, to_block) DO NOTHING`
+	deleteAccessEventQuery     = `DELETE FROM accessevents WHERE chain_id=$1 AND transaction_hash=$2 AND log_index=$3`
+	rollbackAccessQuery        = `DELETE FROM accessevents WHERE chain_id=$1 AND block_number > $2`
+	selectAccessEventsQuery    = `SELECT chain_id, oracle_address, transaction_hash, log_index, block_number, event_time, kind, method, previous_address, new_address, sender FROM classifiedaccessevents WHERE ($1 = '' OR chain_id=$1) AND ($2 = '' OR lower(oracle_address)=lower($2)) AND ($3::timestamp IS NULL OR event_time >= $3) ORDER BY block_number, log_index`
+	selectUpdatersQuery        = `SELECT chain_id, oracle_address, updater, since_block, since_time FROM oracleupdaters WHERE ($1 = '' OR chain_id=$1) AND ($2 = '' OR lower(oracle_address)=lower($2)) ORDER BY chain_id, oracle_address, since_block`
+	selectCatalogueQuery       = `SELECT chain_id, oracle_address, asset_key, symbol, COALESCE(decimals, 0), heartbeat_seconds, deviation_percent FROM assetcatalogue WHERE ($1 = '' OR chain_id=$1) AND ($2 = '' OR lower(oracle_address)=lower($2)) ORDER BY chain_id, oracle_address, asset_key`
+	selectAllowlistQuery       = `SELECT chain_id, oracle_address, updater, label FROM updaterallowlist`
+	selectOracleSendersQuery   = `SELECT chain_id, oracle_address, update_from, COUNT(*), MIN(update_time), MAX(update_time) FROM feederupdates WHERE chain_id IS NOT NULL AND ($1 = '' OR chain_id=$1) AND ($2 = '' OR lower(oracle_address)=lower($2)) GROUP BY chain_id, oracle_address, update_from ORDER BY MIN(update_time)`
+	rollbackFailedQuery        = `DELETE FROM failedupdates WHERE chain_id=$1 AND update_block > $2`
+	selectFailureSummaryQuery  = `SELECT chain_id, oracle_address, update_from, COUNT(*), MAX(update_time), (array_agg(revert_reason ORDER BY update_time DESC))[1] FROM failedupdates WHERE update_time >= $1 GROUP BY chain_id, oracle_address, update_from`
+	insertMetricsBatchQuery    = `INSERT INTO feederupdates (oracle_address, transaction_hash, transaction_cost, asset_key, asset_price, asset_decimals, update_block, update_from, from_balance, gas_cost, gas_used, creation_block, chain_id, update_time, log_index) SELECT oracle_address, transaction_hash, transaction_cost::numeric, asset_key, asset_price::numeric, asset_decimals, update_block, update_from, from_balance::numeric, gas_cost::numeric, gas_used::numeric, creation_block, chain_id, update_time, log_index FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::integer[], $7::bigint[], $8::text[], $9::text[], $10::text[], $11::text[], $12::bigint[], $13::text[], $14::timestamp[], $15::integer[]) AS u(oracle_address, transaction_hash, transaction_cost, asset_key, asset_price, asset_decimals, update_block, update_from, from_balance, gas_cost, gas_used, creation_block, chain_id, update_time, log_index) WHERE NOT EXISTS (SELECT 1 FROM feederupdates f WHERE f.transaction_hash = u.transaction_hash AND f.asset_key = u.asset_key AND f.update_time = u.update_time AND f.log_index = -1) ON CONFLICT (transaction_hash, asset_key, update_time, log_index) DO NOTHING`
+	metricsColumns             = `oracle_address, transaction_hash, transaction_cost::text, asset_key, asset_price::text, asset_decimals, update_block::text, update_from, from_balance::text, gas_cost::text, gas_used::text, COALESCE(chain_id, ''), COALESCE(update_time, 'epoch'::timestamp)`
+	selectLatestValuesQuery    = `SELECT DISTINCT ON (asset_key) ` + metricsColumns + ` FROM feederupdates WHERE chain_id=$1 AND lower(oracle_address)=lower($2) ORDER BY asset_key, update_block DESC`
+	selectUpdatesQuery         = `SELECT ` + metricsColumns + ` FROM feederupdates WHERE chain_id=$1 AND lower(oracle_address)=lower($2) AND ($3 = '' OR asset_key=$3) AND ($4::timestamp IS NULL OR update_time >= $4) AND ($5::timestamp IS NULL OR update_time < $5) ORDER BY update_block DESC, asset_key LIMIT $6 OFFSET $7`
+	selectOracleCostQuery      = `SELECT COALESCE(SUM(updates), 0), COUNT(*), COALESCE(SUM(cost), 0)::text, COALESCE(ROUND(AVG(cost)), 0)::text, COALESCE(SUM(gas), 0)::text, COALESCE(MIN(first), 'epoch'::timestamp), COALESCE(MAX(last), 'epoch'::timestamp) FROM (SELECT transaction_hash, COUNT(*) AS updates, SUM(transaction_cost) AS cost, SUM(gas_used) AS gas, MIN(update_time) AS first, MAX(update_time) AS last FROM feederupdates WHERE chain_id=$1 AND lower(oracle_address)=lower($2) AND ($3::timestamp IS NULL OR update_time >= $3) AND ($4::timestamp IS NULL OR update_time < $4) GROUP BY transaction_hash) txs`
+	selectRetentionQuery       = `SELECT chain_id, retention_days, compress_after_days FROM retentionconfig`
+	pruneMetricsQuery          = `DELETE FROM feederupdates WHERE chain_id=$1 AND update_time < $2`
+	selectChainLastUpdateQuery = `SELECT COALESCE(MAX(update_time), 'epoch'::timestamp) FROM feederupdates WHERE chain_id=$1`
+	upsertAlertQuery           = `INSERT INTO alerts (alert_key, kind, severity, chain_id, oracle_address, asset_key, message, firing, fired_at, resolved_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (alert_key) DO UPDATE SET kind = EXCLUDED.kind, severity = EXCLUDED.severity, message = EXCLUDED.message, firing = EXCLUDED.firing, fired_at = EXCLUDED.fired_at, resolved_at = EXCLUDED.resolved_at`
 )
 
 // Database is an interface that represents the required database operations.
@@ -35,17 +77,49 @@ type Database interface {
 	UpdateOracleCreation(address string, block string, blocktime time.Time, chainid string) error
 	SelectOracles(string) ([]helpers.Target, error)
 	InsertOracleMetrics(metrics *helpers.OracleMetrics) error
+	InsertOracleMetricsBatch(metrics []helpers.OracleMetrics) error
 	GetRPCByChainID([]string) (map[string]string, error)
 	GetWSByChainID([]string) (map[string]string, error)
 	SelectOraclesWithCreationTime(chainID string, lastCreatedTime time.Time) ([]helpers.Target, error)
+	GetChainConfigs(chainIDs []string) (map[string]helpers.ChainConfig, error)
 	GetState(chainID string) (helpers.OracleMetricsState, error)
 	SetState(state helpers.OracleMetricsState) error
+	RollbackOracleMetrics(chainID string, block uint64) error
+	DeleteOracleMetrics(chainID string, transactionHash string) error
+	SelectLatestUpdates() ([]helpers.AssetUpdate, error)
+	SelectHeartbeatRules() ([]helpers.HeartbeatRule, error)
+	SelectActiveAlerts() ([]helpers.Alert, error)
+	UpsertAlert(alert helpers.Alert) error
+	SelectDeviationRules() ([]helpers.DeviationRule, error)
+	SelectPreviousPrice(chainID string, oracleAddress string, assetKey string, block uint64) (string, error)
+	InsertFinding(finding helpers.Finding) error
+	SelectFeeders() ([]helpers.Feeder, error)
+	InsertFeederBalance(balance helpers.FeederBalance) error
+	SelectFeederSpend(feeder helpers.Feeder, since time.Time) (string, error)
+	SelectLatestValues(chainID string, oracleAddress string) ([]helpers.OracleMetrics, error)
+	SelectUpdates(filter helpers.UpdateFilter) ([]helpers.OracleMetrics, error)
+	SelectOracleCost(chainID string, oracleAddress string, from time.Time, to time.Time) (helpers.OracleCost, error)
+	SelectChainLastUpdate(chainID string) (time.Time, error)
+	SelectRetentionPolicies() ([]helpers.RetentionPolicy, error)
+	InsertFailedUpdates(failed []helpers.FailedUpdate) error
+	SelectFailureSummary(since time.Time) ([]helpers.FailureSummary, error)
+	InsertAccessEvents(events []helpers.AccessEvent) error
+	DeleteAccessEvent(chainID string, transactionHash string, logIndex uint) error
+	SelectAccessEvents(chainID string, oracleAddress string, since time.Time) ([]helpers.AccessEvent, error)
+	InsertUncoveredRanges(ranges []helpers.UncoveredRange) error
+	SelectAuthorizedUpdaters(chainID string, oracleAddress string) ([]helpers.AuthorizedUpdater, error)
+	SelectUpdaterAllowlist() ([]helpers.AllowedUpdater, error)
+	SelectAssetCatalogue(chainID string, oracleAddress string) ([]helpers.CatalogueEntry, error)
+	SelectOracleSenders(chainID string, oracleAddress string) ([]helpers.OracleSender, error)
+	PruneOracleMetrics(chainID string, before time.Time) (int64, error)
 
 	Close()
 }
 
 type postgresDB struct {
 	db *pgxpool.Pool
+	// feederupdates is a hypertable whose retention policy drops old chunks
+	hypertable bool
 }
 
 // NewPostgresDB creates a new instance of the Database interface with PostgreSQL implementation.
@@ -71,24 +145,85 @@ func (pdb *postgresDB) Connect() error {
 		return fmt.Errorf("unable to connect to the database: %v", err)
 	}
 
+	// Bring the schema up to date before anything queries it
+	if pdb.hypertable, err = migrate(context.Background(), pdb.db, os.Getenv("TIMESCALEDB")); err != nil {
+		pdb.db.Close()
+		return fmt.Errorf("failed to migrate the database schema: %v", err)
+	}
+
 	return nil
 }
 
-func (pdb *postgresDB) GetState(chainID string) (os helpers.OracleMetricsState, err error) {
-	query := fmt.Sprintf(selectState)
+// GetState returns the scanning checkpoint of a chain. A chain that was never
+// scanned yields a zero LastBlock.
+func (pdb *postgresDB) GetState(chainID string) (state helpers.OracleMetricsState, err error) {
+	row := pdb.db.QueryRow(context.Background(), selectState, chainID)
+	err = row.Scan(&state.ChainID, &state.LastBlock, &state.LastBlockHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return helpers.OracleMetricsState{ChainID: chainID}, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to get the state from the DB: %v", err)
+	}
+	return state, nil
+}
 
-	row := pdb.db.QueryRow(context.Background(), query, chainID)
-	err = row.Scan(&os)
-	return
+// SetState persists the scanning checkpoint of a chain, creating it on first use.
+func (pdb *postgresDB) SetState(state helpers.OracleMetricsState) error {
+	tag, err := pdb.db.Exec(context.Background(), updateState, state.ChainID, state.LastBlock, state.LastBlockHash)
+	if err != nil {
+		return fmt.Errorf("failed to update the state in the DB: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
 
+	_, err = pdb.db.Exec(context.Background(), insertState, state.ChainID, state.LastBlock, state.LastBlockHash)
+	if err != nil {
+		return fmt.Errorf("failed to insert the state in the DB: %w", err)
+	}
+	return nil
 }
 
-func (pdb *postgresDB) SetState(state helpers.OracleMetricsState) error {
-	_, err := pdb.db.Exec(context.Background(), updateState, state.ChainID, state.LastBlock)
+// RollbackOracleMetrics deletes the updates of a chain stored above block,
+// used when those blocks were orphaned by a reorganisation, together with
+// their findings, failed updates and access events. Either all of them are
+// deleted or none.
+func (pdb *postgresDB) RollbackOracleMetrics(chainID string, block uint64) error {
+	ctx := context.Background()
+	tx, err := pdb.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin the rollback: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, step := range []struct {
+		query string
+		what  string
+	}{
+		{rollbackFindingsQuery, "findings"},
+		{rollbackMetricsQuery, "metrics"},
+		{rollbackFailedQuery, "failed updates"},
+		{rollbackAccessQuery, "access events"},
+	} {
+		if _, err := tx.Exec(ctx, step.query, chainID, block); err != nil {
+			return fmt.Errorf("failed to roll back the %s in the DB: %w", step.what, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit the rollback: %w", err)
+	}
+	return nil
+}
+
+// DeleteOracleMetrics deletes the updates stored for a single transaction.
+func (pdb *postgresDB) DeleteOracleMetrics(chainID string, transactionHash string) error {
+	_, err := pdb.db.Exec(context.Background(), deleteMetricsQuery, chainID, transactionHash)
 	if err != nil {
-		return fmt.Errorf("failed to update the SetState  in the DB: %v", err)
+		return fmt.Errorf("failed to delete the metrics in the DB: %w", err)
 	}
-	return err
+	return nil
 }
 
 func (pdb *postgresDB) InsertOracles(targets []helpers.Target) error {
@@ -105,187 +240,569 @@ func (pdb *postgresDB) UpdateOracleCreation(address string, block string, blockt
 
 	return nil
 }
+
+// SelectOraclesWithCreationTime returns the oracles of a chain registered
+// after lastCreatedTime.
 func (pdb *postgresDB) SelectOraclesWithCreationTime(chainID string, lastCreatedTime time.Time) ([]helpers.Target, error) {
-	fmt.Println("SelectOraclesWithCreationTime")
-	targets := []helpers.Target{}
+	return selectRows(pdb, selectOraclesQuery, scanTarget, chainID, nullTime(lastCreatedTime))
+}
 
-	// Retrieve oracles with the latest scraped block
+// SelectOracles returns every oracle of a chain with the latest block an
+// update was stored for.
+func (pdb *postgresDB) SelectOracles(chainID string) ([]helpers.Target, error) {
+	return selectRows(pdb, selectOraclesQuery, scanTarget, chainID, nil)
+}
 
-	query := fmt.Sprintf(selectLatestOraclesQuery, chainID, lastCreatedTime.Format("2006-01-02 15:04:05.999999-07:00"))
+func scanTarget(rows pgx.Rows) (target helpers.Target, err error) {
+	err = rows.Scan(&target.ContractAddress, &target.ChainId, &target.CreationBlock, &target.CreatedDate, &target.LatestScrapedBlock, &target.Decimals, &target.ContractABI)
+	return target, err
+}
 
-	rows, err := pdb.db.Query(context.Background(), query)
+// selectRows runs a query with bound arguments and scans every row with scan.
+// Queries never interpolate values into their SQL.
+func selectRows[T any](pdb *postgresDB, query string, scan func(pgx.Rows) (T, error), args ...interface{}) ([]T, error) {
+	rows, err := pdb.db.Query(context.Background(), query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch data from the DB query: %v", err)
 	}
 	defer rows.Close()
 
-	// Format the results as struct
+	results := []T{}
 	for rows.Next() {
-		var target helpers.Target
-		err := rows.Scan(&target.ContractAddress, &target.ChainId, &target.CreatedDate, &target.LatestScrapedBlock)
+		result, err := scan(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get the list of targets from the DB: %v", err)
+			return nil, fmt.Errorf("failed to read the rows from the DB: %v", err)
 		}
-		targets = append(targets, target)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the rows from the DB: %v", err)
 	}
 
-	return targets, nil
+	return results, nil
 }
 
-func (pdb *postgresDB) SelectOracles(chainID string) ([]helpers.Target, error) {
-	targets := []helpers.Target{}
+// chainFilter binds a list of chain IDs, NULL when the list is empty so the
+// queries select every chain.
+func chainFilter(chainIDs []string) interface{} {
+	if len(chainIDs) == 0 {
+		return nil
+	}
+	return chainIDs
+}
 
-	// Retrieve oracles with the latest scraped block
+// InsertOracleMetrics stores a single update.
+func (pdb *postgresDB) InsertOracleMetrics(metrics *helpers.OracleMetrics) error {
+	return pdb.InsertOracleMetricsBatch([]helpers.OracleMetrics{*metrics})
+}
 
-	query := fmt.Sprintf(selectOraclesQuery, chainID)
-	rows, err := pdb.db.Query(context.Background(), query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch data from the DB query: %v", err)
+// InsertOracleMetricsBatch stores updates with a single multi-row upsert.
+// Updates already stored are skipped, including those stored before log
+// indexes were recorded.
+func (pdb *postgresDB) InsertOracleMetricsBatch(metrics []helpers.OracleMetrics) error {
+	if len(metrics) == 0 {
+		return nil
 	}
-	defer rows.Close()
 
-	// Format the results as struct
-	for rows.Next() {
-		var target helpers.Target
-		err := rows.Scan(&target.ContractAddress, &target.ChainId, &target.LatestScrapedBlock)
+	// one array per column, unnested server side. Numbers travel as decimal
+	// strings so they keep their full precision.
+	var (
+		oracles, hashes, keys, senders, chains []string
+		costs, prices, balances, gasPrices     []*string
+		gasUsed                                []string
+		decimals, logIndexes                   []int32
+		blocks, creationBlocks                 []int64
+		times                                  []time.Time
+	)
+	for _, m := range metrics {
+		block, err := strconv.ParseInt(m.BlockNumber, 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get the list of targets from the DB: %v", err)
+			return fmt.Errorf("invalid block number %q of %s: %v", m.BlockNumber, m.TransactionHash, err)
 		}
-		targets = append(targets, target)
+
+		oracles = append(oracles, m.TransactionTo.String())
+		hashes = append(hashes, m.TransactionHash)
+		costs = append(costs, numericValue(m.TransactionCost))
+		keys = append(keys, m.AssetKey)
+		prices = append(prices, numericValue(m.AssetPrice))
+		decimals = append(decimals, int32(m.AssetDecimals))
+		blocks = append(blocks, block)
+		senders = append(senders, m.TransactionFrom.String())
+		balances = append(balances, numericValue(m.SenderBalance))
+		gasPrices = append(gasPrices, numericValue(m.GasCost))
+		gasUsed = append(gasUsed, strconv.FormatUint(m.GasUsed, 10))
+		creationBlocks = append(creationBlocks, 0)
+		chains = append(chains, m.ChainID)
+		times = append(times, m.BlockTimestamp)
+		logIndexes = append(logIndexes, int32(m.LogIndex))
+	}
+	args := []interface{}{oracles, hashes, costs, keys, prices, decimals, blocks, senders, balances, gasPrices, gasUsed, creationBlocks, chains, times, logIndexes}
+
+	_, err := pdb.db.Exec(context.Background(), insertMetricsBatchQuery, args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert metrics in the DB: %w", err)
 	}
+	return nil
+}
 
-	return targets, nil
+// GetRPCByChainID returns the RPC URL for the given chain ID.
+func (pdb *postgresDB) GetRPCByChainID(chainIDs []string) (map[string]string, error) {
+	return pdb.selectURLs(selectRPCQuery, chainIDs)
 }
 
-func (pdb *postgresDB) InsertOracleMetrics(metrics *helpers.OracleMetrics) error {
-	insertMetricsQuery := fmt.Sprintf("INSERT INTO %s (oracle_address,transaction_hash,transaction_cost,asset_key,asset_price,update_block, update_from, from_balance, gas_cost, gas_used,creation_block,chain_id,update_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10,$11,$12,$13) ON CONFLICT (transaction_hash) DO NOTHING", feederupdatesTable)
-
-	fmt.Printf(
-		"-- Inserted Metrics --\n"+
-			" TransactionTo: %s "+
-			" TransactionHash: %s "+
-			" TransactionCost: %s "+
-			" AssetKey: %s "+
-			" AssetPrice: %s "+
-			" BlockNumber: %s "+
-			" TransactionFrom: %s "+
-			" SenderBalance: %s "+
-			" GasCost: %s "+
-			" GasUsed: %s "+
-			" ChainID: %s "+
-			" BlockTimestamp: %s ",
-		metrics.TransactionTo,
-		metrics.TransactionHash,
-		metrics.TransactionCost,
-		metrics.AssetKey,
-		metrics.AssetPrice,
-		metrics.BlockNumber,
-		metrics.TransactionFrom,
-		metrics.SenderBalance,
-		metrics.GasCost,
-		metrics.GasUsed,
-		metrics.ChainID,
-		metrics.BlockTimestamp,
-	)
+// GetWSByChainID returns the WS URL for the given chain ID.
+func (pdb *postgresDB) GetWSByChainID(chainIDs []string) (map[string]string, error) {
+	return pdb.selectURLs(selectWSQuery, chainIDs)
+}
 
-	metrics.CreationBlock = "0"
-
-	// Insert metrics into the database
-	_, err := pdb.db.Exec(
-		context.Background(),
-		insertMetricsQuery,
-		metrics.TransactionTo.String(),
-		metrics.TransactionHash,
-		metrics.TransactionCost,
-		metrics.AssetKey,
-		metrics.AssetPrice,
-		metrics.BlockNumber,
-		metrics.TransactionFrom.String(),
-		metrics.SenderBalance,
-		metrics.GasCost,
-		metrics.GasUsed,
-		metrics.CreationBlock,
-		metrics.ChainID,
-		metrics.BlockTimestamp,
-	)
+func (pdb *postgresDB) selectURLs(query string, chainIDs []string) (map[string]string, error) {
+	type chainURL struct{ url, chainID string }
+	rows, err := selectRows(pdb, query, func(rows pgx.Rows) (r chainURL, err error) {
+		err = rows.Scan(&r.url, &r.chainID)
+		return r, err
+	}, chainFilter(chainIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make(map[string]string, len(rows))
+	for _, r := range rows {
+		urls[r.chainID] = r.url
+	}
+	return urls, nil
+}
+
+// GetChainConfigs returns the connection settings of the given chains, or of
+// every chain when none is given. The rpcurl and wsurl of chainconfig are the
+// primary endpoints, chainendpoints adds fallbacks ranked by priority.
+func (pdb *postgresDB) GetChainConfigs(chainIDs []string) (map[string]helpers.ChainConfig, error) {
+	chains, err := selectRows(pdb, selectChainConfigsQuery, func(rows pgx.Rows) (helpers.ChainConfig, error) {
+		var config helpers.ChainConfig
+		var rpcurl, wsurl string
+		if err := rows.Scan(&config.ChainID, &rpcurl, &wsurl, &config.Confirmations, &config.HeadQuorum); err != nil {
+			return config, err
+		}
+		if rpcurl != "" {
+			config.RPC = append(config.RPC, helpers.Endpoint{URL: rpcurl})
+		}
+		if wsurl != "" {
+			config.WS = append(config.WS, helpers.Endpoint{URL: wsurl})
+		}
+		return config, nil
+	}, chainFilter(chainIDs))
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			return nil
+		return nil, err
+	}
+
+	type chainEndpoint struct {
+		chainID, kind string
+		endpoint      helpers.Endpoint
+	}
+	endpoints, err := selectRows(pdb, selectChainEndpointsQuery, func(rows pgx.Rows) (e chainEndpoint, err error) {
+		err = rows.Scan(&e.chainID, &e.kind, &e.endpoint.URL, &e.endpoint.Priority)
+		return e, err
+	}, chainFilter(chainIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]helpers.ChainConfig, len(chains))
+	for _, config := range chains {
+		configs[config.ChainID] = config
+	}
+	for _, e := range endpoints {
+		config, ok := configs[e.chainID]
+		if !ok {
+			continue
+		}
+		switch e.kind {
+		case "rpc":
+			config.RPC = append(config.RPC, e.endpoint)
+		case "ws":
+			config.WS = append(config.WS, e.endpoint)
 		}
-		return fmt.Errorf("failed to insert metrics in the DB: %v", err)
+		configs[e.chainID] = config
+	}
+
+	return configs, nil
+}
+
+// SelectLatestUpdates returns the latest stored update of every asset key.
+func (pdb *postgresDB) SelectLatestUpdates() ([]helpers.AssetUpdate, error) {
+	return selectRows(pdb, selectLatestUpdatesQuery, func(rows pgx.Rows) (helpers.AssetUpdate, error) {
+		var update helpers.AssetUpdate
+		var chainID *string
+		var updateTime *time.Time
+		if err := rows.Scan(&chainID, &update.OracleAddress, &update.AssetKey, &update.UpdateBlock, &updateTime); err != nil {
+			return update, err
+		}
+		if chainID != nil {
+			update.ChainID = *chainID
+		}
+		if updateTime != nil {
+			update.UpdateTime = *updateTime
+		}
+		return update, nil
+	})
+}
+
+// SelectHeartbeatRules returns the configured heartbeat intervals followed by
+// those of the asset catalogue, so heartbeatconfig wins ties.
+func (pdb *postgresDB) SelectHeartbeatRules() ([]helpers.HeartbeatRule, error) {
+	return selectRows(pdb, selectHeartbeatRulesQuery, func(rows pgx.Rows) (rule helpers.HeartbeatRule, err error) {
+		var seconds int64
+		err = rows.Scan(&rule.ChainID, &rule.OracleAddress, &rule.AssetKey, &seconds)
+		rule.Interval = time.Duration(seconds) * time.Second
+		return rule, err
+	})
+}
+
+// SelectActiveAlerts returns the alerts that are currently firing.
+func (pdb *postgresDB) SelectActiveAlerts() ([]helpers.Alert, error) {
+	return selectRows(pdb, selectActiveAlertsQuery, func(rows pgx.Rows) (helpers.Alert, error) {
+		alert := helpers.Alert{Firing: true}
+		err := rows.Scan(&alert.Key, &alert.Kind, &alert.Severity, &alert.ChainID, &alert.OracleAddress, &alert.AssetKey, &alert.Message, &alert.FiredAt)
+		return alert, err
+	})
+}
+
+// UpsertAlert stores the current state of an alert.
+func (pdb *postgresDB) UpsertAlert(alert helpers.Alert) error {
+	var resolvedAt *time.Time
+	if !alert.ResolvedAt.IsZero() {
+		resolvedAt = &alert.ResolvedAt
 	}
 
+	_, err := pdb.db.Exec(context.Background(), upsertAlertQuery, alert.Key, alert.Kind, alert.Severity, alert.ChainID, alert.OracleAddress, alert.AssetKey, alert.Message, alert.Firing, alert.FiredAt, resolvedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store the alert in the DB: %v", err)
+	}
 	return nil
 }
 
-// GetRPCByChainID returns the RPC URL for the given chain ID.
-func (pdb *postgresDB) GetRPCByChainID(chainIDs []string) (rpc map[string]string, err error) {
+// SelectDeviationRules returns the configured value limits.
+func (pdb *postgresDB) SelectDeviationRules() ([]helpers.DeviationRule, error) {
+	return selectRows(pdb, selectDeviationRulesQuery, func(rows pgx.Rows) (rule helpers.DeviationRule, err error) {
+		err = rows.Scan(&rule.ChainID, &rule.OracleAddress, &rule.AssetKey, &rule.MaxJumpPercent, &rule.MaxReferenceDeviationPercent, &rule.Decimals)
+		return rule, err
+	})
+}
+
+// SelectPreviousPrice returns the value stored for an asset key before block,
+// or an empty string when there is none.
+func (pdb *postgresDB) SelectPreviousPrice(chainID string, oracleAddress string, assetKey string, block uint64) (string, error) {
+	var price string
+	err := pdb.db.QueryRow(context.Background(), selectPreviousPriceQuery, chainID, oracleAddress, assetKey, block).Scan(&price)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get the previous price from the DB: %v", err)
+	}
+	return price, nil
+}
 
-	query := `SELECT rpcurl, chainid from %s`
+// InsertFinding stores a problem found on an update.
+func (pdb *postgresDB) InsertFinding(finding helpers.Finding) error {
+	_, err := pdb.db.Exec(context.Background(), insertFindingQuery, finding.ChainID, finding.OracleAddress, finding.TransactionHash, finding.AssetKey, finding.Kind, finding.Severity, finding.Message, finding.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert the finding in the DB: %v", err)
+	}
+	return nil
+}
+
+// SelectFeeders returns every wallet that pushed an update.
+func (pdb *postgresDB) SelectFeeders() ([]helpers.Feeder, error) {
+	return selectRows(pdb, selectFeedersQuery, func(rows pgx.Rows) (feeder helpers.Feeder, err error) {
+		err = rows.Scan(&feeder.ChainID, &feeder.Address)
+		return feeder, err
+	})
+}
 
-	if len(chainIDs) > 0 {
-		query = `SELECT rpcurl, chainid from %s WHERE chainid = ANY($1)`
+// InsertFeederBalance records a balance sample of a feeder wallet.
+func (pdb *postgresDB) InsertFeederBalance(balance helpers.FeederBalance) error {
+	_, err := pdb.db.Exec(context.Background(), insertFeederBalanceQuery, balance.ChainID, balance.Address, balance.Balance, balance.RecordedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert the feeder balance in the DB: %v", err)
 	}
+	return nil
+}
 
-	rpc = make(map[string]string)
+// SelectFeederSpend returns the wei spent on update transactions by a feeder
+// since the given time.
+func (pdb *postgresDB) SelectFeederSpend(feeder helpers.Feeder, since time.Time) (string, error) {
+	var spent string
+	err := pdb.db.QueryRow(context.Background(), selectFeederSpendQuery, feeder.ChainID, feeder.Address, since).Scan(&spent)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the feeder spend from the DB: %v", err)
+	}
+	return spent, nil
+}
 
-	var rows pgx.Rows
-	if len(chainIDs) > 0 {
-		rows, err = pdb.db.Query(context.Background(), fmt.Sprintf(query, chainconfig), pq.Array(chainIDs))
-	} else {
-		rows, err = pdb.db.Query(context.Background(), fmt.Sprintf(query, chainconfig))
+// SelectLatestValues returns the latest stored update of every asset key of
+// an oracle.
+func (pdb *postgresDB) SelectLatestValues(chainID string, oracleAddress string) ([]helpers.OracleMetrics, error) {
+	rows, err := pdb.db.Query(context.Background(), selectLatestValuesQuery, chainID, oracleAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data from the DB query: %v", err)
 	}
+	return scanOracleMetrics(rows)
+}
+
+// SelectUpdates returns the stored updates of an oracle matching filter,
+// newest first.
+func (pdb *postgresDB) SelectUpdates(filter helpers.UpdateFilter) ([]helpers.OracleMetrics, error) {
+	rows, err := pdb.db.Query(context.Background(), selectUpdatesQuery, filter.ChainID, filter.OracleAddress, filter.AssetKey, nullTime(filter.From), nullTime(filter.To), filter.Limit, filter.Offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch data from the DB query: %v", err)
 	}
+	return scanOracleMetrics(rows)
+}
 
-	for rows.Next() {
+// SelectOracleCost sums the update transactions of an oracle between from
+// and to. Zero times leave the range open.
+func (pdb *postgresDB) SelectOracleCost(chainID string, oracleAddress string, from time.Time, to time.Time) (helpers.OracleCost, error) {
+	cost := helpers.OracleCost{ChainID: chainID, OracleAddress: oracleAddress}
+	err := pdb.db.QueryRow(context.Background(), selectOracleCostQuery, chainID, oracleAddress, nullTime(from), nullTime(to)).Scan(
+		&cost.Updates, &cost.Transactions, &cost.TotalCost, &cost.AverageCost, &cost.TotalGasUsed, &cost.FirstUpdate, &cost.LastUpdate,
+	)
+	if err != nil {
+		return cost, fmt.Errorf("failed to get the oracle cost from the DB: %v", err)
+	}
+	return cost, nil
+}
 
-		var rpcurl, chainid string
-		err := rows.Scan(&rpcurl, &chainid)
+// SelectChainLastUpdate returns the time of the latest stored update of a
+// chain, the Unix epoch when there is none.
+func (pdb *postgresDB) SelectChainLastUpdate(chainID string) (time.Time, error) {
+	var last time.Time
+	err := pdb.db.QueryRow(context.Background(), selectChainLastUpdateQuery, chainID).Scan(&last)
+	if err != nil {
+		return last, fmt.Errorf("failed to get the last update from the DB: %v", err)
+	}
+	return last, nil
+}
+
+// scanOracleMetrics reads rows selected with metricsColumns.
+func scanOracleMetrics(rows pgx.Rows) ([]helpers.OracleMetrics, error) {
+	defer rows.Close()
+
+	updates := []helpers.OracleMetrics{}
+	for rows.Next() {
+		var update helpers.OracleMetrics
+		var oracle, from string
+		var cost, price, balance, gasPrice *string
+		var gasUsed string
+		err := rows.Scan(&oracle, &update.TransactionHash, &cost, &update.AssetKey, &price, &update.AssetDecimals, &update.BlockNumber,
+			&from, &balance, &gasPrice, &gasUsed, &update.ChainID, &update.BlockTimestamp)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get the list of rpc from the DB: %v", err)
+			return nil, fmt.Errorf("failed to get the updates from the DB: %v", err)
 		}
-		rpc[chainid] = rpcurl
+		update.TransactionTo = common.HexToAddress(oracle)
+		update.TransactionFrom = common.HexToAddress(from)
+		update.TransactionCost = parseNumeric(cost)
+		update.AssetPrice = parseNumeric(price)
+		update.SenderBalance = parseNumeric(balance)
+		update.GasCost = parseNumeric(gasPrice)
+		update.GasUsed, _ = strconv.ParseUint(gasUsed, 10, 64)
+		updates = append(updates, update)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get the updates from the DB: %v", err)
 	}
-	return rpc, nil
+
+	return updates, nil
 }
 
-// GetWSByChainID returns the WS URL for the given chain ID.
-func (pdb *postgresDB) GetWSByChainID(chainIDs []string) (rpc map[string]string, err error) {
+// numericValue binds v to a numeric column, NULL when it is nil.
+func numericValue(v *big.Int) *string {
+	if v == nil {
+		return nil
+	}
+	s := v.String()
+	return &s
+}
 
-	query := `SELECT wsurl, chainid from %s`
+// parseNumeric reads a numeric column selected as text.
+func parseNumeric(s *string) *big.Int {
+	if s == nil {
+		return nil
+	}
+	v, ok := new(big.Int).SetString(*s, 10)
+	if !ok {
+		return nil
+	}
+	return v
+}
 
-	if len(chainIDs) > 0 {
-		query = `SELECT wsurl, chainid from %s WHERE chainid = ANY($1)`
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
 	}
+	return &t
+}
+
+func (pdb *postgresDB) Close() {
+	pdb.db.Close()
+}
+
+// SELECT address, chainid,  COALESCE(latest.scraped_block, 0) AS latest_scraped_block FROM oracleconfig LEFT JOIN (SELECT oracle_address,chain_id, MAX(update_block) AS scraped_block FROM feederupdates GROUP BY oracle_address,chain_id) latest ON oracleconfig.address = latest.oracle_address;
 
-	rpc = make(map[string]string)
+func (pdb *postgresDB) SelectRetentionPolicies() ([]helpers.RetentionPolicy, error) {
+	return selectRows(pdb, selectRetentionQuery, func(rows pgx.Rows) (helpers.RetentionPolicy, error) {
+		var policy helpers.RetentionPolicy
+		var retentionDays, compressDays int
+		err := rows.Scan(&policy.ChainID, &retentionDays, &compressDays)
+		policy.Retention = time.Duration(retentionDays) * 24 * time.Hour
+		policy.CompressAfter = time.Duration(compressDays) * 24 * time.Hour
+		return policy, err
+	})
+}
 
-	var rows pgx.Rows
-	if len(chainIDs) > 0 {
-		rows, err = pdb.db.Query(context.Background(), fmt.Sprintf(query, chainconfig), pq.Array(chainIDs))
-	} else {
-		rows, err = pdb.db.Query(context.Background(), fmt.Sprintf(query, chainconfig))
+// PruneOracleMetrics deletes the updates of a chain stored before a time and
+// returns how many were deleted. A hypertable is left to its retention
+// policy, which drops whole chunks instead of deleting rows.
+func (pdb *postgresDB) PruneOracleMetrics(chainID string, before time.Time) (int64, error) {
+	if pdb.hypertable {
+		return 0, nil
 	}
+	tag, err := pdb.db.Exec(context.Background(), pruneMetricsQuery, chainID, before.UTC())
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch data from the DB query: %v", err)
+		return 0, fmt.Errorf("failed to prune the metrics in the DB: %w", err)
 	}
+	return tag.RowsAffected(), nil
+}
 
-	for rows.Next() {
+// InsertFailedUpdates stores reverted transactions, skipping those already
+// stored.
+func (pdb *postgresDB) InsertFailedUpdates(failed []helpers.FailedUpdate) error {
+	if len(failed) == 0 {
+		return nil
+	}
 
-		var rpcurl, chainid string
-		err := rows.Scan(&rpcurl, &chainid)
+	batch := &pgx.Batch{}
+	for _, f := range failed {
+		block, err := strconv.ParseInt(f.BlockNumber, 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get the list of rpc from the DB: %v", err)
+			return fmt.Errorf("invalid block number %q of %s: %v", f.BlockNumber, f.TransactionHash, err)
+		}
+		keys := f.AssetKeys
+		if keys == nil {
+			keys = []string{}
 		}
-		rpc[chainid] = rpcurl
+		batch.Queue(insertFailedUpdateQuery, f.ChainID, f.TransactionTo.String(), f.TransactionHash, block, f.BlockTimestamp, f.TransactionFrom.String(), f.Method, keys, strconv.FormatUint(f.GasUsed, 10), numericValue(f.GasCost), numericValue(f.TransactionCost), f.RevertReason)
+	}
+
+	if err := pdb.db.SendBatch(context.Background(), batch).Close(); err != nil {
+		return fmt.Errorf("failed to insert failed updates in the DB: %w", err)
 	}
-	return rpc, nil
+	return nil
 }
 
-func (pdb *postgresDB) Close() {
-	pdb.db.Close()
+// InsertUncoveredRanges records block ranges whose reverted transactions
+// could not be looked for.
+func (pdb *postgresDB) InsertUncoveredRanges(ranges []helpers.UncoveredRange) error {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, r := range ranges {
+		batch.Queue(insertUncoveredRangeQuery, r.ChainID, int64(r.FromBlock), int64(r.ToBlock), time.Now())
+	}
+	if err := pdb.db.SendBatch(context.Background(), batch).Close(); err != nil {
+		return fmt.Errorf("failed to insert uncovered ranges in the DB: %w", err)
+	}
+	return nil
 }
 
-// SELECT address, chainid,  COALESCE(latest.scraped_block, 0) AS latest_scraped_block FROM oracleconfig LEFT JOIN (SELECT oracle_address,chain_id, MAX(update_block) AS scraped_block FROM feederupdates GROUP BY oracle_address,chain_id) latest ON oracleconfig.address = latest.oracle_address;
+// SelectFailureSummary counts the reverted transactions of every feeder and
+// oracle since a time.
+func (pdb *postgresDB) SelectFailureSummary(since time.Time) ([]helpers.FailureSummary, error) {
+	return selectRows(pdb, selectFailureSummaryQuery, func(rows pgx.Rows) (summary helpers.FailureSummary, err error) {
+		err = rows.Scan(&summary.ChainID, &summary.OracleAddress, &summary.Sender, &summary.Failures, &summary.LastFailure, &summary.LastReason)
+		return summary, err
+	}, since)
+}
+
+// InsertAccessEvents stores access control events, skipping those already
+// stored.
+func (pdb *postgresDB) InsertAccessEvents(events []helpers.AccessEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, e := range events {
+		batch.Queue(insertAccessEventQuery, e.ChainID, e.OracleAddress, e.TransactionHash, int64(e.LogIndex), int64(e.BlockNumber), e.BlockTimestamp, e.Kind, e.Method, e.PreviousAddress, e.NewAddress, e.Sender)
+	}
+
+	if err := pdb.db.SendBatch(context.Background(), batch).Close(); err != nil {
+		return fmt.Errorf("failed to insert access events in the DB: %w", err)
+	}
+	return nil
+}
+
+// DeleteAccessEvent deletes an access control event orphaned by a reorg.
+func (pdb *postgresDB) DeleteAccessEvent(chainID string, transactionHash string, logIndex uint) error {
+	_, err := pdb.db.Exec(context.Background(), deleteAccessEventQuery, chainID, transactionHash, int64(logIndex))
+	if err != nil {
+		return fmt.Errorf("failed to delete the access event in the DB: %w", err)
+	}
+	return nil
+}
+
+// SelectAccessEvents returns the access control events since a time in
+// chain order, empty chain and oracle match any.
+func (pdb *postgresDB) SelectAccessEvents(chainID string, oracleAddress string, since time.Time) ([]helpers.AccessEvent, error) {
+	return selectRows(pdb, selectAccessEventsQuery, func(rows pgx.Rows) (e helpers.AccessEvent, err error) {
+		var logIndex, block int64
+		err = rows.Scan(&e.ChainID, &e.OracleAddress, &e.TransactionHash, &logIndex, &block, &e.BlockTimestamp, &e.Kind, &e.Method, &e.PreviousAddress, &e.NewAddress, &e.Sender)
+		e.LogIndex, e.BlockNumber = uint(logIndex), uint64(block)
+		return e, err
+	}, chainID, oracleAddress, nullTime(since))
+}
+
+// SelectAuthorizedUpdaters returns the updaters the oracles currently accept,
+// empty chain and oracle match any.
+func (pdb *postgresDB) SelectAuthorizedUpdaters(chainID string, oracleAddress string) ([]helpers.AuthorizedUpdater, error) {
+	return selectRows(pdb, selectUpdatersQuery, func(rows pgx.Rows) (u helpers.AuthorizedUpdater, err error) {
+		var block int64
+		err = rows.Scan(&u.ChainID, &u.OracleAddress, &u.Updater, &block, &u.SinceTime)
+		u.SinceBlock = uint64(block)
+		return u, err
+	}, chainID, oracleAddress)
+}
+
+// SelectAssetCatalogue returns the declared asset keys, empty chain and
+// oracle match any.
+func (pdb *postgresDB) SelectAssetCatalogue(chainID string, oracleAddress string) ([]helpers.CatalogueEntry, error) {
+	return selectRows(pdb, selectCatalogueQuery, func(rows pgx.Rows) (e helpers.CatalogueEntry, err error) {
+		var decimals int32
+		var seconds int64
+		err = rows.Scan(&e.ChainID, &e.OracleAddress, &e.AssetKey, &e.Symbol, &decimals, &seconds, &e.DeviationPercent)
+		e.Decimals = int(decimals)
+		e.Heartbeat = time.Duration(seconds) * time.Second
+		return e, err
+	}, chainID, oracleAddress)
+}
+
+// SelectUpdaterAllowlist returns the configured expected updaters.
+func (pdb *postgresDB) SelectUpdaterAllowlist() ([]helpers.AllowedUpdater, error) {
+	return selectRows(pdb, selectAllowlistQuery, func(rows pgx.Rows) (u helpers.AllowedUpdater, err error) {
+		err = rows.Scan(&u.ChainID, &u.OracleAddress, &u.Updater, &u.Label)
+		return u, err
+	})
+}
+
+// SelectOracleSenders returns every wallet that updated the oracles, oldest
+// first, empty chain and oracle match any.
+func (pdb *postgresDB) SelectOracleSenders(chainID string, oracleAddress string) ([]helpers.OracleSender, error) {
+	return selectRows(pdb, selectOracleSendersQuery, func(rows pgx.Rows) (s helpers.OracleSender, err error) {
+		var updates int64
+		err = rows.Scan(&s.ChainID, &s.OracleAddress, &s.Sender, &updates, &s.FirstUpdate, &s.LastUpdate)
+		s.Updates = uint64(updates)
+		return s, err
+	}, chainID, oracleAddress)
+}
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+// testBackend opens an empty database of one backend.
+type testBackend struct {
+	name string
+	open func(t *testing.T) Database
+}
+
+// testBackends returns the backends the query tests run against: the memory
+// backend, and postgres when TEST_DATABASE_URL names a server the tests may
+// create schemas on.
+func testBackends(t *testing.T) []testBackend {
+	backends := []testBackend{{"memory", func(t *testing.T) Database { return NewMemoryDB("", false, 0) }}}
+	if os.Getenv("TEST_DATABASE_URL") != "" {
+		backends = append(backends, testBackend{"postgres", func(t *testing.T) Database { return newTestPostgres(t, TimescaleOff) }})
+	}
+	return backends
+}
+
+// newTestPostgres migrates a schema of its own on the TEST_DATABASE_URL
+// server and drops it when the test ends.
+func newTestPostgres(t *testing.T, timescale string) *postgresDB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("failed to create schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+	}
+	// extensions such as timescaledb live in public
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	hypertable, err := migrate(ctx, pool, timescale)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return &postgresDB{db: pool, hypertable: hypertable}
+}
+
+// exec runs setup statements the Database interface has no method for.
+func (pdb *postgresDB) exec(t *testing.T, statements ...string) {
+	t.Helper()
+	for _, statement := range statements {
+		if _, err := pdb.db.Exec(context.Background(), statement); err != nil {
+			t.Fatalf("failed to run %q: %v", statement, err)
+		}
+	}
+}
+
+func TestPostgresLegacyUpdates(t *testing.T) {
+	db := newTestPostgres(t, TimescaleOff)
+
+	// rows stored before log indexes were recorded
+	if err := db.InsertOracleMetricsBatch([]helpers.OracleMetrics{testUpdate("0xa", "BTC/USD", 1, 0, 1)}); err != nil {
+		t.Fatalf("InsertOracleMetricsBatch: %v", err)
+	}
+	db.exec(t, `UPDATE feederupdates SET log_index = -1`)
+
+	tests := []struct {
+		name   string
+		update helpers.OracleMetrics
+		want   int
+	}{
+		{"rescan of a legacy row", testUpdate("0xa", "BTC/USD", 1, 4, 1), 1},
+		{"other key of the transaction", testUpdate("0xa", "ETH/USD", 1, 5, 1), 2},
+		{"new transaction", testUpdate("0xb", "BTC/USD", 2, 0, 1), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.InsertOracleMetricsBatch([]helpers.OracleMetrics{tt.update}); err != nil {
+				t.Fatalf("InsertOracleMetricsBatch: %v", err)
+			}
+			updates, err := db.SelectUpdates(helpers.UpdateFilter{ChainID: "1", OracleAddress: testOracle.Hex(), Limit: 100})
+			if err != nil {
+				t.Fatalf("SelectUpdates: %v", err)
+			}
+			if len(updates) != tt.want {
+				t.Errorf("got %d updates, want %d", len(updates), tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresRules(t *testing.T) {
+	db := newTestPostgres(t, TimescaleOff)
+	db.exec(t,
+		`INSERT INTO heartbeatconfig (chain_id, oracle_address, asset_key, heartbeat_seconds) VALUES ('1', '', 'BTC/USD', 60)`,
+		`INSERT INTO assetcatalogue (chain_id, oracle_address, asset_key, heartbeat_seconds) VALUES ('1', '`+testOracle.Hex()+`', 'BTC/USD', 120), ('1', '`+testOracle.Hex()+`', 'ETH/USD', 0)`,
+		`INSERT INTO deviationconfig (chain_id, asset_key, max_jump_percent) VALUES ('1', 'BTC/USD', 5)`,
+		`INSERT INTO deviationconfig (chain_id, asset_key, max_jump_percent, decimals) VALUES ('1', 'ETH/USD', 5, 8)`,
+	)
+
+	heartbeats, err := db.SelectHeartbeatRules()
+	if err != nil {
+		t.Fatalf("SelectHeartbeatRules: %v", err)
+	}
+	if len(heartbeats) != 2 || heartbeats[0].Interval != time.Minute || heartbeats[1].Interval != 2*time.Minute {
+		t.Errorf("got heartbeat rules %+v, want the configured one before the catalogue one", heartbeats)
+	}
+
+	deviations, err := db.SelectDeviationRules()
+	if err != nil {
+		t.Fatalf("SelectDeviationRules: %v", err)
+	}
+	decimals := make(map[string]int)
+	for _, rule := range deviations {
+		decimals[rule.AssetKey] = rule.Decimals
+	}
+	if len(deviations) != 2 || decimals["BTC/USD"] != 0 || decimals["ETH/USD"] != 8 {
+		t.Errorf("got deviation rules %+v, want unset and explicit decimals", deviations)
+	}
+
+	catalogue, err := db.SelectAssetCatalogue("1", testOracle.Hex())
+	if err != nil {
+		t.Fatalf("SelectAssetCatalogue: %v", err)
+	}
+	for _, entry := range catalogue {
+		if entry.Decimals != 0 {
+			t.Errorf("catalogue key %s has decimals %d it never declared", entry.AssetKey, entry.Decimals)
+		}
+	}
+}
+
+func TestPostgresChainConfigs(t *testing.T) {
+	db := newTestPostgres(t, TimescaleOff)
+	db.exec(t,
+		`INSERT INTO chainconfig (chainid, rpcurl, wsurl, confirmations) VALUES ('1', 'http://a', 'ws://a', 5), ('2', 'http://c', '', 0)`,
+		`INSERT INTO chainendpoints (chainid, kind, url, priority) VALUES ('1', 'rpc', 'http://b', 2), ('1', 'ws', 'ws://b', 1), ('3', 'rpc', 'http://d', 1)`,
+	)
+
+	tests := []struct {
+		name     string
+		chainIDs []string
+		want     map[string][2]int
+	}{
+		{"every chain", nil, map[string][2]int{"1": {2, 2}, "2": {1, 0}}},
+		{"one chain", []string{"2"}, map[string][2]int{"2": {1, 0}}},
+		{"unknown chain", []string{"3"}, map[string][2]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := db.GetChainConfigs(tt.chainIDs)
+			if err != nil {
+				t.Fatalf("GetChainConfigs: %v", err)
+			}
+			if len(configs) != len(tt.want) {
+				t.Fatalf("got chains %+v, want %v", configs, tt.want)
+			}
+			for chainID, endpoints := range tt.want {
+				config := configs[chainID]
+				if len(config.RPC) != endpoints[0] || len(config.WS) != endpoints[1] {
+					t.Errorf("chain %s has %d rpc and %d ws endpoints, want %v", chainID, len(config.RPC), len(config.WS), endpoints)
+				}
+			}
+			if config, ok := configs["1"]; ok && (config.Confirmations != 5 || config.RPC[0].URL != "http://a") {
+				t.Errorf("chain 1 is %+v, want 5 confirmations and its chainconfig url first", config)
+			}
+		})
+	}
+}
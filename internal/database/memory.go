@@ -0,0 +1,994 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+// MemorySeed is the content of the file a memory database starts from.
+type MemorySeed struct {
+	Chains []struct {
+		ChainID       string   `json:"chain-id"`
+		RPC           []string `json:"rpc"`
+		WS            []string `json:"ws"`
+		Confirmations uint64   `json:"confirmations"`
+		HeadQuorum    int      `json:"head-quorum"`
+	} `json:"chains"`
+	Oracles        []helpers.Target         `json:"oracles"`
+	HeartbeatRules []helpers.HeartbeatRule  `json:"heartbeat-rules"`
+	DeviationRules []helpers.DeviationRule  `json:"deviation-rules"`
+	Allowlist      []helpers.AllowedUpdater `json:"updater-allowlist"`
+	Catalogue      []helpers.CatalogueEntry `json:"asset-catalogue"`
+}
+
+// defaultMemoryRows is the number of updates, failed updates, findings and
+// balances the memory backend keeps of each when MEMORY_MAX_ROWS is not set.
+const defaultMemoryRows = 100000
+
+// memoryDB keeps everything in process memory. It mirrors the queries of
+// postgresDB closely enough for the scrapers, alerts and API to run against
+// it, and logs every write when dryRun is set. The key sets mirror the unique
+// indexes so inserts do not scan the stored rows.
+type memoryDB struct {
+	mu       sync.RWMutex
+	seedFile string
+	dryRun   bool
+	maxRows  int
+
+	chains         map[string]helpers.ChainConfig
+	oracles        map[string]helpers.Target
+	metrics        []helpers.OracleMetrics
+	states         map[string]helpers.OracleMetricsState
+	alerts         map[string]helpers.Alert
+	findings       []helpers.Finding
+	failed         []helpers.FailedUpdate
+	access         []helpers.AccessEvent
+	balances       []helpers.FeederBalance
+	uncovered      []helpers.UncoveredRange
+	heartbeatRules []helpers.HeartbeatRule
+	deviationRules []helpers.DeviationRule
+	allowlist      []helpers.AllowedUpdater
+	catalogue      []helpers.CatalogueEntry
+
+	metricKeys    map[metricKey]bool
+	failedKeys    map[failedKey]bool
+	findingKeys   map[findingKey]bool
+	accessKeys    map[accessKey]bool
+	uncoveredKeys map[helpers.UncoveredRange]bool
+}
+
+// metricKey and the keys below mirror the unique indexes of the postgres
+// tables, so both backends skip the same rows.
+type metricKey struct {
+	transactionHash, assetKey string
+	updateTime                int64
+	logIndex                  uint
+}
+
+type failedKey struct {
+	chainID, transactionHash string
+}
+
+type findingKey struct {
+	chainID, transactionHash, assetKey, kind string
+}
+
+type accessKey struct {
+	chainID, transactionHash string
+	logIndex                 uint
+}
+
+func metricKeyOf(metrics helpers.OracleMetrics) metricKey {
+	return metricKey{metrics.TransactionHash, metrics.AssetKey, metrics.BlockTimestamp.UnixNano(), metrics.LogIndex}
+}
+
+func findingKeyOf(finding helpers.Finding) findingKey {
+	return findingKey{finding.ChainID, finding.TransactionHash, finding.AssetKey, finding.Kind}
+}
+
+// NewMemoryDB creates a Database held in memory, seeded from seedFile when it
+// is not empty. With dryRun every write is also logged. Past maxRows updates,
+// failed updates, findings or balances the oldest ones are dropped, zero
+// means defaultMemoryRows.
+func NewMemoryDB(seedFile string, dryRun bool, maxRows int) Database {
+	if maxRows <= 0 {
+		maxRows = defaultMemoryRows
+	}
+	return &memoryDB{
+		seedFile:      seedFile,
+		dryRun:        dryRun,
+		maxRows:       maxRows,
+		chains:        make(map[string]helpers.ChainConfig),
+		oracles:       make(map[string]helpers.Target),
+		states:        make(map[string]helpers.OracleMetricsState),
+		alerts:        make(map[string]helpers.Alert),
+		metricKeys:    make(map[metricKey]bool),
+		failedKeys:    make(map[failedKey]bool),
+		findingKeys:   make(map[findingKey]bool),
+		accessKeys:    make(map[accessKey]bool),
+		uncoveredKeys: make(map[helpers.UncoveredRange]bool),
+	}
+}
+
+// NewDatabase returns the backend selected by DB_BACKEND, postgres unless it
+// is set to memory. DRY_RUN=true selects the memory backend and logs every
+// write, DB_SEED_FILE seeds the memory backend and MEMORY_MAX_ROWS bounds it.
+// A dry run needs the seed, without chains and oracles it monitors nothing.
+func NewDatabase() (Database, error) {
+	dryRun := false
+	if value := os.Getenv("DRY_RUN"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid DRY_RUN: %v", err)
+		}
+	}
+	maxRows := 0
+	if value := os.Getenv("MEMORY_MAX_ROWS"); value != "" {
+		var err error
+		if maxRows, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid MEMORY_MAX_ROWS: %v", err)
+		}
+	}
+
+	if dryRun && os.Getenv("DB_SEED_FILE") == "" {
+		return nil, fmt.Errorf("DRY_RUN needs DB_SEED_FILE to provide the chains and oracles to monitor")
+	}
+
+	switch backend := os.Getenv("DB_BACKEND"); {
+	case dryRun || backend == "memory":
+		return NewMemoryDB(os.Getenv("DB_SEED_FILE"), dryRun, maxRows), nil
+	case backend == "" || backend == "postgres":
+		return NewPostgresDB(), nil
+	default:
+		return nil, fmt.Errorf("unknown DB_BACKEND %q", backend)
+	}
+}
+
+func oracleKey(chainID, address string) string {
+	return chainID + ":" + strings.ToLower(address)
+}
+
+func (m *memoryDB) logWrite(format string, args ...interface{}) {
+	if m.dryRun {
+		log.Printf("dry run: "+format, args...)
+	}
+}
+
+func (m *memoryDB) Connect() error {
+	if m.seedFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(m.seedFile)
+	if err != nil {
+		return fmt.Errorf("failed to open the seed file: %v", err)
+	}
+	var seed MemorySeed
+	if err := json.Unmarshal(data, &seed); err != nil {
+		return fmt.Errorf("failed to parse the JSON in the seed file: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, chain := range seed.Chains {
+		config := helpers.ChainConfig{ChainID: chain.ChainID, Confirmations: chain.Confirmations, HeadQuorum: chain.HeadQuorum}
+		for i, url := range chain.RPC {
+			config.RPC = append(config.RPC, helpers.Endpoint{URL: url, Priority: i})
+		}
+		for i, url := range chain.WS {
+			config.WS = append(config.WS, helpers.Endpoint{URL: url, Priority: i})
+		}
+		if config.HeadQuorum < 1 {
+			config.HeadQuorum = 1
+		}
+		m.chains[chain.ChainID] = config
+	}
+	for _, target := range seed.Oracles {
+		if target.CreatedDate.IsZero() {
+			target.CreatedDate = time.Now()
+		}
+		m.oracles[oracleKey(target.ChainId, target.ContractAddress)] = target
+	}
+	m.heartbeatRules = seed.HeartbeatRules
+	m.deviationRules = seed.DeviationRules
+	m.allowlist = seed.Allowlist
+	m.catalogue = seed.Catalogue
+	return nil
+}
+
+func (m *memoryDB) InsertOracles(targets []helpers.Target) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, target := range targets {
+		if target.CreatedDate.IsZero() {
+			target.CreatedDate = time.Now()
+		}
+		m.oracles[oracleKey(target.ChainId, target.ContractAddress)] = target
+		m.logWrite("insert oracle %s chain %s", target.ContractAddress, target.ChainId)
+	}
+	return nil
+}
+
+func (m *memoryDB) UpdateOracleCreation(address string, block string, blocktime time.Time, chainid string) error {
+	creationBlock, err := strconv.ParseUint(block, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to update the creation block: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := oracleKey(chainid, address)
+	if target, ok := m.oracles[key]; ok {
+		target.CreationBlock = creationBlock
+		m.oracles[key] = target
+	}
+	m.logWrite("update oracle %s chain %s creation block %s at %s", address, chainid, block, blocktime)
+	return nil
+}
+
+// selectOracles returns the oracles of a chain created after since, with the
+// latest block an update was stored for.
+func (m *memoryDB) selectOracles(chainID string, since time.Time) []helpers.Target {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latest := make(map[string]uint64)
+	for _, metrics := range m.metrics {
+		if metrics.ChainID != chainID {
+			continue
+		}
+		key := oracleKey(chainID, metrics.TransactionTo.Hex())
+		if block := blockOf(metrics); block > latest[key] {
+			latest[key] = block
+		}
+	}
+
+	targets := []helpers.Target{}
+	for key, target := range m.oracles {
+		if target.ChainId != chainID || !target.CreatedDate.After(since) {
+			continue
+		}
+		target.LatestScrapedBlock = latest[key]
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ContractAddress < targets[j].ContractAddress })
+	return targets
+}
+
+func (m *memoryDB) SelectOracles(chainID string) ([]helpers.Target, error) {
+	return m.selectOracles(chainID, time.Time{}), nil
+}
+
+func (m *memoryDB) SelectOraclesWithCreationTime(chainID string, lastCreatedTime time.Time) ([]helpers.Target, error) {
+	return m.selectOracles(chainID, lastCreatedTime), nil
+}
+
+func (m *memoryDB) InsertOracleMetrics(metrics *helpers.OracleMetrics) error {
+	return m.InsertOracleMetricsBatch([]helpers.OracleMetrics{*metrics})
+}
+
+// InsertOracleMetricsBatch skips updates already stored for their transaction
+// and log, like the unique index of feederupdates.
+func (m *memoryDB) InsertOracleMetricsBatch(metrics []helpers.OracleMetrics) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, update := range metrics {
+		key := metricKeyOf(update)
+		if m.metricKeys[key] {
+			continue
+		}
+		m.metricKeys[key] = true
+		update.CreationBlock = "0"
+		m.metrics = append(m.metrics, update)
+		m.logWrite("insert update %s chain %s oracle %s key %s value %s block %s", update.TransactionHash, update.ChainID, update.TransactionTo.Hex(), update.AssetKey, update.AssetPrice, update.BlockNumber)
+	}
+	m.metrics = dropOldest(m.metrics, m.maxRows, func(dropped helpers.OracleMetrics) {
+		delete(m.metricKeys, metricKeyOf(dropped))
+	})
+	return nil
+}
+
+func (m *memoryDB) GetRPCByChainID(chainIDs []string) (map[string]string, error) {
+	configs, _ := m.GetChainConfigs(chainIDs)
+	urls := make(map[string]string, len(configs))
+	for chainID, config := range configs {
+		if len(config.RPC) > 0 {
+			urls[chainID] = config.RPC[0].URL
+		}
+	}
+	return urls, nil
+}
+
+func (m *memoryDB) GetWSByChainID(chainIDs []string) (map[string]string, error) {
+	configs, _ := m.GetChainConfigs(chainIDs)
+	urls := make(map[string]string, len(configs))
+	for chainID, config := range configs {
+		if len(config.WS) > 0 {
+			urls[chainID] = config.WS[0].URL
+		}
+	}
+	return urls, nil
+}
+
+func (m *memoryDB) GetChainConfigs(chainIDs []string) (map[string]helpers.ChainConfig, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	configs := make(map[string]helpers.ChainConfig)
+	for chainID, config := range m.chains {
+		if len(chainIDs) == 0 || contains(chainIDs, chainID) {
+			configs[chainID] = config
+		}
+	}
+	return configs, nil
+}
+
+func (m *memoryDB) GetState(chainID string) (helpers.OracleMetricsState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if state, ok := m.states[chainID]; ok {
+		return state, nil
+	}
+	return helpers.OracleMetricsState{ChainID: chainID}, nil
+}
+
+func (m *memoryDB) SetState(state helpers.OracleMetricsState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.states[state.ChainID] = state
+	m.logWrite("set checkpoint of chain %s to block %d %s", state.ChainID, state.LastBlock, state.LastBlockHash)
+	return nil
+}
+
+func (m *memoryDB) RollbackOracleMetrics(chainID string, block uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orphaned := make(map[string]bool)
+	m.removeMetrics(func(metrics helpers.OracleMetrics) bool {
+		if metrics.ChainID != chainID || blockOf(metrics) <= block {
+			return false
+		}
+		orphaned[metrics.TransactionHash] = true
+		return true
+	})
+	keptFindings := m.findings[:0]
+	for _, finding := range m.findings {
+		if finding.ChainID != chainID || !orphaned[finding.TransactionHash] {
+			keptFindings = append(keptFindings, finding)
+		} else {
+			delete(m.findingKeys, findingKeyOf(finding))
+		}
+	}
+	m.findings = keptFindings
+	kept := m.failed[:0]
+	for _, failed := range m.failed {
+		if number, _ := strconv.ParseUint(failed.BlockNumber, 10, 64); failed.ChainID != chainID || number <= block {
+			kept = append(kept, failed)
+		} else {
+			delete(m.failedKeys, failedKey{failed.ChainID, failed.TransactionHash})
+		}
+	}
+	m.failed = kept
+	keptAccess := m.access[:0]
+	for _, access := range m.access {
+		if access.ChainID != chainID || access.BlockNumber <= block {
+			keptAccess = append(keptAccess, access)
+		} else {
+			delete(m.accessKeys, accessKey{access.ChainID, access.TransactionHash, access.LogIndex})
+		}
+	}
+	m.access = keptAccess
+	m.logWrite("roll back updates of chain %s above block %d", chainID, block)
+	return nil
+}
+
+func (m *memoryDB) DeleteOracleMetrics(chainID string, transactionHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeMetrics(func(metrics helpers.OracleMetrics) bool {
+		return metrics.ChainID == chainID && metrics.TransactionHash == transactionHash
+	})
+	m.logWrite("delete updates of transaction %s chain %s", transactionHash, chainID)
+	return nil
+}
+
+func (m *memoryDB) removeMetrics(remove func(helpers.OracleMetrics) bool) {
+	kept := m.metrics[:0]
+	for _, metrics := range m.metrics {
+		if !remove(metrics) {
+			kept = append(kept, metrics)
+		} else {
+			delete(m.metricKeys, metricKeyOf(metrics))
+		}
+	}
+	m.metrics = kept
+}
+
+// dropOldest drops the oldest rows once there are more than max of them.
+// It drops a tenth more than needed so the copy is not paid on every insert.
+func dropOldest[T any](rows []T, max int, dropped func(T)) []T {
+	if len(rows) <= max {
+		return rows
+	}
+	drop := len(rows) - max + max/10
+	for _, row := range rows[:drop] {
+		dropped(row)
+	}
+	return append(rows[:0], rows[drop:]...)
+}
+
+func (m *memoryDB) SelectLatestUpdates() ([]helpers.AssetUpdate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latest := make(map[string]helpers.AssetUpdate)
+	for _, metrics := range m.metrics {
+		key := oracleKey(metrics.ChainID, metrics.TransactionTo.Hex()) + ":" + metrics.AssetKey
+		update := latest[key]
+		update.ChainID = metrics.ChainID
+		update.OracleAddress = metrics.TransactionTo.Hex()
+		update.AssetKey = metrics.AssetKey
+		if block := blockOf(metrics); block > update.UpdateBlock {
+			update.UpdateBlock = block
+		}
+		if metrics.BlockTimestamp.After(update.UpdateTime) {
+			update.UpdateTime = metrics.BlockTimestamp
+		}
+		latest[key] = update
+	}
+
+	updates := make([]helpers.AssetUpdate, 0, len(latest))
+	for _, update := range latest {
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+// SelectHeartbeatRules returns the seeded rules followed by those of the
+// catalogue, like the heartbeat rules query.
+func (m *memoryDB) SelectHeartbeatRules() ([]helpers.HeartbeatRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := append([]helpers.HeartbeatRule{}, m.heartbeatRules...)
+	for _, entry := range m.catalogue {
+		if entry.Heartbeat > 0 {
+			rules = append(rules, helpers.HeartbeatRule{ChainID: entry.ChainID, OracleAddress: entry.OracleAddress, AssetKey: entry.AssetKey, Interval: entry.Heartbeat})
+		}
+	}
+	return rules, nil
+}
+
+func (m *memoryDB) SelectActiveAlerts() ([]helpers.Alert, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	alerts := []helpers.Alert{}
+	for _, alert := range m.alerts {
+		if alert.Firing {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+func (m *memoryDB) UpsertAlert(alert helpers.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.alerts[alert.Key] = alert
+	m.logWrite("store alert %s firing %t", alert.Key, alert.Firing)
+	return nil
+}
+
+func (m *memoryDB) SelectDeviationRules() ([]helpers.DeviationRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]helpers.DeviationRule{}, m.deviationRules...), nil
+}
+
+func (m *memoryDB) SelectPreviousPrice(chainID string, oracleAddress string, assetKey string, block uint64) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var price string
+	var priceBlock uint64
+	for _, metrics := range m.metrics {
+		if metrics.ChainID != chainID || !strings.EqualFold(metrics.TransactionTo.Hex(), oracleAddress) || metrics.AssetKey != assetKey {
+			continue
+		}
+		if metrics.AssetPrice == nil {
+			continue
+		}
+		if b := blockOf(metrics); b < block && (price == "" || b > priceBlock) {
+			price, priceBlock = metrics.AssetPrice.String(), b
+		}
+	}
+	return price, nil
+}
+
+func (m *memoryDB) InsertFinding(finding helpers.Finding) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := findingKeyOf(finding)
+	if m.findingKeys[key] {
+		return nil
+	}
+	m.findingKeys[key] = true
+	m.findings = append(m.findings, finding)
+	m.findings = dropOldest(m.findings, m.maxRows, func(dropped helpers.Finding) {
+		delete(m.findingKeys, findingKeyOf(dropped))
+	})
+	m.logWrite("insert %s finding for %s key %s: %s", finding.Kind, finding.TransactionHash, finding.AssetKey, finding.Message)
+	return nil
+}
+
+func (m *memoryDB) SelectFeeders() ([]helpers.Feeder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[helpers.Feeder]bool)
+	feeders := []helpers.Feeder{}
+	for _, metrics := range m.metrics {
+		feeder := helpers.Feeder{ChainID: metrics.ChainID, Address: metrics.TransactionFrom.String()}
+		if !seen[feeder] {
+			seen[feeder] = true
+			feeders = append(feeders, feeder)
+		}
+	}
+	return feeders, nil
+}
+
+func (m *memoryDB) InsertFeederBalance(balance helpers.FeederBalance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.balances = append(m.balances, balance)
+	m.balances = dropOldest(m.balances, m.maxRows, func(helpers.FeederBalance) {})
+	m.logWrite("insert balance %s of feeder %s chain %s", balance.Balance, balance.Address, balance.ChainID)
+	return nil
+}
+
+func (m *memoryDB) SelectFeederSpend(feeder helpers.Feeder, since time.Time) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	spent := new(big.Int)
+	for _, metrics := range m.metrics {
+		if metrics.ChainID != feeder.ChainID || metrics.TransactionFrom.String() != feeder.Address || metrics.BlockTimestamp.Before(since) {
+			continue
+		}
+		if metrics.TransactionCost != nil {
+			spent.Add(spent, metrics.TransactionCost)
+		}
+	}
+	// reverted transactions burn gas too
+	for _, failed := range m.failed {
+		if failed.ChainID != feeder.ChainID || failed.TransactionFrom.String() != feeder.Address || failed.BlockTimestamp.Before(since) {
+			continue
+		}
+		if failed.TransactionCost != nil {
+			spent.Add(spent, failed.TransactionCost)
+		}
+	}
+	return spent.String(), nil
+}
+
+func (m *memoryDB) SelectLatestValues(chainID string, oracleAddress string) ([]helpers.OracleMetrics, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latest := make(map[string]helpers.OracleMetrics)
+	for _, metrics := range m.oracleMetrics(chainID, oracleAddress) {
+		if current, ok := latest[metrics.AssetKey]; !ok || blockOf(metrics) > blockOf(current) {
+			latest[metrics.AssetKey] = metrics
+		}
+	}
+
+	values := make([]helpers.OracleMetrics, 0, len(latest))
+	for _, metrics := range latest {
+		values = append(values, metrics)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].AssetKey < values[j].AssetKey })
+	return values, nil
+}
+
+func (m *memoryDB) SelectUpdates(filter helpers.UpdateFilter) ([]helpers.OracleMetrics, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	updates := []helpers.OracleMetrics{}
+	for _, metrics := range m.oracleMetrics(filter.ChainID, filter.OracleAddress) {
+		if filter.AssetKey != "" && metrics.AssetKey != filter.AssetKey {
+			continue
+		}
+		if !inRange(metrics.BlockTimestamp, filter.From, filter.To) {
+			continue
+		}
+		updates = append(updates, metrics)
+	}
+
+	sort.Slice(updates, func(i, j int) bool {
+		if bi, bj := blockOf(updates[i]), blockOf(updates[j]); bi != bj {
+			return bi > bj
+		}
+		return updates[i].AssetKey < updates[j].AssetKey
+	})
+
+	if filter.Offset >= len(updates) {
+		return []helpers.OracleMetrics{}, nil
+	}
+	updates = updates[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(updates) {
+		updates = updates[:filter.Limit]
+	}
+	return updates, nil
+}
+
+func (m *memoryDB) SelectOracleCost(chainID string, oracleAddress string, from time.Time, to time.Time) (helpers.OracleCost, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cost := helpers.OracleCost{ChainID: chainID, OracleAddress: oracleAddress}
+	totalCost, totalGas := new(big.Int), new(big.Int)
+	counted := make(map[string]bool)
+
+	for _, metrics := range m.oracleMetrics(chainID, oracleAddress) {
+		if !inRange(metrics.BlockTimestamp, from, to) {
+			continue
+		}
+		cost.Updates++
+		if cost.FirstUpdate.IsZero() || metrics.BlockTimestamp.Before(cost.FirstUpdate) {
+			cost.FirstUpdate = metrics.BlockTimestamp
+		}
+		if metrics.BlockTimestamp.After(cost.LastUpdate) {
+			cost.LastUpdate = metrics.BlockTimestamp
+		}
+
+		if !counted[metrics.TransactionHash] {
+			counted[metrics.TransactionHash] = true
+			cost.Transactions++
+		}
+		if metrics.TransactionCost != nil {
+			totalCost.Add(totalCost, metrics.TransactionCost)
+		}
+		totalGas.Add(totalGas, new(big.Int).SetUint64(metrics.GasUsed))
+	}
+
+	cost.TotalCost = totalCost.String()
+	cost.AverageCost = "0"
+	if cost.Transactions > 0 {
+		cost.AverageCost = new(big.Int).Div(totalCost, new(big.Int).SetUint64(cost.Transactions)).String()
+	}
+	cost.TotalGasUsed = totalGas.String()
+	return cost, nil
+}
+
+func (m *memoryDB) SelectChainLastUpdate(chainID string) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var last time.Time
+	for _, metrics := range m.metrics {
+		if metrics.ChainID == chainID && metrics.BlockTimestamp.After(last) {
+			last = metrics.BlockTimestamp
+		}
+	}
+	return last, nil
+}
+
+func (m *memoryDB) Close() {}
+
+// oracleMetrics returns the stored updates of an oracle, the caller holds
+// the lock.
+func (m *memoryDB) oracleMetrics(chainID string, oracleAddress string) []helpers.OracleMetrics {
+	var updates []helpers.OracleMetrics
+	for _, metrics := range m.metrics {
+		if metrics.ChainID == chainID && strings.EqualFold(metrics.TransactionTo.Hex(), oracleAddress) {
+			updates = append(updates, metrics)
+		}
+	}
+	return updates
+}
+
+func blockOf(metrics helpers.OracleMetrics) uint64 {
+	block, _ := strconv.ParseUint(metrics.BlockNumber, 10, 64)
+	return block
+}
+
+// inRange reports whether t lies in [from, to), zero bounds are open.
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// The memory backend is bounded by maxRows instead of retention policies.
+func (m *memoryDB) SelectRetentionPolicies() ([]helpers.RetentionPolicy, error) {
+	return nil, nil
+}
+
+func (m *memoryDB) PruneOracleMetrics(chainID string, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := len(m.metrics)
+	m.removeMetrics(func(metrics helpers.OracleMetrics) bool {
+		return metrics.ChainID == chainID && metrics.BlockTimestamp.Before(before)
+	})
+	pruned := int64(count - len(m.metrics))
+	m.logWrite("prune %d updates of chain %s before %s", pruned, chainID, before)
+	return pruned, nil
+}
+
+func (m *memoryDB) InsertFailedUpdates(failed []helpers.FailedUpdate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range failed {
+		key := failedKey{f.ChainID, f.TransactionHash}
+		if m.failedKeys[key] {
+			continue
+		}
+		m.failedKeys[key] = true
+		m.failed = append(m.failed, f)
+		m.logWrite("insert failed update %s chain %s oracle %s method %s: %s", f.TransactionHash, f.ChainID, f.TransactionTo.Hex(), f.Method, f.RevertReason)
+	}
+	m.failed = dropOldest(m.failed, m.maxRows, func(dropped helpers.FailedUpdate) {
+		delete(m.failedKeys, failedKey{dropped.ChainID, dropped.TransactionHash})
+	})
+	return nil
+}
+
+func (m *memoryDB) SelectFailureSummary(since time.Time) ([]helpers.FailureSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	summaries := make(map[[3]string]*helpers.FailureSummary)
+	var order [][3]string
+	for _, f := range m.failed {
+		if f.BlockTimestamp.Before(since) {
+			continue
+		}
+		key := [3]string{f.ChainID, f.TransactionTo.String(), f.TransactionFrom.String()}
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &helpers.FailureSummary{ChainID: key[0], OracleAddress: key[1], Sender: key[2]}
+			summaries[key] = summary
+			order = append(order, key)
+		}
+		summary.Failures++
+		if !f.BlockTimestamp.Before(summary.LastFailure) {
+			summary.LastFailure = f.BlockTimestamp
+			summary.LastReason = f.RevertReason
+		}
+	}
+
+	result := []helpers.FailureSummary{}
+	for _, key := range order {
+		result = append(result, *summaries[key])
+	}
+	return result, nil
+}
+
+func (m *memoryDB) InsertAccessEvents(events []helpers.AccessEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range events {
+		key := accessKey{e.ChainID, e.TransactionHash, e.LogIndex}
+		if m.accessKeys[key] {
+			continue
+		}
+		m.accessKeys[key] = true
+		m.access = append(m.access, e)
+		m.logWrite("insert %s of oracle %s chain %s to %s in %s", e.Kind, e.OracleAddress, e.ChainID, e.NewAddress, e.TransactionHash)
+	}
+	return nil
+}
+
+func (m *memoryDB) DeleteAccessEvent(chainID string, transactionHash string, logIndex uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := accessKey{chainID, transactionHash, logIndex}
+	if m.accessKeys[key] {
+		delete(m.accessKeys, key)
+		for i, e := range m.access {
+			if e.ChainID == chainID && e.TransactionHash == transactionHash && e.LogIndex == logIndex {
+				m.access = append(m.access[:i], m.access[i+1:]...)
+				break
+			}
+		}
+	}
+	m.logWrite("delete access event %s:%d chain %s", transactionHash, logIndex, chainID)
+	return nil
+}
+
+func (m *memoryDB) SelectAccessEvents(chainID string, oracleAddress string, since time.Time) ([]helpers.AccessEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var oracleEvents []helpers.AccessEvent
+	for _, e := range m.access {
+		if (chainID == "" || e.ChainID == chainID) && (oracleAddress == "" || strings.EqualFold(e.OracleAddress, oracleAddress)) {
+			oracleEvents = append(oracleEvents, e)
+		}
+	}
+	sort.SliceStable(oracleEvents, func(i, j int) bool {
+		if oracleEvents[i].BlockNumber != oracleEvents[j].BlockNumber {
+			return oracleEvents[i].BlockNumber < oracleEvents[j].BlockNumber
+		}
+		return oracleEvents[i].LogIndex < oracleEvents[j].LogIndex
+	})
+
+	events := []helpers.AccessEvent{}
+	for _, e := range classifyAccess(oracleEvents) {
+		if !e.BlockTimestamp.Before(since) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m *memoryDB) InsertUncoveredRanges(ranges []helpers.UncoveredRange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range ranges {
+		if m.uncoveredKeys[r] {
+			continue
+		}
+		m.uncoveredKeys[r] = true
+		m.uncovered = append(m.uncovered, r)
+		m.logWrite("record uncovered blocks %d-%d chain %s", r.FromBlock, r.ToBlock, r.ChainID)
+	}
+	m.uncovered = dropOldest(m.uncovered, m.maxRows, func(dropped helpers.UncoveredRange) {
+		delete(m.uncoveredKeys, dropped)
+	})
+	return nil
+}
+
+// classifyAccess tells the updater additions and removals of ordered events
+// apart like the classifiedaccessevents view: the logs naming an address
+// alternate between adding and removing it.
+func classifyAccess(events []helpers.AccessEvent) []helpers.AccessEvent {
+	seen := make(map[[3]string]int)
+	for i, e := range events {
+		if e.Kind != helpers.AccessUpdaterChange && e.Kind != helpers.AccessUpdaterRemoved {
+			continue
+		}
+		key := [3]string{e.ChainID, strings.ToLower(e.OracleAddress), strings.ToLower(e.NewAddress)}
+		seen[key]++
+		if seen[key]%2 == 1 {
+			events[i].Kind = helpers.AccessUpdaterChange
+		} else {
+			events[i].Kind = helpers.AccessUpdaterRemoved
+		}
+	}
+	return events
+}
+
+// SelectAuthorizedUpdaters replays the updater events like the oracleupdaters
+// view.
+func (m *memoryDB) SelectAuthorizedUpdaters(chainID string, oracleAddress string) ([]helpers.AuthorizedUpdater, error) {
+	events, _ := m.SelectAccessEvents(chainID, oracleAddress, time.Time{})
+
+	latest := make(map[[3]string]helpers.AccessEvent)
+	var order [][3]string
+	for _, e := range events {
+		if e.Kind != helpers.AccessUpdaterChange && e.Kind != helpers.AccessUpdaterRemoved && e.Kind != helpers.AccessUpdaterSet {
+			continue
+		}
+		key := [3]string{e.ChainID, strings.ToLower(e.OracleAddress), strings.ToLower(e.NewAddress)}
+		if e.Kind == helpers.AccessUpdaterSet {
+			// the new updater replaces every other one
+			for other, previous := range latest {
+				if other[0] == key[0] && other[1] == key[1] && other != key {
+					previous.Kind = helpers.AccessUpdaterRemoved
+					latest[other] = previous
+				}
+			}
+		}
+		if _, ok := latest[key]; !ok {
+			order = append(order, key)
+		}
+		latest[key] = e
+	}
+
+	updaters := []helpers.AuthorizedUpdater{}
+	for _, key := range order {
+		e := latest[key]
+		if e.Kind != helpers.AccessUpdaterChange && e.Kind != helpers.AccessUpdaterSet {
+			continue
+		}
+		updaters = append(updaters, helpers.AuthorizedUpdater{
+			ChainID:       e.ChainID,
+			OracleAddress: e.OracleAddress,
+			Updater:       e.NewAddress,
+			SinceBlock:    e.BlockNumber,
+			SinceTime:     e.BlockTimestamp,
+		})
+	}
+	return updaters, nil
+}
+
+func (m *memoryDB) SelectUpdaterAllowlist() ([]helpers.AllowedUpdater, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]helpers.AllowedUpdater{}, m.allowlist...), nil
+}
+
+func (m *memoryDB) SelectAssetCatalogue(chainID string, oracleAddress string) ([]helpers.CatalogueEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []helpers.CatalogueEntry{}
+	for _, entry := range m.catalogue {
+		if (chainID == "" || entry.ChainID == chainID) && (oracleAddress == "" || strings.EqualFold(entry.OracleAddress, oracleAddress)) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (m *memoryDB) SelectOracleSenders(chainID string, oracleAddress string) ([]helpers.OracleSender, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	senders := make(map[[3]string]*helpers.OracleSender)
+	var order [][3]string
+	for _, metrics := range m.metrics {
+		oracle := metrics.TransactionTo.String()
+		if (chainID != "" && metrics.ChainID != chainID) || (oracleAddress != "" && !strings.EqualFold(oracle, oracleAddress)) {
+			continue
+		}
+		key := [3]string{metrics.ChainID, oracle, metrics.TransactionFrom.String()}
+		sender, ok := senders[key]
+		if !ok {
+			sender = &helpers.OracleSender{ChainID: key[0], OracleAddress: key[1], Sender: key[2], FirstUpdate: metrics.BlockTimestamp}
+			senders[key] = sender
+			order = append(order, key)
+		}
+		sender.Updates++
+		if metrics.BlockTimestamp.Before(sender.FirstUpdate) {
+			sender.FirstUpdate = metrics.BlockTimestamp
+		}
+		if metrics.BlockTimestamp.After(sender.LastUpdate) {
+			sender.LastUpdate = metrics.BlockTimestamp
+		}
+	}
+
+	result := []helpers.OracleSender{}
+	for _, key := range order {
+		result = append(result, *senders[key])
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].FirstUpdate.Before(result[j].FirstUpdate) })
+	return result, nil
+}
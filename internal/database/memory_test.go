@@ -0,0 +1,395 @@
+package database
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+var (
+	testOracle = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	testFeeder = common.HexToAddress("0x2000000000000000000000000000000000000002")
+)
+
+func testUpdate(tx string, key string, block uint64, logIndex uint, price int64) helpers.OracleMetrics {
+	return helpers.OracleMetrics{
+		TransactionMetadata: helpers.TransactionMetadata{
+			BlockNumber:     strconv.FormatUint(block, 10),
+			ChainID:         "1",
+			BlockTimestamp:  time.Unix(int64(block), 0),
+			TransactionFrom: testFeeder,
+			TransactionTo:   testOracle,
+			TransactionHash: tx,
+			TransactionCost: big.NewInt(100),
+			GasUsed:         10,
+		},
+		AssetKey:   key,
+		AssetPrice: big.NewInt(price),
+		LogIndex:   logIndex,
+	}
+}
+
+func onChain(update helpers.OracleMetrics, chainID string) helpers.OracleMetrics {
+	update.ChainID = chainID
+	return update
+}
+
+func TestInsertOracleMetricsBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		batches [][]helpers.OracleMetrics
+		want    int
+	}{
+		{
+			name:    "distinct updates",
+			batches: [][]helpers.OracleMetrics{{testUpdate("0xa", "BTC/USD", 1, 0, 1), testUpdate("0xb", "BTC/USD", 2, 0, 2)}},
+			want:    2,
+		},
+		{
+			name:    "replayed batch",
+			batches: [][]helpers.OracleMetrics{{testUpdate("0xa", "BTC/USD", 1, 0, 1)}, {testUpdate("0xa", "BTC/USD", 1, 0, 1)}},
+			want:    1,
+		},
+		{
+			name:    "same key twice in a transaction",
+			batches: [][]helpers.OracleMetrics{{testUpdate("0xa", "BTC/USD", 1, 0, 1), testUpdate("0xa", "BTC/USD", 1, 1, 2)}},
+			want:    2,
+		},
+		{
+			name:    "duplicate within a batch",
+			batches: [][]helpers.OracleMetrics{{testUpdate("0xa", "BTC/USD", 1, 3, 1), testUpdate("0xa", "BTC/USD", 1, 3, 1)}},
+			want:    1,
+		},
+		{
+			name:    "transaction stored for another chain",
+			batches: [][]helpers.OracleMetrics{{onChain(testUpdate("0xa", "BTC/USD", 1, 0, 1), "2")}, {testUpdate("0xa", "BTC/USD", 1, 0, 1)}},
+			want:    0,
+		},
+	}
+
+	for _, backend := range testBackends(t) {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				db := backend.open(t)
+				for _, batch := range tt.batches {
+					if err := db.InsertOracleMetricsBatch(batch); err != nil {
+						t.Fatalf("InsertOracleMetricsBatch: %v", err)
+					}
+				}
+				updates, err := db.SelectUpdates(helpers.UpdateFilter{ChainID: "1", OracleAddress: testOracle.Hex(), Limit: 100})
+				if err != nil {
+					t.Fatalf("SelectUpdates: %v", err)
+				}
+				if len(updates) != tt.want {
+					t.Errorf("got %d updates, want %d", len(updates), tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestRollbackOracleMetrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		chainID   string
+		block     uint64
+		remaining int
+	}{
+		{"above the last block", "1", 10, 3},
+		{"inside the range", "1", 2, 2},
+		{"below every update", "1", 0, 0},
+		{"another chain", "2", 0, 3},
+	}
+
+	for _, backend := range testBackends(t) {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				db := backend.open(t)
+				updates := []helpers.OracleMetrics{
+					testUpdate("0xa", "BTC/USD", 1, 0, 1),
+					testUpdate("0xb", "BTC/USD", 2, 0, 2),
+					testUpdate("0xc", "BTC/USD", 3, 0, 3),
+				}
+				if err := db.InsertOracleMetricsBatch(updates); err != nil {
+					t.Fatalf("InsertOracleMetricsBatch: %v", err)
+				}
+				if err := db.RollbackOracleMetrics(tt.chainID, tt.block); err != nil {
+					t.Fatalf("RollbackOracleMetrics: %v", err)
+				}
+				stored, _ := db.SelectUpdates(helpers.UpdateFilter{ChainID: "1", OracleAddress: testOracle.Hex(), Limit: 100})
+				if len(stored) != tt.remaining {
+					t.Fatalf("got %d updates, want %d", len(stored), tt.remaining)
+				}
+
+				// rolled back updates can be stored again from the new branch
+				if err := db.InsertOracleMetricsBatch(updates); err != nil {
+					t.Fatalf("InsertOracleMetricsBatch: %v", err)
+				}
+				stored, _ = db.SelectUpdates(helpers.UpdateFilter{ChainID: "1", OracleAddress: testOracle.Hex(), Limit: 100})
+				if len(stored) != len(updates) {
+					t.Errorf("got %d updates after the replay, want %d", len(stored), len(updates))
+				}
+			})
+		}
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int
+		max     int
+		want    int
+		dropped int
+	}{
+		{"under the bound", 5, 10, 5, 0},
+		{"at the bound", 10, 10, 10, 0},
+		{"one over", 11, 10, 9, 2},
+		{"far over", 30, 10, 9, 21},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := make([]int, tt.rows)
+			for i := range rows {
+				rows[i] = i
+			}
+			dropped := 0
+			kept := dropOldest(rows, tt.max, func(int) { dropped++ })
+			if len(kept) != tt.want || dropped != tt.dropped {
+				t.Fatalf("kept %d dropped %d, want %d and %d", len(kept), dropped, tt.want, tt.dropped)
+			}
+			if len(kept) > 0 && kept[len(kept)-1] != tt.rows-1 {
+				t.Errorf("newest row %d was dropped", tt.rows-1)
+			}
+		})
+	}
+}
+
+func TestMemoryMaxRows(t *testing.T) {
+	db := NewMemoryDB("", false, 10)
+	for i := uint64(1); i <= 25; i++ {
+		update := testUpdate("0x"+strconv.FormatUint(i, 16), "BTC/USD", i, 0, int64(i))
+		if err := db.InsertOracleMetricsBatch([]helpers.OracleMetrics{update}); err != nil {
+			t.Fatalf("InsertOracleMetricsBatch: %v", err)
+		}
+	}
+	updates, _ := db.SelectUpdates(helpers.UpdateFilter{ChainID: "1", OracleAddress: testOracle.Hex(), Limit: 100})
+	if len(updates) == 0 || len(updates) > 10 {
+		t.Fatalf("got %d updates, want at most 10", len(updates))
+	}
+	if updates[0].BlockNumber != "25" {
+		t.Errorf("latest update is block %s, want 25", updates[0].BlockNumber)
+	}
+
+	// a dropped update is no longer known and can be stored again
+	if err := db.InsertOracleMetricsBatch([]helpers.OracleMetrics{testUpdate("0x1", "BTC/USD", 1, 0, 1)}); err != nil {
+		t.Fatalf("InsertOracleMetricsBatch: %v", err)
+	}
+	updates, _ = db.SelectUpdates(helpers.UpdateFilter{ChainID: "1", OracleAddress: testOracle.Hex(), AssetKey: "BTC/USD"})
+	if updates[len(updates)-1].BlockNumber != "1" {
+		t.Errorf("dropped update was not stored again")
+	}
+}
+
+func testAccess(tx string, block uint64, kind string, updater string) helpers.AccessEvent {
+	return helpers.AccessEvent{
+		ChainID:         "1",
+		OracleAddress:   testOracle.Hex(),
+		TransactionHash: tx,
+		BlockNumber:     block,
+		BlockTimestamp:  time.Unix(int64(block), 0),
+		Kind:            kind,
+		NewAddress:      updater,
+	}
+}
+
+func TestAccessEvents(t *testing.T) {
+	const (
+		alice = "0xA000000000000000000000000000000000000001"
+		bob   = "0xB000000000000000000000000000000000000002"
+	)
+
+	tests := []struct {
+		name      string
+		events    []helpers.AccessEvent
+		wantKinds []string
+		updaters  []string
+	}{
+		{
+			name:      "added",
+			events:    []helpers.AccessEvent{testAccess("0xa", 1, helpers.AccessUpdaterChange, alice)},
+			wantKinds: []string{helpers.AccessUpdaterChange},
+			updaters:  []string{alice},
+		},
+		{
+			name: "added and removed",
+			events: []helpers.AccessEvent{
+				testAccess("0xa", 1, helpers.AccessUpdaterChange, alice),
+				testAccess("0xb", 2, helpers.AccessUpdaterChange, alice),
+			},
+			wantKinds: []string{helpers.AccessUpdaterChange, helpers.AccessUpdaterRemoved},
+			updaters:  []string{},
+		},
+		{
+			name: "re-added",
+			events: []helpers.AccessEvent{
+				testAccess("0xa", 1, helpers.AccessUpdaterChange, alice),
+				testAccess("0xb", 2, helpers.AccessUpdaterChange, alice),
+				testAccess("0xc", 3, helpers.AccessUpdaterChange, alice),
+			},
+			wantKinds: []string{helpers.AccessUpdaterChange, helpers.AccessUpdaterRemoved, helpers.AccessUpdaterChange},
+			updaters:  []string{alice},
+		},
+		{
+			name: "inserted out of order",
+			events: []helpers.AccessEvent{
+				testAccess("0xb", 2, helpers.AccessUpdaterChange, alice),
+				testAccess("0xa", 1, helpers.AccessUpdaterChange, bob),
+				testAccess("0xc", 1, helpers.AccessUpdaterChange, alice),
+			},
+			wantKinds: []string{helpers.AccessUpdaterChange, helpers.AccessUpdaterChange, helpers.AccessUpdaterRemoved},
+			updaters:  []string{bob},
+		},
+		{
+			name: "single updater replaced",
+			events: []helpers.AccessEvent{
+				testAccess("0xa", 1, helpers.AccessUpdaterSet, alice),
+				testAccess("0xb", 2, helpers.AccessUpdaterSet, bob),
+			},
+			wantKinds: []string{helpers.AccessUpdaterSet, helpers.AccessUpdaterSet},
+			updaters:  []string{bob},
+		},
+	}
+
+	for _, backend := range testBackends(t) {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				db := backend.open(t)
+				if err := db.InsertAccessEvents(tt.events); err != nil {
+					t.Fatalf("InsertAccessEvents: %v", err)
+				}
+				events, err := db.SelectAccessEvents("1", testOracle.Hex(), time.Time{})
+				if err != nil {
+					t.Fatalf("SelectAccessEvents: %v", err)
+				}
+				if len(events) != len(tt.wantKinds) {
+					t.Fatalf("got %d events, want %d", len(events), len(tt.wantKinds))
+				}
+				for i, e := range events {
+					if e.Kind != tt.wantKinds[i] {
+						t.Errorf("event %d of block %d is %s, want %s", i, e.BlockNumber, e.Kind, tt.wantKinds[i])
+					}
+				}
+
+				updaters, err := db.SelectAuthorizedUpdaters("1", testOracle.Hex())
+				if err != nil {
+					t.Fatalf("SelectAuthorizedUpdaters: %v", err)
+				}
+				if len(updaters) != len(tt.updaters) {
+					t.Fatalf("got %d updaters, want %v", len(updaters), tt.updaters)
+				}
+				for i, updater := range updaters {
+					if updater.Updater != tt.updaters[i] {
+						t.Errorf("updater %d is %s, want %s", i, updater.Updater, tt.updaters[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMemoryDeleteAccessEvent(t *testing.T) {
+	db := NewMemoryDB("", false, 0)
+	event := testAccess("0xa", 1, helpers.AccessUpdaterChange, "0xA000000000000000000000000000000000000001")
+	if err := db.InsertAccessEvents([]helpers.AccessEvent{event}); err != nil {
+		t.Fatalf("InsertAccessEvents: %v", err)
+	}
+	if err := db.DeleteAccessEvent("1", "0xa", 0); err != nil {
+		t.Fatalf("DeleteAccessEvent: %v", err)
+	}
+	if events, _ := db.SelectAccessEvents("1", testOracle.Hex(), time.Time{}); len(events) != 0 {
+		t.Fatalf("got %d events after the delete, want 0", len(events))
+	}
+	// the orphaned event comes back when its block is mined again
+	if err := db.InsertAccessEvents([]helpers.AccessEvent{event}); err != nil {
+		t.Fatalf("InsertAccessEvents: %v", err)
+	}
+	if events, _ := db.SelectAccessEvents("1", testOracle.Hex(), time.Time{}); len(events) != 1 {
+		t.Errorf("got %d events after the replay, want 1", len(events))
+	}
+}
+
+func TestMemoryConnectSeed(t *testing.T) {
+	seed := `{
+		"chains": [{"chain-id": "1", "rpc": ["http://a", "http://b"], "confirmations": 5}],
+		"oracles": [{"contract-address": "0x1000000000000000000000000000000000000001", "chain-id": "1"}],
+		"heartbeat-rules": [{"ChainID": "1", "Interval": 60000000000}],
+		"asset-catalogue": [{"ChainID": "1", "OracleAddress": "0x1000000000000000000000000000000000000001", "AssetKey": "BTC/USD", "Heartbeat": 120000000000}]
+	}`
+	file := filepath.Join(t.TempDir(), "seed.json")
+	if err := os.WriteFile(file, []byte(seed), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := NewMemoryDB(file, false, 0)
+	if err := db.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	configs, _ := db.GetChainConfigs(nil)
+	config, ok := configs["1"]
+	if !ok || len(config.RPC) != 2 || config.RPC[1].Priority != 1 || config.HeadQuorum != 1 || config.Confirmations != 5 {
+		t.Errorf("unexpected chain config %+v", config)
+	}
+	if oracles, _ := db.SelectOracles("1"); len(oracles) != 1 {
+		t.Errorf("got %d oracles, want 1", len(oracles))
+	}
+	rules, _ := db.SelectHeartbeatRules()
+	if len(rules) != 2 || rules[0].Interval != time.Minute || rules[1].Interval != 2*time.Minute {
+		t.Errorf("got heartbeat rules %+v, want the seeded rule before the catalogue", rules)
+	}
+
+	if err := NewMemoryDB(filepath.Join(t.TempDir(), "missing.json"), false, 0).Connect(); err == nil {
+		t.Error("Connect succeeded without a seed file")
+	}
+}
+
+func TestNewDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		memory  bool
+		wantErr bool
+	}{
+		{"postgres by default", nil, false, false},
+		{"memory backend", map[string]string{"DB_BACKEND": "memory"}, true, false},
+		{"dry run", map[string]string{"DRY_RUN": "true", "DB_SEED_FILE": "seed.json"}, true, false},
+		{"dry run without a seed", map[string]string{"DRY_RUN": "true"}, false, true},
+		{"invalid dry run", map[string]string{"DRY_RUN": "maybe"}, false, true},
+		{"unknown backend", map[string]string{"DB_BACKEND": "sqlite"}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"DB_BACKEND", "DRY_RUN", "DB_SEED_FILE", "MEMORY_MAX_ROWS"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			db, err := NewDatabase()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if _, memory := db.(*memoryDB); err == nil && memory != tt.memory {
+				t.Errorf("got %T, want the memory backend %t", db, tt.memory)
+			}
+		})
+	}
+}
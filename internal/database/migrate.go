@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// key of the advisory lock held while migrating, so that several instances
+// starting together apply every migration once
+const migrationLock = 7305092411
+
+const (
+	createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER NOT NULL PRIMARY KEY,
+  name TEXT NOT NULL,
+  checksum TEXT NOT NULL,
+  applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+)`
+	selectMigrationsQuery = `SELECT version, name, checksum FROM schema_migrations ORDER BY version`
+	insertMigrationQuery  = `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`
+)
+
+// migration is one file of migrations/, named <version>_<name>.sql.
+type migration struct {
+	version  int
+	name     string
+	sql      string
+	checksum string
+}
+
+// loadMigrations returns the embedded migrations ordered by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the migrations: %v", err)
+	}
+
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		file := entry.Name()
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %s", file)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, file, version)
+		}
+		seen[version] = file
+
+		content, err := migrationFiles.ReadFile("migrations/" + file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", file, err)
+		}
+		sum := sha256.Sum256(content)
+		migrations = append(migrations, migration{version: version, name: name, sql: string(content), checksum: hex.EncodeToString(sum[:])})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// migrate brings the schema up to the latest embedded migration and sets up
+// time partitioning according to the TIMESCALEDB mode, reporting whether
+// feederupdates is a hypertable. It refuses a database migrated by a newer
+// release or whose applied migrations differ from the embedded ones.
+func migrate(ctx context.Context, pool *pgxpool.Pool, timescale string) (bool, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return false, err
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire a connection: %v", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		return false, fmt.Errorf("failed to lock the schema: %v", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLock)
+
+	if _, err := conn.Exec(ctx, createMigrationsTable); err != nil {
+		return false, fmt.Errorf("failed to create the migrations table: %v", err)
+	}
+
+	applied, err := appliedMigrations(ctx, conn.Conn())
+	if err != nil {
+		return false, err
+	}
+	if err := checkApplied(migrations, applied); err != nil {
+		return false, err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+
+		log.Printf("applying schema migration %d %s", m.version, m.name)
+		err := pgx.BeginFunc(ctx, conn.Conn(), func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, m.sql); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, insertMigrationQuery, m.version, m.name, m.checksum)
+			return err
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to apply migration %d %s: %v", m.version, m.name, err)
+		}
+	}
+
+	return setupTimescale(ctx, conn.Conn(), timescale)
+}
+
+func appliedMigrations(ctx context.Context, conn *pgx.Conn) (map[int]migration, error) {
+	rows, err := conn.Query(ctx, selectMigrationsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]migration)
+	for rows.Next() {
+		var m migration
+		if err := rows.Scan(&m.version, &m.name, &m.checksum); err != nil {
+			return nil, fmt.Errorf("failed to get the applied migrations: %v", err)
+		}
+		applied[m.version] = m
+	}
+	return applied, rows.Err()
+}
+
+// checkApplied verifies that every applied migration is one of the embedded
+// migrations, unchanged since it was applied.
+func checkApplied(migrations []migration, applied map[int]migration) error {
+	known := make(map[int]migration, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = m
+	}
+
+	for version, a := range applied {
+		m, ok := known[version]
+		if !ok {
+			return fmt.Errorf("database schema has migration %d %s which this release does not know, refusing to start against a newer schema", version, a.name)
+		}
+		if m.name != a.name || m.checksum != a.checksum {
+			return fmt.Errorf("migration %d %s differs from the one applied to the database (%s), refusing to start against an incompatible schema", version, m.name, a.name)
+		}
+	}
+	return nil
+}
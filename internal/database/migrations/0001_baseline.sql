@@ -0,0 +1,138 @@
+-- Every table the monitor uses. Deployments created from the former
+-- scripts/schema.sql already have some of them, so each statement leaves
+-- existing tables in place and only adds what is missing.
+
+CREATE TABLE IF NOT EXISTS chainconfig (
+  chainid TEXT NOT NULL PRIMARY KEY,
+  rpcurl TEXT NOT NULL DEFAULT '',
+  wsurl TEXT NOT NULL DEFAULT ''
+);
+
+-- blocks an update must be buried under before the block scanner records it
+ALTER TABLE chainconfig ADD COLUMN IF NOT EXISTS confirmations BIGINT NOT NULL DEFAULT 0;
+-- number of endpoints that must report a head before the scanner follows it
+ALTER TABLE chainconfig ADD COLUMN IF NOT EXISTS head_quorum INTEGER NOT NULL DEFAULT 1;
+
+-- fallback node endpoints of a chain, kind is either 'rpc' or 'ws' and lower
+-- priorities are tried first after the urls of chainconfig
+CREATE TABLE IF NOT EXISTS chainendpoints (
+  id BIGSERIAL PRIMARY KEY,
+  chainid TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  url TEXT NOT NULL,
+  priority INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS oracleconfig (
+  address TEXT NOT NULL,
+  chainid TEXT NOT NULL,
+  createddate TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  PRIMARY KEY (address, chainid)
+);
+
+ALTER TABLE oracleconfig ADD COLUMN IF NOT EXISTS creation_block BIGINT;
+ALTER TABLE oracleconfig ADD COLUMN IF NOT EXISTS creation_block_time TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS oracles (
+  contract_address TEXT NOT NULL PRIMARY KEY,
+  contract_abi TEXT NOT NULL,
+  chain_id TEXT NOT NULL,
+  node_url TEXT NOT NULL,
+  creation_block BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS feederupdates (
+  id BIGSERIAL PRIMARY KEY,
+  oracle_address TEXT NOT NULL,
+  transaction_hash TEXT NOT NULL,
+  transaction_cost TEXT NOT NULL,
+  asset_key TEXT NOT NULL,
+  asset_price TEXT NOT NULL,
+  update_block BIGINT NOT NULL,
+  update_from TEXT NOT NULL,
+  from_balance TEXT NOT NULL,
+  gas_cost TEXT NOT NULL,
+  gas_used DOUBLE PRECISION NOT NULL,
+  creation_block BIGINT NOT NULL,
+  chain_id TEXT,
+  update_time TIMESTAMP WITHOUT TIME ZONE
+);
+
+ALTER TABLE feederupdates ALTER COLUMN gas_used TYPE DOUBLE PRECISION USING gas_used::double precision;
+
+-- the writer relies on ON CONFLICT (transaction_hash), drop the duplicates
+-- older deployments collected before the index existed
+DELETE FROM feederupdates a USING feederupdates b
+WHERE a.transaction_hash = b.transaction_hash AND a.ctid > b.ctid;
+
+CREATE UNIQUE INDEX IF NOT EXISTS feederupdates_transaction_hash_idx ON feederupdates (transaction_hash);
+CREATE INDEX IF NOT EXISTS feederupdates_chain_block_idx ON feederupdates (chain_id, update_block);
+CREATE INDEX IF NOT EXISTS feederupdates_oracle_key_idx ON feederupdates (chain_id, oracle_address, asset_key, update_block);
+
+CREATE TABLE IF NOT EXISTS feederupdatestate (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL,
+  last_block BIGINT NOT NULL
+);
+
+ALTER TABLE feederupdatestate ADD COLUMN IF NOT EXISTS last_block_hash TEXT NOT NULL DEFAULT '';
+
+-- maximum seconds between two updates, empty chain_id, oracle_address or
+-- asset_key match anything and the most specific row wins
+CREATE TABLE IF NOT EXISTS heartbeatconfig (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL DEFAULT '',
+  oracle_address TEXT NOT NULL DEFAULT '',
+  asset_key TEXT NOT NULL DEFAULT '',
+  heartbeat_seconds BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS alerts (
+  alert_key TEXT NOT NULL PRIMARY KEY,
+  kind TEXT NOT NULL,
+  severity TEXT NOT NULL,
+  chain_id TEXT NOT NULL,
+  oracle_address TEXT NOT NULL,
+  asset_key TEXT NOT NULL,
+  message TEXT NOT NULL,
+  firing BOOLEAN NOT NULL,
+  fired_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  resolved_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+-- value limits per chain, oracle and key, empty fields match anything and
+-- zero limits disable the comparison. A NULL decimals leaves the decimals the
+-- update was decoded with
+CREATE TABLE IF NOT EXISTS deviationconfig (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL DEFAULT '',
+  oracle_address TEXT NOT NULL DEFAULT '',
+  asset_key TEXT NOT NULL DEFAULT '',
+  max_jump_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+  max_reference_deviation_percent DOUBLE PRECISION NOT NULL DEFAULT 0,
+  decimals INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS updatefindings (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL,
+  oracle_address TEXT NOT NULL,
+  transaction_hash TEXT NOT NULL,
+  asset_key TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  severity TEXT NOT NULL,
+  message TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS updatefindings_transaction_hash_idx ON updatefindings (transaction_hash);
+
+CREATE TABLE IF NOT EXISTS feederbalances (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL,
+  address TEXT NOT NULL,
+  balance NUMERIC NOT NULL,
+  recorded_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS feederbalances_address_idx ON feederbalances (chain_id, address, recorded_at);
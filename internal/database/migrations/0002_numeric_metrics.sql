@@ -0,0 +1,44 @@
+-- Store prices, balances, gas and costs of feederupdates as numbers and
+-- record the decimals of every value.
+
+-- keep a copy of the rows holding text that is not an integer, those
+-- columns become NULL instead of failing the conversion
+CREATE TABLE IF NOT EXISTS feederupdates_unparsed (
+  id BIGINT NOT NULL,
+  transaction_hash TEXT NOT NULL,
+  asset_price TEXT,
+  transaction_cost TEXT,
+  from_balance TEXT,
+  gas_cost TEXT,
+  copied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+INSERT INTO feederupdates_unparsed (id, transaction_hash, asset_price, transaction_cost, from_balance, gas_cost)
+SELECT id, transaction_hash, asset_price, transaction_cost, from_balance, gas_cost FROM feederupdates
+WHERE asset_price !~ '^[0-9]+$' OR transaction_cost !~ '^[0-9]+$' OR from_balance !~ '^[0-9]+$' OR gas_cost !~ '^[0-9]+$';
+
+ALTER TABLE feederupdates
+  ALTER COLUMN asset_price DROP NOT NULL,
+  ALTER COLUMN transaction_cost DROP NOT NULL,
+  ALTER COLUMN from_balance DROP NOT NULL,
+  ALTER COLUMN gas_cost DROP NOT NULL;
+
+ALTER TABLE feederupdates
+  ALTER COLUMN asset_price TYPE NUMERIC USING CASE WHEN asset_price ~ '^[0-9]+$' THEN asset_price::numeric END,
+  ALTER COLUMN transaction_cost TYPE NUMERIC USING CASE WHEN transaction_cost ~ '^[0-9]+$' THEN transaction_cost::numeric END,
+  ALTER COLUMN from_balance TYPE NUMERIC USING CASE WHEN from_balance ~ '^[0-9]+$' THEN from_balance::numeric END,
+  ALTER COLUMN gas_cost TYPE NUMERIC USING CASE WHEN gas_cost ~ '^[0-9]+$' THEN gas_cost::numeric END,
+  ALTER COLUMN gas_used TYPE NUMERIC USING round(gas_used)::numeric;
+
+-- decimals of the values an oracle publishes
+ALTER TABLE oracleconfig ADD COLUMN IF NOT EXISTS decimals INTEGER NOT NULL DEFAULT 8;
+
+ALTER TABLE feederupdates ADD COLUMN IF NOT EXISTS asset_decimals INTEGER;
+
+UPDATE feederupdates SET asset_decimals = oracleconfig.decimals
+FROM oracleconfig
+WHERE lower(oracleconfig.address) = lower(feederupdates.oracle_address) AND oracleconfig.chainid = feederupdates.chain_id;
+
+UPDATE feederupdates SET asset_decimals = 8 WHERE asset_decimals IS NULL;
+
+ALTER TABLE feederupdates ALTER COLUMN asset_decimals SET NOT NULL;
@@ -0,0 +1,26 @@
+-- Prepare feederupdates for partitioning on update_time: the time column may
+-- not be NULL and has to be part of every unique index.
+
+-- updates stored before update_time was recorded have no known time
+UPDATE feederupdates SET update_time = 'epoch'::timestamp WHERE update_time IS NULL;
+ALTER TABLE feederupdates ALTER COLUMN update_time SET NOT NULL;
+
+ALTER TABLE feederupdates DROP CONSTRAINT IF EXISTS feederupdates_pkey;
+ALTER TABLE feederupdates ADD PRIMARY KEY (id, update_time);
+
+-- a transaction always has the time of its block, so this is as strict as
+-- the former index on transaction_hash alone
+DROP INDEX IF EXISTS feederupdates_transaction_hash_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS feederupdates_transaction_time_idx ON feederupdates (transaction_hash, update_time);
+
+-- how long updates of a chain are kept and when they are compressed, zero
+-- disables either, the row with an empty chain_id applies to every chain
+-- without its own row. Compression is only available with TimescaleDB. There
+-- both are policies of the whole hypertable: the shortest compress_after_days
+-- wins, and chunks are dropped past the longest retention_days once every
+-- chain has one.
+CREATE TABLE IF NOT EXISTS retentionconfig (
+  chain_id TEXT NOT NULL PRIMARY KEY,
+  retention_days INTEGER NOT NULL DEFAULT 0,
+  compress_after_days INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,3 @@
+-- ABI of the contract version an oracle is deployed from, one of the files
+-- in internal/abi without the .json suffix
+ALTER TABLE oracleconfig ADD COLUMN IF NOT EXISTS contract_abi TEXT NOT NULL DEFAULT 'oracle-v2';
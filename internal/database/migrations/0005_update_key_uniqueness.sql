@@ -0,0 +1,8 @@
+-- a batch update stores one row per key of the transaction, each charged
+-- with its share of the gas, and a transaction may update the same key more
+-- than once, so every update log is stored. Rows stored before have no log
+-- index and keep -1, the insert skips the updates matching them.
+ALTER TABLE feederupdates ADD COLUMN IF NOT EXISTS log_index INTEGER NOT NULL DEFAULT -1;
+ALTER TABLE feederupdates ALTER COLUMN log_index DROP DEFAULT;
+DROP INDEX IF EXISTS feederupdates_transaction_time_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS feederupdates_transaction_log_idx ON feederupdates (transaction_hash, asset_key, update_time, log_index);
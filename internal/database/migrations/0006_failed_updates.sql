@@ -0,0 +1,20 @@
+-- transactions to monitored oracles that reverted, they burn feeder gas
+-- without updating anything
+CREATE TABLE IF NOT EXISTS failedupdates (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL,
+  oracle_address TEXT NOT NULL,
+  transaction_hash TEXT NOT NULL,
+  update_block BIGINT NOT NULL,
+  update_time TIMESTAMP NOT NULL,
+  update_from TEXT NOT NULL,
+  method TEXT NOT NULL,
+  asset_keys TEXT[] NOT NULL DEFAULT '{}',
+  gas_used NUMERIC NOT NULL,
+  gas_cost NUMERIC,
+  transaction_cost NUMERIC,
+  revert_reason TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS failedupdates_transaction_hash_idx ON failedupdates (chain_id, transaction_hash);
+CREATE INDEX IF NOT EXISTS failedupdates_time_idx ON failedupdates (update_time);
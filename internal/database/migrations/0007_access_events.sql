@@ -0,0 +1,55 @@
+-- access control changes of the oracles: updater_change and updater_removed
+-- from UpdaterAddressChange logs, updater_set from the logs of single updater
+-- oracles, owner_change from OwnershipTransferred logs
+CREATE TABLE IF NOT EXISTS accessevents (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL,
+  oracle_address TEXT NOT NULL,
+  transaction_hash TEXT NOT NULL,
+  log_index INTEGER NOT NULL,
+  block_number BIGINT NOT NULL,
+  event_time TIMESTAMP NOT NULL,
+  kind TEXT NOT NULL,
+  method TEXT NOT NULL DEFAULT '',
+  previous_address TEXT NOT NULL DEFAULT '',
+  new_address TEXT NOT NULL,
+  sender TEXT NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS accessevents_log_idx ON accessevents (chain_id, transaction_hash, log_index);
+CREATE INDEX IF NOT EXISTS accessevents_oracle_idx ON accessevents (chain_id, lower(oracle_address), block_number);
+
+-- addUpdater and removeUpdater of a multi updater oracle emit the same
+-- UpdaterAddressChange log, whichever contract called them. The contract
+-- rejects adding an authorized updater and removing an unknown one, so the
+-- logs naming an address alternate between adding and removing it, the first
+-- one adds it. Rows classified by the called method count the same way.
+CREATE OR REPLACE VIEW classifiedaccessevents AS
+SELECT chain_id, oracle_address, transaction_hash, log_index, block_number, event_time,
+  CASE WHEN kind IN ('updater_change', 'updater_removed') THEN
+    CASE WHEN count(*) FILTER (WHERE kind IN ('updater_change', 'updater_removed')) OVER (
+      PARTITION BY chain_id, lower(oracle_address), lower(new_address) ORDER BY block_number, log_index
+    ) % 2 = 1 THEN 'updater_change' ELSE 'updater_removed' END
+  ELSE kind END AS kind,
+  method, previous_address, new_address, sender
+FROM accessevents;
+
+-- updaters every oracle currently accepts: addresses whose latest classified
+-- event adds them. Oracles of a single updater version announce each new
+-- updater with an updater_set event, which revokes every updater authorized
+-- before it.
+CREATE OR REPLACE VIEW oracleupdaters AS
+SELECT chain_id, oracle_address, updater, since_block, since_time FROM (
+  SELECT DISTINCT ON (chain_id, lower(oracle_address), lower(new_address))
+    chain_id, oracle_address, new_address AS updater, kind, block_number AS since_block, log_index, event_time AS since_time
+  FROM classifiedaccessevents
+  WHERE kind IN ('updater_change', 'updater_removed', 'updater_set')
+  ORDER BY chain_id, lower(oracle_address), lower(new_address), block_number DESC, log_index DESC
+) latest
+WHERE kind IN ('updater_change', 'updater_set') AND NOT EXISTS (
+  SELECT 1 FROM accessevents later
+  WHERE later.kind = 'updater_set' AND later.chain_id = latest.chain_id
+    AND lower(later.oracle_address) = lower(latest.oracle_address)
+    AND lower(later.new_address) <> lower(latest.updater)
+    AND (later.block_number, later.log_index) > (latest.since_block, latest.log_index)
+);
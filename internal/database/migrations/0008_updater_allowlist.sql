@@ -0,0 +1,11 @@
+-- updaters expected on top of those the access control events authorized,
+-- empty chain_id or oracle_address match any chain or oracle
+CREATE TABLE IF NOT EXISTS updaterallowlist (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL DEFAULT '',
+  oracle_address TEXT NOT NULL DEFAULT '',
+  updater TEXT NOT NULL,
+  label TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS feederupdates_sender_idx ON feederupdates (chain_id, oracle_address, update_from);
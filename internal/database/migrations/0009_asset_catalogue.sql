@@ -0,0 +1,16 @@
+-- keys every oracle is expected to serve. A heartbeat_seconds above zero also
+-- acts as the heartbeat rule of the key, deviation_percent is the price change
+-- the feeder pushes an update on, zero when not declared. decimals override
+-- those of the oracle in the deviation checks, NULL when the key declares none
+CREATE TABLE IF NOT EXISTS assetcatalogue (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL,
+  oracle_address TEXT NOT NULL,
+  asset_key TEXT NOT NULL,
+  symbol TEXT NOT NULL DEFAULT '',
+  decimals INTEGER,
+  heartbeat_seconds BIGINT NOT NULL DEFAULT 0,
+  deviation_percent DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS assetcatalogue_key_idx ON assetcatalogue (chain_id, lower(oracle_address), asset_key);
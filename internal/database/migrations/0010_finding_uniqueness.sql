@@ -0,0 +1,5 @@
+-- an update reaches the checks from the event listener and the block scanner,
+-- its findings are stored once
+DELETE FROM updatefindings a USING updatefindings b
+  WHERE a.id > b.id AND a.chain_id = b.chain_id AND a.transaction_hash = b.transaction_hash AND a.asset_key = b.asset_key AND a.kind = b.kind;
+CREATE UNIQUE INDEX IF NOT EXISTS updatefindings_update_kind_idx ON updatefindings (chain_id, transaction_hash, asset_key, kind);
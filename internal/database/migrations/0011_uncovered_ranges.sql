@@ -0,0 +1,12 @@
+-- block ranges scanned with eth_getLogs on nodes serving no traces. Reverted
+-- transactions emit no logs, the ones to the oracles in these ranges are
+-- missing from failedupdates.
+CREATE TABLE IF NOT EXISTS uncoveredranges (
+  id BIGSERIAL PRIMARY KEY,
+  chain_id TEXT NOT NULL,
+  from_block BIGINT NOT NULL,
+  to_block BIGINT NOT NULL,
+  recorded_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uncoveredranges_range_idx ON uncoveredranges (chain_id, from_block, to_block);
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Modes of TIMESCALEDB: auto uses TimescaleDB when the server offers it, on
+// refuses to start without it and off never uses it.
+const (
+	TimescaleAuto = "auto"
+	TimescaleOn   = "on"
+	TimescaleOff  = "off"
+)
+
+// aggregates maps the name of every aggregate view to its bucket width.
+var aggregates = []struct {
+	name   string
+	bucket string
+}{
+	{"feederupdates_hourly", "1 hour"},
+	{"feederupdates_daily", "1 day"},
+}
+
+const (
+	timescaleAvailableQuery  = `SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb')`
+	timescaleInstalledQuery  = `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')`
+	relationKindQuery        = `SELECT relkind::text FROM pg_class WHERE relname = $1 AND relnamespace = current_schema()::regnamespace`
+	hypertableQuery          = `SELECT EXISTS (SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_schema = current_schema() AND hypertable_name = 'feederupdates')`
+	continuousAggregateQuery = `SELECT EXISTS (SELECT 1 FROM timescaledb_information.continuous_aggregates WHERE view_schema = current_schema() AND view_name = $1)`
+
+	// compression and retention are policies of the whole hypertable, they
+	// cannot differ per chain. The shortest compress_after_days compresses
+	// every chain, and chunks are dropped past the longest retention_days
+	// once every chain has one, the default row included.
+	minCompressAfterQuery = `SELECT COALESCE(MIN(compress_after_days), 0) FROM retentionconfig WHERE compress_after_days > 0`
+	maxRetentionQuery     = `SELECT CASE WHEN bool_or(chain_id = '') AND bool_and(retention_days > 0) THEN MAX(retention_days) ELSE 0 END FROM retentionconfig`
+
+	createHypertableQuery = `SELECT create_hypertable('feederupdates', 'update_time', chunk_time_interval => interval '7 days', migrate_data => true, if_not_exists => true)`
+	enableCompression     = `ALTER TABLE feederupdates SET (timescaledb.compress, timescaledb.compress_segmentby = 'chain_id, oracle_address', timescaledb.compress_orderby = 'update_time DESC')`
+	removeCompression     = `SELECT remove_compression_policy('feederupdates', if_exists => true)`
+	addCompression        = `SELECT add_compression_policy('feederupdates', make_interval(days => $1))`
+	removeRetention       = `SELECT remove_retention_policy('feederupdates', if_exists => true)`
+	addRetention          = `SELECT add_retention_policy('feederupdates', make_interval(days => $1))`
+
+	// hourly and daily update counts, gas spend and price OHLC per key
+	continuousAggregate = `CREATE MATERIALIZED VIEW IF NOT EXISTS %[1]s WITH (timescaledb.continuous) AS
+SELECT time_bucket(interval '%[2]s', update_time) AS bucket, chain_id, oracle_address, asset_key,
+  count(*) AS updates, sum(transaction_cost) AS transaction_cost, sum(gas_used) AS gas_used,
+  first(asset_price, update_time) AS open, max(asset_price) AS high, min(asset_price) AS low, last(asset_price, update_time) AS close
+FROM feederupdates GROUP BY bucket, chain_id, oracle_address, asset_key WITH NO DATA`
+	aggregatePolicy = `SELECT add_continuous_aggregate_policy('%[1]s', start_offset => interval '%[2]s' * 3, end_offset => interval '%[2]s', schedule_interval => interval '%[2]s', if_not_exists => true)`
+
+	// the same aggregates computed on read for vanilla Postgres
+	plainAggregate = `CREATE OR REPLACE VIEW %[1]s AS
+SELECT date_trunc('%[2]s', update_time) AS bucket, chain_id, oracle_address, asset_key,
+  count(*) AS updates, sum(transaction_cost) AS transaction_cost, sum(gas_used) AS gas_used,
+  (array_agg(asset_price ORDER BY update_time))[1] AS open, max(asset_price) AS high, min(asset_price) AS low, (array_agg(asset_price ORDER BY update_time DESC))[1] AS close
+FROM feederupdates GROUP BY bucket, chain_id, oracle_address, asset_key`
+)
+
+// setupTimescale turns feederupdates into a hypertable with continuous
+// aggregates and compression and retention policies when TimescaleDB is
+// available, and falls back to plain aggregate views otherwise. It runs after
+// the migrations, on the connection holding the migration lock, and reports
+// whether feederupdates is a hypertable.
+func setupTimescale(ctx context.Context, conn *pgx.Conn, mode string) (bool, error) {
+	if mode == "" {
+		mode = TimescaleAuto
+	}
+
+	useTimescale := false
+	switch mode {
+	case TimescaleOff:
+	case TimescaleAuto, TimescaleOn:
+		var available bool
+		if err := conn.QueryRow(ctx, timescaleAvailableQuery).Scan(&available); err != nil {
+			return false, fmt.Errorf("failed to look for TimescaleDB: %v", err)
+		}
+		if available {
+			_, err := conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS timescaledb")
+			if err == nil {
+				useTimescale = true
+			} else if mode == TimescaleOn {
+				return false, fmt.Errorf("failed to enable TimescaleDB: %v", err)
+			} else {
+				log.Printf("TimescaleDB is installed but cannot be enabled, using plain Postgres: %v", err)
+			}
+		} else if mode == TimescaleOn {
+			return false, fmt.Errorf("TIMESCALEDB is on but the server does not offer the extension")
+		}
+	default:
+		return false, fmt.Errorf("invalid TIMESCALEDB %q, expected auto, on or off", mode)
+	}
+
+	if !useTimescale {
+		return setupPlainAggregates(ctx, conn)
+	}
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		return setupHypertable(ctx, tx)
+	})
+	return err == nil, err
+}
+
+func setupHypertable(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, createHypertableQuery); err != nil {
+		return fmt.Errorf("failed to create the hypertable: %v", err)
+	}
+
+	for _, aggregate := range aggregates {
+		// replace the view left by a run without TimescaleDB. A continuous
+		// aggregate is a view in pg_class as well, so it is told apart by
+		// TimescaleDB and kept.
+		continuous, err := isContinuousAggregate(ctx, tx, aggregate.name)
+		if err != nil {
+			return err
+		}
+		kind, err := relationKind(ctx, tx, aggregate.name)
+		if err != nil {
+			return err
+		}
+		if !continuous && kind == "v" {
+			if _, err := tx.Exec(ctx, "DROP VIEW "+aggregate.name); err != nil {
+				return fmt.Errorf("failed to drop view %s: %v", aggregate.name, err)
+			}
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(continuousAggregate, aggregate.name, aggregate.bucket)); err != nil {
+			return fmt.Errorf("failed to create continuous aggregate %s: %v", aggregate.name, err)
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(aggregatePolicy, aggregate.name, aggregate.bucket)); err != nil {
+			return fmt.Errorf("failed to schedule continuous aggregate %s: %v", aggregate.name, err)
+		}
+	}
+
+	var compressAfter int
+	if err := tx.QueryRow(ctx, minCompressAfterQuery).Scan(&compressAfter); err != nil {
+		return fmt.Errorf("failed to get the compression settings: %v", err)
+	}
+	if _, err := tx.Exec(ctx, removeCompression); err != nil {
+		return fmt.Errorf("failed to remove the compression policy: %v", err)
+	}
+	if compressAfter > 0 {
+		if _, err := tx.Exec(ctx, enableCompression); err != nil {
+			return fmt.Errorf("failed to enable compression: %v", err)
+		}
+		if _, err := tx.Exec(ctx, addCompression, compressAfter); err != nil {
+			return fmt.Errorf("failed to add the compression policy: %v", err)
+		}
+	}
+
+	var retention int
+	if err := tx.QueryRow(ctx, maxRetentionQuery).Scan(&retention); err != nil {
+		return fmt.Errorf("failed to get the retention settings: %v", err)
+	}
+	if _, err := tx.Exec(ctx, removeRetention); err != nil {
+		return fmt.Errorf("failed to remove the retention policy: %v", err)
+	}
+	if retention > 0 {
+		if _, err := tx.Exec(ctx, addRetention, retention); err != nil {
+			return fmt.Errorf("failed to add the retention policy: %v", err)
+		}
+	}
+
+	log.Printf("feederupdates is a TimescaleDB hypertable, compression after %d days, chunks dropped after %d days", compressAfter, retention)
+	return nil
+}
+
+// setupPlainAggregates creates the aggregate views computed on read. A
+// hypertable and continuous aggregates left by an earlier run with
+// TimescaleDB stay, with their policies, and it reports whether there is one.
+func setupPlainAggregates(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	installed := false
+	if err := conn.QueryRow(ctx, timescaleInstalledQuery).Scan(&installed); err != nil {
+		return false, fmt.Errorf("failed to look for TimescaleDB: %v", err)
+	}
+
+	for _, aggregate := range aggregates {
+		if installed {
+			continuous, err := isContinuousAggregate(ctx, conn, aggregate.name)
+			if err != nil {
+				return false, err
+			}
+			if continuous {
+				continue
+			}
+		}
+
+		unit := "hour"
+		if aggregate.bucket == "1 day" {
+			unit = "day"
+		}
+		if _, err := conn.Exec(ctx, fmt.Sprintf(plainAggregate, aggregate.name, unit)); err != nil {
+			return false, fmt.Errorf("failed to create view %s: %v", aggregate.name, err)
+		}
+	}
+
+	if !installed {
+		return false, nil
+	}
+	var hypertable bool
+	if err := conn.QueryRow(ctx, hypertableQuery).Scan(&hypertable); err != nil {
+		return false, fmt.Errorf("failed to look up the hypertable: %v", err)
+	}
+	return hypertable, nil
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// relationKind returns the pg_class relkind of a relation, empty when it does
+// not exist.
+func relationKind(ctx context.Context, q queryRower, name string) (string, error) {
+	var kind string
+	err := q.QueryRow(ctx, relationKindQuery, name).Scan(&kind)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s: %v", name, err)
+	}
+	return kind, nil
+}
+
+// isContinuousAggregate tells whether name is a continuous aggregate of the
+// current schema. It needs the timescaledb extension.
+func isContinuousAggregate(ctx context.Context, q queryRower, name string) (bool, error) {
+	var exists bool
+	if err := q.QueryRow(ctx, continuousAggregateQuery, name).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up continuous aggregate %s: %v", name, err)
+	}
+	return exists, nil
+}
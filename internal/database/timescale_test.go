@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+func TestSetupTimescaleTwice(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      string
+		second     string
+		hypertable bool
+	}{
+		{"plain Postgres", TimescaleOff, TimescaleOff, false},
+		{"TimescaleDB", TimescaleOn, TimescaleOn, true},
+		{"TimescaleDB after plain Postgres", TimescaleOff, TimescaleOn, true},
+		{"plain Postgres after TimescaleDB", TimescaleOn, TimescaleOff, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the schema is migrated without TimescaleDB first, like a
+			// database of a release before it
+			db := newTestPostgres(t, TimescaleOff)
+			ctx := context.Background()
+			if tt.first == TimescaleOn || tt.second == TimescaleOn {
+				var available bool
+				if err := db.db.QueryRow(ctx, timescaleAvailableQuery).Scan(&available); err != nil || !available {
+					t.Skipf("the server does not offer TimescaleDB: %v", err)
+				}
+			}
+			db.exec(t, `INSERT INTO retentionconfig (chain_id, retention_days, compress_after_days) VALUES ('', 30, 7), ('1', 90, 0)`)
+			if err := db.InsertOracleMetricsBatch([]helpers.OracleMetrics{testUpdate("0xa", "BTC/USD", 1, 0, 1)}); err != nil {
+				t.Fatalf("InsertOracleMetricsBatch: %v", err)
+			}
+
+			var hypertable bool
+			for _, mode := range []string{tt.first, tt.second} {
+				var err error
+				if hypertable, err = migrate(ctx, db.db, mode); err != nil {
+					t.Fatalf("migrate with TIMESCALEDB=%s: %v", mode, err)
+				}
+			}
+			if hypertable != tt.hypertable {
+				t.Errorf("hypertable %t, want %t", hypertable, tt.hypertable)
+			}
+
+			for _, aggregate := range aggregates {
+				var updates int
+				if err := db.db.QueryRow(ctx, "SELECT COALESCE(sum(updates), 0) FROM "+aggregate.name).Scan(&updates); err != nil {
+					t.Errorf("failed to read %s: %v", aggregate.name, err)
+				}
+			}
+
+			if tt.hypertable {
+				var jobs int
+				err := db.db.QueryRow(ctx, `SELECT count(*) FROM timescaledb_information.jobs WHERE proc_name IN ('policy_retention', 'policy_compression') AND hypertable_schema = current_schema() AND hypertable_name = 'feederupdates'`).Scan(&jobs)
+				if err != nil {
+					t.Fatalf("failed to read the policies: %v", err)
+				}
+				if jobs != 2 {
+					t.Errorf("got %d retention and compression policies, want 2", jobs)
+				}
+			}
+		})
+	}
+}
@@ -17,15 +17,81 @@ type Target struct {
 	CreationBlock      uint64    `json:"creation-block"`
 	LatestScrapedBlock uint64    `json:"latest-scraped-block"`
 	CreatedDate        time.Time `json:"createddate"`
+	Decimals           int       `json:"decimals"`
 }
 
 type Oracle struct {
 	ContractAddress    common.Address
-	ContractABI        *abi.ABI
+	Contract           *OracleContract
 	NodeUrl            string
 	ChainID            string
 	LatestScrapedBlock *big.Int
+	CreationBlock      uint64
 	CreatedDate        time.Time
+	// decimals of the values the oracle publishes
+	Decimals int
+}
+
+// Decimals of oracle values when the oracle configures none
+const DefaultAssetDecimals = 8
+
+// Version of the oracle contract an oracle is deployed from, named after its
+// ABI file. UpdateMethod and UpdateEvent name the method feeders call and the
+// event it emits, the fields name the event arguments carrying the update.
+// BatchUpdateMethod, when set, takes an array of keys and an array of packed
+// values, each the value in the upper and the timestamp in the lower 128 bits.
+// UpdaterEvent and OwnerEvent, when set, announce access control changes, the
+// address fields name their arguments. With SingleUpdater the UpdaterEvent
+// names the only updater, replacing the previous one, otherwise it is emitted
+// both when an updater is added and when it is removed. ValueMethod and LastUpdateBlockMethod, when set, name the views
+// returning the stored value and timestamp of a key and the block of the
+// latest update.
+type OracleContract struct {
+	Name                  string
+	ABI                   *abi.ABI
+	UpdateMethod          string
+	BatchUpdateMethod     string
+	UpdateEvent           string
+	KeyField              string
+	ValueField            string
+	TimestampField        string
+	UpdaterEvent          string
+	UpdaterField          string
+	SingleUpdater         bool
+	OwnerEvent            string
+	PreviousOwnerField    string
+	NewOwnerField         string
+	ValueMethod           string
+	LastUpdateBlockMethod string
+}
+
+// Node endpoint of a chain, lower priorities are preferred
+type Endpoint struct {
+	URL      string
+	Priority int
+}
+
+// Connection settings of a chain
+type ChainConfig struct {
+	ChainID       string
+	RPC           []Endpoint
+	WS            []Endpoint
+	Confirmations uint64
+	HeadQuorum    int
+}
+
+// Health of a node endpoint as seen by the client pool
+type EndpointStatus struct {
+	ChainID   string
+	URL       string
+	Priority  int
+	Calls     uint64
+	Errors    uint64
+	ErrorRate float64
+	Latency   time.Duration
+	Head      uint64
+	HeadLag   uint64
+	Healthy   bool
 }
 
 // Event emitted by the oracle contract
@@ -35,6 +101,89 @@ type OracleUpdate struct {
 	Timestamp *big.Int
 }
 
+// Transaction to an oracle that reverted, TransactionTo is the oracle.
+// Method is the called method, or its selector when the ABI does not know it,
+// AssetKeys are the keys an update call tried to update. RevertReason comes
+// from replaying the call, empty when unknown.
+type FailedUpdate struct {
+	TransactionMetadata
+	Method       string
+	AssetKeys    []string
+	RevertReason string
+}
+
+// Reverted transactions a feeder sent to an oracle since some time, with the
+// most recent revert.
+type FailureSummary struct {
+	ChainID       string
+	OracleAddress string
+	Sender        string
+	Failures      uint64
+	LastFailure   time.Time
+	LastReason    string
+}
+
+// Access control events of an oracle
+const (
+	AccessUpdaterChange  = "updater_change"
+	AccessUpdaterRemoved = "updater_removed"
+	AccessUpdaterSet     = "updater_set"
+	AccessOwnerChange    = "owner_change"
+)
+
+// Access control change of an oracle, from an UpdaterAddressChange or
+// OwnershipTransferred log. Method is the oracle method the transaction
+// called, empty when it called the oracle through another contract. A
+// Removed event was orphaned by a reorg.
+//
+// The scraper records every UpdaterAddressChange of a multi updater oracle as
+// an updater_change, the database tells removals apart when reading: the
+// contract rejects adding an authorized updater and removing an unknown one,
+// so the logs naming an address alternate between adding and removing it.
+type AccessEvent struct {
+	ChainID         string
+	OracleAddress   string
+	TransactionHash string
+	LogIndex        uint
+	BlockNumber     uint64
+	BlockTimestamp  time.Time
+	Kind            string
+	Method          string
+	PreviousAddress string
+	NewAddress      string
+	Sender          string
+	Removed         bool
+}
+
+// Updater address an oracle currently accepts, according to its access
+// control events.
+type AuthorizedUpdater struct {
+	ChainID       string
+	OracleAddress string
+	Updater       string
+	SinceBlock    uint64
+	SinceTime     time.Time
+}
+
+// Updater address configured as expected for an oracle. Empty ChainID or
+// OracleAddress match any chain or oracle.
+type AllowedUpdater struct {
+	ChainID       string
+	OracleAddress string
+	Updater       string
+	Label         string
+}
+
+// Wallet that pushed updates to an oracle, with its first and latest update
+type OracleSender struct {
+	ChainID       string
+	OracleAddress string
+	Sender        string
+	Updates       uint64
+	FirstUpdate   time.Time
+	LastUpdate    time.Time
+}
+
 // Metadata on any transaction
 type TransactionMetadata struct {
 	BlockNumber     string
@@ -43,24 +192,64 @@ type TransactionMetadata struct {
 	TransactionFrom common.Address
 	TransactionTo   common.Address
 	TransactionHash string
-	TransactionCost string
-	SenderBalance   string
-	GasUsed         string
-	GasCost         string
-	CreationBlock   string
+	// gas used times the effective gas price, in wei
+	TransactionCost *big.Int
+	// sender balance when the update was scraped, nil when unknown
+	SenderBalance *big.Int
+	GasUsed       uint64
+	// effective gas price, in wei
+	GasCost       *big.Int
+	CreationBlock string
 }
 
 // All the data scraped
 type OracleMetrics struct {
 	TransactionMetadata
 	AssetKey        string
-	AssetPrice      string
+	AssetPrice      *big.Int
+	AssetDecimals   int
 	UpdateTimestamp string
+	// index of the update log in its block, the position in the calldata
+	// for updates that emitted no log
+	LogIndex uint
+	// Removed is set when the update was reverted by a chain reorganisation
+	// and has to be deleted rather than stored.
+	Removed bool
 }
 
 type OracleMetricsState struct {
+	ChainID       string
+	LastBlock     uint64
+	LastBlockHash string
+}
+
+// Metrics scraped from a contiguous block range together with the checkpoint
+// to persist once all of them have been stored. Done receives the outcome of
+// the write so the scraper only advances after a durable commit. Failed holds
+// the reverted update transactions of the range, Access its access control
+// events and Uncovered the parts whose reverted transactions could not be
+// looked for.
+// A Rollback batch carries no metrics: the writer discards every stored update
+// above State.LastBlock before moving the checkpoint back to it. A Repair batch
+// re-stores an already scanned range and leaves the checkpoint untouched.
+type MetricsBatch struct {
+	Metrics   []OracleMetrics
+	Failed    []FailedUpdate
+	Access    []AccessEvent
+	Uncovered []UncoveredRange
+	State     OracleMetricsState
+	Rollback  bool
+	Repair    bool
+	Done      chan error
+}
+
+// Blocks scanned with eth_getLogs on a node serving no traces. Reverted
+// transactions emit no logs, the ones to the oracles in these blocks are
+// missing from the failed updates.
+type UncoveredRange struct {
 	ChainID   string
-	LastBlock uint64
+	FromBlock uint64
+	ToBlock   uint64
 }
 
 type OracleUpdateEvent struct {
@@ -70,6 +259,124 @@ type OracleUpdateEvent struct {
 	BlockTimestamp time.Time
 }
 
+// Latest update stored for an asset key of an oracle
+type AssetUpdate struct {
+	ChainID       string
+	OracleAddress string
+	AssetKey      string
+	UpdateBlock   uint64
+	UpdateTime    time.Time
+}
+
+// Key an oracle is expected to serve, declared in the asset catalogue. Zero
+// Decimals, Heartbeat or DeviationPercent mean none is declared.
+type CatalogueEntry struct {
+	ChainID          string
+	OracleAddress    string
+	AssetKey         string
+	Symbol           string
+	Decimals         int
+	Heartbeat        time.Duration
+	DeviationPercent float64
+}
+
+// Maximum time allowed between two updates. Empty fields match any chain,
+// oracle or key and the most specific rule wins.
+type HeartbeatRule struct {
+	ChainID       string
+	OracleAddress string
+	AssetKey      string
+	Interval      time.Duration
+}
+
+// Limits for the value pushed to an asset key. Empty scope fields match any
+// chain, oracle or key and the most specific rule wins. Zero limits disable
+// the corresponding comparison. Zero Decimals keeps the decimals the catalogue
+// declares for the key, or else those the update was decoded with.
+type DeviationRule struct {
+	ChainID                      string
+	OracleAddress                string
+	AssetKey                     string
+	MaxJumpPercent               float64
+	MaxReferenceDeviationPercent float64
+	Decimals                     int
+}
+
+// Problem found while checking an oracle update
+type Finding struct {
+	ChainID         string
+	OracleAddress   string
+	TransactionHash string
+	AssetKey        string
+	Kind            string
+	Severity        string
+	Message         string
+	CreatedAt       time.Time
+}
+
+// Wallet that pushed updates on a chain
+type Feeder struct {
+	ChainID string
+	Address string
+}
+
+// Balance of a feeder wallet at a point in time, in wei
+type FeederBalance struct {
+	Feeder
+	Balance    string
+	RecordedAt time.Time
+}
+
+// Alert raised by one of the checks. Key identifies the condition so that a
+// firing alert is only notified once until it resolves.
+type Alert struct {
+	Key           string
+	Kind          string
+	Severity      string
+	ChainID       string
+	OracleAddress string
+	AssetKey      string
+	Message       string
+	Firing        bool
+	FiredAt       time.Time
+	ResolvedAt    time.Time
+}
+
+// Selection of stored updates. Zero times leave the range open, an empty
+// AssetKey selects every key.
+type UpdateFilter struct {
+	ChainID       string
+	OracleAddress string
+	AssetKey      string
+	From          time.Time
+	To            time.Time
+	Limit         int
+	Offset        int
+}
+
+// Transaction costs of an oracle over a time range. TotalCost and TotalGasUsed
+// count every transaction once, however many keys it updated.
+type OracleCost struct {
+	ChainID       string
+	OracleAddress string
+	Updates       uint64
+	Transactions  uint64
+	TotalCost     string
+	AverageCost   string
+	TotalGasUsed  string
+	FirstUpdate   time.Time
+	LastUpdate    time.Time
+}
+
+// How long updates of a chain are kept, an empty ChainID is the default for
+// chains without their own policy. Zero durations disable pruning or
+// compression.
+type RetentionPolicy struct {
+	ChainID       string
+	Retention     time.Duration
+	CompressAfter time.Duration
+}
+
 func PrettyPrint(i interface{}) string {
 	s, _ := json.MarshalIndent(i, "", "\t")
 	return string(s)
@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "oracle_monitoring"
+
+var (
+	HeadBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chain_head_block",
+		Help:      "Latest block reported by the RPC endpoints of the chain.",
+	}, []string{"chain_id"})
+
+	ScrapedBlock = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scraper_last_block",
+		Help:      "Last block committed by the block scanner.",
+	}, []string{"chain_id"})
+
+	ScrapeLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scraper_lag_blocks",
+		Help:      "Blocks between the chain head and the last committed block.",
+	}, []string{"chain_id"})
+
+	RPCCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_calls_total",
+		Help:      "RPC calls per endpoint.",
+	}, []string{"chain_id", "endpoint"})
+
+	RPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_errors_total",
+		Help:      "Failed RPC calls per endpoint.",
+	}, []string{"chain_id", "endpoint"})
+
+	RPCLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_latency_seconds",
+		Help:      "RPC call latency per endpoint.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"chain_id", "endpoint"})
+
+	WSReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_reconnects_total",
+		Help:      "Times the event subscription was re-established.",
+	}, []string{"chain_id"})
+
+	DBErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_errors_total",
+		Help:      "Failed database writes per operation.",
+	}, []string{"operation"})
+
+	TransactionCost = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "oracle_update_cost_wei",
+		Help:      "Cost of the latest update transaction of an asset key.",
+	}, []string{"chain_id", "oracle", "asset_key"})
+
+	FailedUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oracle_failed_updates_total",
+		Help:      "Transactions to an oracle that reverted.",
+	}, []string{"chain_id", "oracle"})
+
+	FailedUpdateCost = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oracle_failed_update_cost_wei_total",
+		Help:      "Gas spent on transactions to an oracle that reverted.",
+	}, []string{"chain_id", "oracle"})
+
+	StateMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oracle_state_mismatches_total",
+		Help:      "Reconciliations where the on-chain state of an oracle differed from the stored updates.",
+	}, []string{"chain_id", "oracle"})
+
+	Backfills = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oracle_backfills_total",
+		Help:      "Targeted backfills started after a state mismatch.",
+	}, []string{"chain_id", "oracle"})
+
+	SkippedChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alert_skipped_checks_total",
+		Help:      "Updates a check skipped because it was behind or had stopped.",
+	}, []string{"check"})
+
+	FeederBalance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "feeder_balance_wei",
+		Help:      "Latest sampled balance of a feeder wallet.",
+	}, []string{"chain_id", "address"})
+
+	updateAge = newAgeCollector()
+)
+
+// ageCollector reports the time elapsed since the last update of every asset
+// key, computed when Prometheus scrapes.
+type ageCollector struct {
+	mu      sync.Mutex
+	desc    *prometheus.Desc
+	updates map[[3]string]time.Time
+}
+
+func newAgeCollector() *ageCollector {
+	c := &ageCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "oracle_last_update_age_seconds"),
+			"Seconds since the latest update of an asset key.",
+			[]string{"chain_id", "oracle", "asset_key"}, nil,
+		),
+		updates: make(map[[3]string]time.Time),
+	}
+	prometheus.MustRegister(c)
+	return c
+}
+
+func (c *ageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *ageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for labels, at := range c.updates {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(at).Seconds(), labels[0], labels[1], labels[2])
+	}
+}
+
+// ObserveUpdate records a stored update of an asset key.
+func ObserveUpdate(chainID, oracle, assetKey string, at time.Time, costWei *big.Int) {
+	labels := [3]string{chainID, strings.ToLower(oracle), assetKey}
+
+	updateAge.mu.Lock()
+	if at.After(updateAge.updates[labels]) {
+		updateAge.updates[labels] = at
+	}
+	updateAge.mu.Unlock()
+
+	if costWei != nil {
+		value, _ := new(big.Float).SetInt(costWei).Float64()
+		TransactionCost.WithLabelValues(labels[:]...).Set(value)
+	}
+}
+
+// ObserveFailedUpdate records a reverted transaction to an oracle.
+func ObserveFailedUpdate(chainID, oracle string, costWei *big.Int) {
+	labels := []string{chainID, strings.ToLower(oracle)}
+	FailedUpdates.WithLabelValues(labels...).Inc()
+	if costWei != nil {
+		value, _ := new(big.Float).SetInt(costWei).Float64()
+		FailedUpdateCost.WithLabelValues(labels...).Add(value)
+	}
+}
+
+// ObserveBalance records a feeder balance sample.
+func ObserveBalance(chainID, address string, balance *big.Int) {
+	value, _ := new(big.Float).SetInt(balance).Float64()
+	FeederBalance.WithLabelValues(chainID, strings.ToLower(address)).Set(value)
+}
+
+// ChannelDepth exports the number of items waiting in a channel.
+func ChannelDepth(channel, chainID string, depth func() int) {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "channel_depth",
+		Help:        "Items waiting in an internal channel.",
+		ConstLabels: prometheus.Labels{"channel": channel, "chain_id": chainID},
+	}, func() float64 { return float64(depth()) })
+
+	err := prometheus.Register(gauge)
+	var registered prometheus.AlreadyRegisteredError
+	if err != nil && !errors.As(err, &registered) {
+		panic(err)
+	}
+}
+
+// EndpointLabel reduces a node URL to its host so API keys carried in the
+// path or query never end up in a label.
+func EndpointLabel(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+	return u.Host
+}
+
+// NewServer returns a server exposing the metrics on /metrics at addr.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,167 @@
+package scraper
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+// accessTopics returns the access control event IDs of every contract
+// version among the monitored oracles.
+func (s *scraperImpl) accessTopics() []common.Hash {
+	s.oraclesMu.RLock()
+	defer s.oraclesMu.RUnlock()
+
+	var topics []common.Hash
+	for _, oracle := range s.oraclesmap {
+		for _, topic := range accessTopics(oracle.Contract) {
+			if !containsTopic(topics, topic) {
+				topics = append(topics, topic)
+			}
+		}
+	}
+	return topics
+}
+
+// isAccessLog tells whether a log is an access control event of a monitored
+// oracle.
+func (s *scraperImpl) isAccessLog(eventLog types.Log) bool {
+	oracle, ok := s.oracle(eventLog.Address)
+	return ok && len(eventLog.Topics) > 0 && containsTopic(accessTopics(oracle.Contract), eventLog.Topics[0])
+}
+
+// parseAccessLog decodes an access control log of a monitored oracle.
+func (s *scraperImpl) parseAccessLog(eventLog types.Log, cache *logCache) (*helpers.AccessEvent, error) {
+	oracle, ok := s.oracle(eventLog.Address)
+	if !ok {
+		return nil, fmt.Errorf("unknown oracle %s", eventLog.Address.Hex())
+	}
+	contract := oracle.Contract
+
+	event, err := contract.ABI.EventByID(eventLog.Topics[0])
+	if err != nil {
+		return nil, fmt.Errorf("unknown event: %v", err)
+	}
+
+	fields := make(map[string]interface{})
+	if len(eventLog.Data) > 0 {
+		if err := contract.ABI.UnpackIntoMap(fields, event.Name, eventLog.Data); err != nil {
+			return nil, fmt.Errorf("failed to unpack log data: %v", err)
+		}
+	}
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(fields, indexed, eventLog.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("failed to unpack log topics: %v", err)
+	}
+
+	access := &helpers.AccessEvent{
+		ChainID:         s.chainID,
+		OracleAddress:   eventLog.Address.Hex(),
+		TransactionHash: strings.ToLower(eventLog.TxHash.Hex()),
+		LogIndex:        eventLog.Index,
+		BlockNumber:     eventLog.BlockNumber,
+	}
+	switch event.Name {
+	case contract.UpdaterEvent:
+		access.Kind = helpers.AccessUpdaterChange
+		if contract.SingleUpdater {
+			access.Kind = helpers.AccessUpdaterSet
+		}
+		access.NewAddress = addressField(fields, contract.UpdaterField)
+	case contract.OwnerEvent:
+		access.Kind = helpers.AccessOwnerChange
+		access.PreviousAddress = addressField(fields, contract.PreviousOwnerField)
+		access.NewAddress = addressField(fields, contract.NewOwnerField)
+	default:
+		return nil, fmt.Errorf("%s is no access control event", event.Name)
+	}
+
+	header, ok := cache.headers[eventLog.BlockNumber]
+	if !ok {
+		header, err = s.client.HeaderByNumber(s.ctx, new(big.Int).SetUint64(eventLog.BlockNumber))
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve block %d: %v", eventLog.BlockNumber, err)
+		}
+		cache.headers[eventLog.BlockNumber] = header
+	}
+	access.BlockTimestamp = time.Unix(int64(header.Time), 0)
+
+	tx, ok := cache.txs[eventLog.TxHash]
+	if !ok {
+		tx, _, err = s.client.TransactionByHash(s.ctx, eventLog.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transaction: %v", err)
+		}
+		cache.txs[eventLog.TxHash] = tx
+	}
+	sender, err := s.getTransactionSender(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender: %v", err)
+	}
+	access.Sender = sender.Hex()
+
+	// calls through a multisig or proxy name no method of the oracle
+	if tx.To() != nil && *tx.To() == eventLog.Address && len(tx.Data()) >= 4 {
+		if method, err := contract.ABI.MethodById(tx.Data()[:4]); err == nil {
+			access.Method = method.Name
+		}
+	}
+	return access, nil
+}
+
+// parseBlockAccess returns the access control events of the monitored
+// oracles in a block, whichever contract the transactions called.
+func (s *scraperImpl) parseBlockAccess(block *types.Block) ([]helpers.AccessEvent, error) {
+	topics := s.accessTopics()
+	if len(topics) == 0 {
+		return nil, nil
+	}
+
+	hash := block.Hash()
+	logs, err := s.client.FilterLogs(s.ctx, ethereum.FilterQuery{
+		BlockHash: &hash,
+		Addresses: s.oraclesaddresses,
+		Topics:    [][]common.Hash{topics},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter access logs of block %d: %v", block.NumberU64(), err)
+	}
+
+	var events []helpers.AccessEvent
+	cache := newLogCache()
+	cache.headers[block.NumberU64()] = block.Header()
+	for _, l := range logs {
+		if !s.isAccessLog(l) {
+			continue
+		}
+		if tx := block.Transaction(l.TxHash); tx != nil {
+			cache.txs[l.TxHash] = tx
+		}
+		access, err := s.parseAccessLog(l, cache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse access log %d of %s: %v", l.Index, l.TxHash.Hex(), err)
+		}
+		events = append(events, *access)
+	}
+	return events, nil
+}
+
+func addressField(fields map[string]interface{}, name string) string {
+	if address, ok := fields[name].(common.Address); ok {
+		return address.Hex()
+	}
+	return ""
+}
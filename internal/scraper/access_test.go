@@ -0,0 +1,22 @@
+package scraper
+
+import "testing"
+
+func TestAccessTopics(t *testing.T) {
+	tests := []struct {
+		name   string
+		abi    string
+		topics int
+	}{
+		{"updater and owner events", "oracle-v2", 2},
+		{"updater event only", "oracle-v1", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if topics := accessTopics(testContract(t, tt.abi)); len(topics) != tt.topics {
+				t.Errorf("got %d access topics, want %d", len(topics), tt.topics)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+// accessTopics returns the IDs of the access control events.
+func accessTopics(c *helpers.OracleContract) []common.Hash {
+	var topics []common.Hash
+	for _, name := range []string{c.UpdaterEvent, c.OwnerEvent} {
+		if event, ok := c.ABI.Events[name]; ok && name != "" {
+			topics = append(topics, event.ID)
+		}
+	}
+	return topics
+}
+
+// updateTopic returns the ID of the update event.
+func updateTopic(c *helpers.OracleContract) common.Hash {
+	return c.ABI.Events[c.UpdateEvent].ID
+}
+
+// unpackUpdate decodes the data of an update event.
+func unpackUpdate(c *helpers.OracleContract, data []byte) (*helpers.OracleUpdate, error) {
+	fields := make(map[string]interface{})
+	if err := c.ABI.UnpackIntoMap(fields, c.UpdateEvent, data); err != nil {
+		return nil, err
+	}
+
+	key, ok := fields[c.KeyField].(string)
+	value, ok2 := fields[c.ValueField].(*big.Int)
+	timestamp, ok3 := fields[c.TimestampField].(*big.Int)
+	if !ok || !ok2 || !ok3 {
+		return nil, fmt.Errorf("unexpected %s fields for contract %s", c.UpdateEvent, c.Name)
+	}
+	return &helpers.OracleUpdate{Key: key, Value: value, Timestamp: timestamp}, nil
+}
+
+// updateMethodOf returns the update method called by calldata, empty when
+// the calldata calls none.
+func updateMethodOf(c *helpers.OracleContract, data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	for _, name := range []string{c.UpdateMethod, c.BatchUpdateMethod} {
+		if method, ok := c.ABI.Methods[name]; ok && name != "" && bytes.Equal(data[:4], method.ID) {
+			return name
+		}
+	}
+	return ""
+}
+
+// unpackUpdateCall decodes the inputs of an update method call into one
+// update per key.
+func unpackUpdateCall(c *helpers.OracleContract, data []byte) ([]helpers.OracleUpdate, error) {
+	switch updateMethodOf(c, data) {
+	case c.UpdateMethod:
+		fields := make(map[string]interface{})
+		if err := c.ABI.Methods[c.UpdateMethod].Inputs.UnpackIntoMap(fields, data[4:]); err != nil {
+			return nil, err
+		}
+		key, ok := fields[c.KeyField].(string)
+		value, ok2 := fields[c.ValueField].(*big.Int)
+		timestamp, ok3 := fields[c.TimestampField].(*big.Int)
+		if !ok || !ok2 || !ok3 {
+			return nil, fmt.Errorf("unexpected %s arguments for contract %s", c.UpdateMethod, c.Name)
+		}
+		return []helpers.OracleUpdate{{Key: key, Value: value, Timestamp: timestamp}}, nil
+	case "":
+		return nil, fmt.Errorf("calldata calls no update method of contract %s", c.Name)
+	}
+	return unpackBatchUpdate(c, data)
+}
+
+// unpackBatchUpdate decodes the calldata of a batch update.
+func unpackBatchUpdate(c *helpers.OracleContract, data []byte) ([]helpers.OracleUpdate, error) {
+	method := c.ABI.Methods[c.BatchUpdateMethod]
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, fmt.Errorf("unexpected %s arguments for contract %s", c.BatchUpdateMethod, c.Name)
+	}
+	keys, ok := args[0].([]string)
+	packed, ok2 := args[1].([]*big.Int)
+	if !ok || !ok2 || len(keys) != len(packed) {
+		return nil, fmt.Errorf("unexpected %s arguments for contract %s", c.BatchUpdateMethod, c.Name)
+	}
+
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	updates := make([]helpers.OracleUpdate, 0, len(keys))
+	for i, key := range keys {
+		updates = append(updates, helpers.OracleUpdate{
+			Key:       key,
+			Value:     new(big.Int).Rsh(packed[i], 128),
+			Timestamp: new(big.Int).And(packed[i], mask),
+		})
+	}
+	return updates, nil
+}
@@ -0,0 +1,99 @@
+package scraper
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/diadata-org/oracle-monitoring/internal/config"
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+func testContract(t *testing.T, name string) *helpers.OracleContract {
+	t.Helper()
+
+	registry, err := config.LoadABIRegistry("../abi")
+	if err != nil {
+		t.Fatalf("LoadABIRegistry: %v", err)
+	}
+	contract, err := registry.Contract(name)
+	if err != nil {
+		t.Fatalf("Contract: %v", err)
+	}
+	return contract
+}
+
+func TestUnpackUpdateCall(t *testing.T) {
+	v2 := testContract(t, "oracle-v2")
+	v1 := testContract(t, "oracle-v1")
+
+	pack := func(c *helpers.OracleContract, method string, args ...interface{}) []byte {
+		data, err := c.ABI.Pack(method, args...)
+		if err != nil {
+			t.Fatalf("Pack %s: %v", method, err)
+		}
+		return data
+	}
+	packed := func(value, timestamp int64) *big.Int {
+		return new(big.Int).Or(new(big.Int).Lsh(big.NewInt(value), 128), big.NewInt(timestamp))
+	}
+
+	tests := []struct {
+		name     string
+		contract *helpers.OracleContract
+		data     []byte
+		want     []helpers.OracleUpdate
+		wantErr  bool
+	}{
+		{
+			name:     "single update",
+			contract: v2,
+			data:     pack(v2, "setValue", "BTC/USD", big.NewInt(42), big.NewInt(1700000000)),
+			want:     []helpers.OracleUpdate{{Key: "BTC/USD", Value: big.NewInt(42), Timestamp: big.NewInt(1700000000)}},
+		},
+		{
+			name:     "batch update",
+			contract: v2,
+			data:     pack(v2, "setMultipleValues", []string{"BTC/USD", "ETH/USD"}, []*big.Int{packed(42, 1700000000), packed(7, 1700000001)}),
+			want: []helpers.OracleUpdate{
+				{Key: "BTC/USD", Value: big.NewInt(42), Timestamp: big.NewInt(1700000000)},
+				{Key: "ETH/USD", Value: big.NewInt(7), Timestamp: big.NewInt(1700000001)},
+			},
+		},
+		{
+			name:     "first version",
+			contract: v1,
+			data:     pack(v1, "setValue", "BTC/USD", big.NewInt(42), big.NewInt(1700000000)),
+			want:     []helpers.OracleUpdate{{Key: "BTC/USD", Value: big.NewInt(42), Timestamp: big.NewInt(1700000000)}},
+		},
+		{
+			name:     "batch update unknown to the first version",
+			contract: v1,
+			data:     pack(v2, "setMultipleValues", []string{"BTC/USD"}, []*big.Int{packed(42, 1700000000)}),
+			wantErr:  true,
+		},
+		{
+			name:     "no update method",
+			contract: v2,
+			data:     pack(v2, "getValue", "BTC/USD"),
+			wantErr:  true,
+		},
+		{
+			name:     "truncated calldata",
+			contract: v2,
+			data:     []byte{0x01, 0x02},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates, err := unpackUpdateCall(tt.contract, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if !sameUpdates(updates, tt.want) {
+				t.Errorf("got %+v, want %+v", updates, tt.want)
+			}
+		})
+	}
+}
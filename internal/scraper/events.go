@@ -0,0 +1,391 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
+)
+
+const (
+	// blocks replayed before the head when the listener first subscribes
+	eventsLookback = 500
+	// bounds of the exponential backoff between WebSocket reconnects
+	wsMinBackoff = 1 * time.Second
+	wsMaxBackoff = 2 * time.Minute
+)
+
+// logID identifies a log within the chain.
+type logID struct {
+	txHash common.Hash
+	index  uint
+}
+
+// errResubscribe asks the listener to subscribe again for a new address set.
+var errResubscribe = errors.New("resubscribe requested")
+
+func (s *scraperImpl) currentWsClient() *ethclient.Client {
+	s.wsMu.RLock()
+	defer s.wsMu.RUnlock()
+	return s.wsClient
+}
+
+// reconnectWsNode closes the failed WebSocket client and dials the next
+// endpoint of the chain, falling back through the others in order.
+func (s *scraperImpl) reconnectWsNode() error {
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+
+	if s.wsClient != nil {
+		s.wsClient.Close()
+	}
+	s.wsIndex++
+
+	client, err := s.connectToWsNode()
+	if err != nil {
+		return err
+	}
+	s.wsClient = client
+	return nil
+}
+
+// Reconnects returns how many times the event subscription had to be
+// re-established.
+func (s *scraperImpl) Reconnects() uint64 {
+	return s.reconnects.Load()
+}
+
+// listenEvents supervises the OracleUpdate subscription. Whenever the
+// subscription fails it re-dials the WebSocket node with exponential backoff,
+// subscribes again and replays the blocks missed in between.
+func (s *scraperImpl) listenEvents() {
+	defer s.wg.Done()
+
+	if len(s.oraclesaddresses) <= 0 {
+		return
+	}
+
+	var from uint64
+	for {
+		head, err := s.currentWsClient().BlockNumber(s.ctx)
+		if err == nil {
+			if head > eventsLookback {
+				from = head - eventsLookback
+			}
+			break
+		}
+
+		s.logger.Printf("Failed to get latest BlockNumber : %v chainid %s ", err, s.chainID)
+		if !s.sleep(wsMinBackoff) {
+			return
+		}
+	}
+
+	backoff := wsMinBackoff
+	for {
+		started := time.Now()
+		err := s.subscribeEvents(from)
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		from = s.lastEventBlock
+		if errors.Is(err, errResubscribe) {
+			continue
+		}
+
+		// a subscription that stayed up for a while is not flapping
+		if time.Since(started) > wsMaxBackoff {
+			backoff = wsMinBackoff
+		}
+
+		reconnects := s.reconnects.Add(1)
+		metrics.WSReconnects.WithLabelValues(s.chainID).Inc()
+		s.logger.Printf("subscription error: %v chainID %s, reconnecting in %s (reconnect %d)", err, s.chainID, backoff, reconnects)
+
+		if !s.sleep(backoff) {
+			return
+		}
+		backoff *= 2
+		if backoff > wsMaxBackoff {
+			backoff = wsMaxBackoff
+		}
+
+		if err := s.reconnectWsNode(); err != nil {
+			s.logger.Printf("failed to reconnect ws chainid %s: %v", s.chainID, err)
+		}
+	}
+}
+
+// subscribeEvents subscribes to the OracleUpdate logs of the current address
+// set, replays the logs from block from onwards and then forwards live logs
+// until the subscription fails or a resubscribe is requested.
+func (s *scraperImpl) subscribeEvents(from uint64) error {
+	addresses := s.eventAddressSet()
+	updateeventchan := make(chan types.Log)
+
+	subscription, err := s.currentWsClient().SubscribeFilterLogs(s.ctx, ethereum.FilterQuery{
+		Addresses: addresses,
+		Topics:    [][]common.Hash{s.logTopics()},
+	}, updateeventchan)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to event logs: %v", err)
+	}
+	defer subscription.Unsubscribe()
+
+	// subscribe first so nothing emitted during the replay is lost, duplicates
+	// are ignored on insert
+	if err := s.catchUpEvents(from, addresses); err != nil {
+		return err
+	}
+
+	confirm := time.NewTicker(scanPollInterval)
+	defer confirm.Stop()
+
+	for {
+		select {
+		case <-confirm.C:
+			if len(s.pendingLogs) == 0 {
+				continue
+			}
+			head, err := s.client.BlockNumber(s.ctx)
+			if err != nil {
+				s.logger.Printf("failed to retrieve the latest block: %v chainid %s", err, s.chainID)
+				continue
+			}
+			s.releaseLogs(head)
+		case err := <-subscription.Err():
+			if err == nil {
+				err = errors.New("subscription closed")
+			}
+			return err
+		case <-s.resubscribe:
+			return errResubscribe
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case eventLog := <-updateeventchan:
+			s.handleEventLog(eventLog)
+			if eventLog.BlockNumber > s.lastEventBlock {
+				s.lastEventBlock = eventLog.BlockNumber
+			}
+		}
+	}
+}
+
+// catchUpEvents replays the OracleUpdate logs between from and the current
+// head over RPC, shrinking the range when the provider rejects it.
+func (s *scraperImpl) catchUpEvents(from uint64, addresses []common.Address) error {
+	head, err := s.client.BlockNumber(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the latest block: %v", err)
+	}
+
+	window := uint64(maxLogRange)
+	for from <= head {
+		to := from + window - 1
+		if to > head {
+			to = head
+		}
+
+		logs, err := s.client.FilterLogs(s.ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: addresses,
+			Topics:    [][]common.Hash{s.logTopics()},
+		})
+		if err != nil {
+			if isRangeTooLarge(err) && window > minLogRange {
+				window /= 2
+				continue
+			}
+			return fmt.Errorf("failed to replay logs %d-%d: %v", from, to, err)
+		}
+
+		for _, eventLog := range logs {
+			s.handleEventLog(eventLog)
+		}
+
+		s.lastEventBlock = to
+		from = to + 1
+	}
+
+	s.releaseLogs(head)
+	return nil
+}
+
+// handleEventLog holds a log back until it is confirmations blocks deep, the
+// depth the forward scanner waits for too. A reorg that removes a log still
+// held back drops it, one that removes a released log deletes what it stored.
+func (s *scraperImpl) handleEventLog(eventLog types.Log) {
+	if s.confirmations == 0 {
+		s.emitEventLog(eventLog)
+		return
+	}
+
+	id := logID{txHash: eventLog.TxHash, index: eventLog.Index}
+	if eventLog.Removed {
+		if _, ok := s.pendingLogs[id]; ok {
+			delete(s.pendingLogs, id)
+			return
+		}
+		s.emitEventLog(eventLog)
+		return
+	}
+	s.pendingLogs[id] = eventLog
+}
+
+// releaseLogs forwards the held back logs that are confirmations blocks deep
+// at head, in chain order.
+func (s *scraperImpl) releaseLogs(head uint64) {
+	if head < s.confirmations {
+		return
+	}
+
+	var confirmed []types.Log
+	for id, eventLog := range s.pendingLogs {
+		if eventLog.BlockNumber <= head-s.confirmations {
+			confirmed = append(confirmed, eventLog)
+			delete(s.pendingLogs, id)
+		}
+	}
+	sort.Slice(confirmed, func(i, j int) bool {
+		if confirmed[i].BlockNumber != confirmed[j].BlockNumber {
+			return confirmed[i].BlockNumber < confirmed[j].BlockNumber
+		}
+		return confirmed[i].Index < confirmed[j].Index
+	})
+	for _, eventLog := range confirmed {
+		s.emitEventLog(eventLog)
+	}
+}
+
+// emitEventLog turns a log into metrics for the writer. Logs reverted by a
+// reorg delete the update they stored.
+func (s *scraperImpl) emitEventLog(eventLog types.Log) {
+	if s.isAccessLog(eventLog) {
+		s.handleAccessLog(eventLog)
+		return
+	}
+
+	if eventLog.Removed {
+		// the update was orphaned by a reorg, the canonical log is
+		// delivered again by the subscription
+		s.logger.Printf("removed log %s in block %d chainid %s", eventLog.TxHash.Hex(), eventLog.BlockNumber, s.chainID)
+		removed := helpers.OracleMetrics{Removed: true}
+		removed.ChainID = s.chainID
+		removed.TransactionHash = eventLog.TxHash.Hex()
+		removed.BlockNumber = strconv.FormatUint(eventLog.BlockNumber, 10)
+		select {
+		case s.mchan <- removed:
+		case <-s.ctx.Done():
+		}
+		return
+	}
+
+	metrics, err := s.parseOracleLog(eventLog, newLogCache())
+	if err != nil {
+		s.logger.Printf("failed to parse oracle update log %s: %v chainid %s", eventLog.TxHash.Hex(), err, s.chainID)
+		return
+	}
+	if metrics == nil {
+		return
+	}
+
+	select {
+	case s.mchan <- *metrics:
+	case <-s.ctx.Done():
+	}
+}
+
+// handleAccessLog forwards an access control log to accessChan.
+func (s *scraperImpl) handleAccessLog(eventLog types.Log) {
+	if s.accessChan == nil {
+		return
+	}
+
+	access := &helpers.AccessEvent{
+		ChainID:         s.chainID,
+		OracleAddress:   eventLog.Address.Hex(),
+		TransactionHash: strings.ToLower(eventLog.TxHash.Hex()),
+		LogIndex:        eventLog.Index,
+		BlockNumber:     eventLog.BlockNumber,
+		Removed:         true,
+	}
+	if !eventLog.Removed {
+		var err error
+		access, err = s.parseAccessLog(eventLog, newLogCache())
+		if err != nil {
+			s.logger.Printf("failed to parse access log %s: %v chainid %s", eventLog.TxHash.Hex(), err, s.chainID)
+			return
+		}
+	}
+
+	select {
+	case s.accessChan <- *access:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *scraperImpl) eventAddressSet() []common.Address {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	return s.eventAddresses
+}
+
+func sameAddresses(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, address := range a {
+		if !contains(b, address) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateEvents sets the oracles whose events are followed. The listener is
+// started on the first call and resubscribed when the address set changes.
+func (s *scraperImpl) UpdateEvents(oracles []helpers.Oracle) error {
+	s.logger.Printf("UpdateEvents started for chain %s, total oracles %d ", s.chainID, len(oracles))
+
+	oracleaddresses := make([]common.Address, 0, len(oracles))
+	s.oraclesMu.Lock()
+	for _, oracle := range oracles {
+		s.oraclesmap[oracle.ContractAddress] = oracle
+		oracleaddresses = append(oracleaddresses, oracle.ContractAddress)
+	}
+	s.oraclesMu.Unlock()
+
+	s.eventsMu.Lock()
+	changed := !sameAddresses(s.eventAddresses, oracleaddresses)
+	s.eventAddresses = append([]common.Address(nil), oracleaddresses...)
+	running := s.listening
+	s.listening = true
+	s.eventsMu.Unlock()
+
+	if !running {
+		s.wg.Add(1)
+		go s.listenEvents()
+		return nil
+	}
+
+	if changed {
+		select {
+		case s.resubscribe <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
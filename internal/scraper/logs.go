@@ -0,0 +1,433 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+const (
+	// gap to the head above which the scanner switches to eth_getLogs ranges
+	backfillThreshold = 1000
+	// bounds of the adaptive eth_getLogs range
+	minLogRange = 10
+	maxLogRange = 10000
+)
+
+// logCache keeps the chain data fetched while turning a set of logs into
+// metrics, so logs sharing a block, transaction or sender are only fetched once.
+type logCache struct {
+	headers  map[uint64]*types.Header
+	receipts map[common.Hash]*types.Receipt
+	txs      map[common.Hash]*types.Transaction
+	balances map[common.Address]*big.Int
+}
+
+func newLogCache() *logCache {
+	return &logCache{
+		headers:  make(map[uint64]*types.Header),
+		receipts: make(map[common.Hash]*types.Receipt),
+		txs:      make(map[common.Hash]*types.Transaction),
+		balances: make(map[common.Address]*big.Int),
+	}
+}
+
+// parseOracleLog builds the metrics of an update log, decoded with the
+// contract version of the emitting oracle. It returns nil for logs that are
+// not updates of that version. It is shared by the live subscription and the
+// log backfill so both produce identical rows.
+func (s *scraperImpl) parseOracleLog(eventLog types.Log, cache *logCache) (*helpers.OracleMetrics, error) {
+	oracle, ok := s.oracle(eventLog.Address)
+	if !ok {
+		return nil, fmt.Errorf("unknown oracle %s", eventLog.Address.Hex())
+	}
+	if len(eventLog.Topics) == 0 || eventLog.Topics[0] != updateTopic(oracle.Contract) {
+		return nil, nil
+	}
+
+	update, err := unpackUpdate(oracle.Contract, eventLog.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack log data: %v", err)
+	}
+
+	receipt, ok := cache.receipts[eventLog.TxHash]
+	if !ok {
+		receipt, err = s.client.TransactionReceipt(s.ctx, eventLog.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transaction receipt: %v", err)
+		}
+		cache.receipts[eventLog.TxHash] = receipt
+	}
+
+	header, ok := cache.headers[eventLog.BlockNumber]
+	if !ok {
+		header, err = s.client.HeaderByNumber(s.ctx, new(big.Int).SetUint64(eventLog.BlockNumber))
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve block %d: %v", eventLog.BlockNumber, err)
+		}
+		cache.headers[eventLog.BlockNumber] = header
+	}
+
+	tx, ok := cache.txs[eventLog.TxHash]
+	if !ok {
+		tx, _, err = s.client.TransactionByHash(s.ctx, eventLog.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transaction: %v", err)
+		}
+		cache.txs[eventLog.TxHash] = tx
+	}
+
+	sender, err := s.getTransactionSender(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender: %v", err)
+	}
+
+	metadata := helpers.TransactionMetadata{}
+	metadata.ChainID = s.chainID
+	metadata.BlockNumber = strconv.FormatUint(eventLog.BlockNumber, 10)
+	metadata.BlockTimestamp = time.Unix(int64(header.Time), 0)
+	metadata.TransactionHash = strings.ToLower(eventLog.TxHash.Hex())
+	metadata.TransactionCost = new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice)
+	metadata.GasUsed = receipt.GasUsed
+	metadata.GasCost = receipt.EffectiveGasPrice
+	metadata.TransactionFrom = sender
+	metadata.TransactionTo = eventLog.Address
+
+	// use lates balance instead of block number as that need archieve node
+	balance, ok := cache.balances[sender]
+	if !ok {
+		balance, err = s.client.BalanceAt(s.ctx, sender, nil)
+		if err != nil {
+			s.logger.Printf("failed to get sender balance: %v", err)
+		}
+		cache.balances[sender] = balance
+	}
+	metadata.SenderBalance = balance
+
+	// a batch update emits one log per key, each carries its share of the gas
+	index, count := updateLogPosition(receipt, eventLog, updateTopic(oracle.Contract))
+
+	return &helpers.OracleMetrics{
+		TransactionMetadata: shareCost(metadata, index, count),
+		AssetKey:            update.Key,
+		AssetPrice:          update.Value,
+		AssetDecimals:       s.assetDecimals(eventLog.Address),
+		UpdateTimestamp:     update.Timestamp.String(),
+		LogIndex:            eventLog.Index,
+	}, nil
+}
+
+// updateLogPosition returns the position of an update log among the update
+// logs its oracle emitted in the same transaction, and their number.
+func updateLogPosition(receipt *types.Receipt, eventLog types.Log, topic common.Hash) (int, int) {
+	index, count := 0, 0
+	for _, l := range receipt.Logs {
+		if l.Address != eventLog.Address || len(l.Topics) == 0 || l.Topics[0] != topic {
+			continue
+		}
+		if l.Index < eventLog.Index {
+			index++
+		}
+		count++
+	}
+	if count == 0 {
+		return 0, 1
+	}
+	return index, count
+}
+
+// updateTopics returns the update event IDs of every contract version among
+// the monitored oracles.
+func (s *scraperImpl) updateTopics() []common.Hash {
+	s.oraclesMu.RLock()
+	defer s.oraclesMu.RUnlock()
+
+	var topics []common.Hash
+	for _, oracle := range s.oraclesmap {
+		topic := updateTopic(oracle.Contract)
+		if !containsTopic(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// logTopics returns the update and access control event IDs of the
+// monitored oracles.
+func (s *scraperImpl) logTopics() []common.Hash {
+	return append(s.updateTopics(), s.accessTopics()...)
+}
+
+func containsTopic(topics []common.Hash, topic common.Hash) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// isRangeTooLarge tells whether a provider rejected eth_getLogs because the
+// block range or the number of results exceeds its limits.
+func isRangeTooLarge(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, hint := range []string{
+		"too many results",
+		"more than 10000 results",
+		"query returned more than",
+		"block range",
+		"range is too large",
+		"limit exceeded",
+		"response size exceeded",
+	} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnsupported tells whether an endpoint does not serve an RPC method.
+func isUnsupported(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, hint := range []string{
+		"method not found",
+		"does not exist",
+		"not supported",
+		"not available",
+		"unsupported method",
+	} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanLogs builds the batch for [from, to] from the OracleUpdate logs of the
+// range, fetching only the blocks and transactions those logs reference.
+func (s *scraperImpl) scanLogs(from, to uint64) (helpers.MetricsBatch, common.Hash, error) {
+	batch := helpers.MetricsBatch{
+		State: helpers.OracleMetricsState{ChainID: s.chainID, LastBlock: to},
+	}
+
+	logs, err := s.client.FilterLogs(s.ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: s.oraclesaddresses,
+		Topics:    [][]common.Hash{s.logTopics()},
+	})
+	if err != nil {
+		return batch, common.Hash{}, err
+	}
+
+	cache := newLogCache()
+	if err := s.collectLogs(logs, cache, &batch); err != nil {
+		return batch, common.Hash{}, err
+	}
+	if err := s.scanFailed(from, to, &batch); err != nil {
+		return batch, common.Hash{}, err
+	}
+
+	header, ok := cache.headers[to]
+	if !ok {
+		header, err = s.client.HeaderByNumber(s.ctx, new(big.Int).SetUint64(to))
+		if err != nil {
+			return batch, common.Hash{}, fmt.Errorf("failed to retrieve header %d: %v", to, err)
+		}
+	}
+
+	batch.State.LastBlockHash = header.Hash().Hex()
+	return batch, header.Hash(), nil
+}
+
+// scanFailed appends the reverted transactions to the oracles in [from, to] to
+// batch. They emit no logs, so eth_getLogs misses them and trace_filter finds
+// them instead. When no endpoint serves traces the range is recorded as
+// uncovered: its reverted updates are neither stored nor alerted on.
+func (s *scraperImpl) scanFailed(from, to uint64, batch *helpers.MetricsBatch) error {
+	if !s.noTraces {
+		hashes, err := s.client.FailedCalls(s.ctx, from, to, s.oraclesaddresses)
+		if err == nil {
+			return s.collectFailed(hashes, batch)
+		}
+		if !isUnsupported(err) {
+			return fmt.Errorf("failed to trace blocks %d-%d: %v", from, to, err)
+		}
+		s.noTraces = true
+		s.logger.Printf("no endpoint serves trace_filter, reverted updates of log ranges are recorded as uncovered chainid %s", s.chainID)
+	}
+	batch.Uncovered = append(batch.Uncovered, helpers.UncoveredRange{ChainID: s.chainID, FromBlock: from, ToBlock: to})
+	return nil
+}
+
+// collectFailed appends the transactions to the oracles among hashes that
+// reverted to batch.
+func (s *scraperImpl) collectFailed(hashes []common.Hash, batch *helpers.MetricsBatch) error {
+	blocks := make(map[uint64]*types.Block)
+	for _, hash := range hashes {
+		receipt, err := s.client.TransactionReceipt(s.ctx, hash)
+		if err != nil {
+			return fmt.Errorf("failed to get transaction receipt: %v", err)
+		}
+		if receipt.Status != types.ReceiptStatusFailed {
+			continue
+		}
+
+		block, ok := blocks[receipt.BlockNumber.Uint64()]
+		if !ok {
+			block, err = s.client.BlockByNumber(s.ctx, receipt.BlockNumber)
+			if err != nil {
+				return fmt.Errorf("failed to retrieve block %d: %v", receipt.BlockNumber.Uint64(), err)
+			}
+			blocks[receipt.BlockNumber.Uint64()] = block
+		}
+		tx := block.Transaction(hash)
+		if tx == nil || tx.To() == nil {
+			continue
+		}
+		oracle, ok := s.oracle(*tx.To())
+		if !ok {
+			continue
+		}
+
+		metadata, err := s.parseTransactionMetadata(s.ctx, s.client, block, tx, receipt)
+		if err != nil {
+			return fmt.Errorf("failed to parse transaction metadata: %v", err)
+		}
+		batch.Failed = append(batch.Failed, *s.parseFailedTransaction(s.ctx, tx, receipt, oracle, *metadata))
+	}
+	return nil
+}
+
+// collectLogs appends the updates and access control events of logs to batch.
+func (s *scraperImpl) collectLogs(logs []types.Log, cache *logCache, batch *helpers.MetricsBatch) error {
+	for _, eventLog := range logs {
+		if eventLog.Removed {
+			continue
+		}
+		if s.isAccessLog(eventLog) {
+			access, err := s.parseAccessLog(eventLog, cache)
+			if err != nil {
+				return fmt.Errorf("failed to parse access log %s: %v", eventLog.TxHash.Hex(), err)
+			}
+			batch.Access = append(batch.Access, *access)
+			continue
+		}
+		metrics, err := s.parseOracleLog(eventLog, cache)
+		if err != nil {
+			return fmt.Errorf("failed to parse log %s: %v", eventLog.TxHash.Hex(), err)
+		}
+		if metrics == nil {
+			continue
+		}
+		batch.Metrics = append(batch.Metrics, *metrics)
+	}
+	return nil
+}
+
+// Backfill scans the logs of one oracle in [from, to] again and stores them
+// as repair batches, which leave the checkpoint where it is. Updates already
+// stored are skipped on insert. Like backfill it adapts the range to the
+// provider, on its own window since repairs run beside the scanner. It
+// returns the number of updates found.
+func (s *scraperImpl) Backfill(oracle common.Address, from, to uint64) (int, error) {
+	if s.batchChan == nil {
+		return 0, fmt.Errorf("scraper of chain %s has no batch writer", s.chainID)
+	}
+	if _, ok := s.oracle(oracle); !ok {
+		return 0, fmt.Errorf("oracle %s is not monitored on chain %s", oracle.Hex(), s.chainID)
+	}
+
+	start, found := from, 0
+	window := uint64(maxLogRange)
+	for from <= to {
+		end := from + window - 1
+		if end > to {
+			end = to
+		}
+
+		logs, err := s.client.FilterLogs(s.ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []common.Address{oracle},
+			Topics:    [][]common.Hash{s.logTopics()},
+		})
+		if err != nil {
+			if isRangeTooLarge(err) && window > minLogRange {
+				window /= 2
+				continue
+			}
+			return found, fmt.Errorf("failed to backfill blocks %d-%d: %v", from, end, err)
+		}
+
+		batch := helpers.MetricsBatch{Repair: true, State: helpers.OracleMetricsState{ChainID: s.chainID}}
+		if err := s.collectLogs(logs, newLogCache(), &batch); err != nil {
+			return found, err
+		}
+		if err := s.commit(batch); err != nil {
+			return found, fmt.Errorf("failed to commit blocks %d-%d: %v", from, end, err)
+		}
+		found += len(batch.Metrics)
+		from = end + 1
+		window = growLogRange(window)
+	}
+
+	s.logger.Printf("repaired blocks %d-%d of oracle %s with %d updates chainid %s", start, to, oracle.Hex(), found, s.chainID)
+	return found, nil
+}
+
+// backfill scans [from, head] with eth_getLogs, halving the range whenever
+// the provider rejects it and growing it back after each success. It returns
+// the next block to scan.
+func (s *scraperImpl) backfill(from, head uint64) (uint64, error) {
+	to := from + s.logRange - 1
+	if to > head {
+		to = head
+	}
+
+	batch, hash, err := s.scanLogs(from, to)
+	if err != nil {
+		if isRangeTooLarge(err) && s.logRange > minLogRange {
+			s.logRange /= 2
+			s.logger.Printf("log range rejected, shrinking to %d blocks chainid %s", s.logRange, s.chainID)
+			return from, nil
+		}
+		return from, fmt.Errorf("failed to backfill blocks %d-%d: %v", from, to, err)
+	}
+
+	if err := s.commit(batch); err != nil {
+		return from, fmt.Errorf("failed to commit blocks %d-%d: %v", from, to, err)
+	}
+	s.history.add(to, hash)
+	s.reportProgress(to, head)
+
+	s.logger.Printf("backfilled blocks %d-%d with %d updates for chain %s", from, to, len(batch.Metrics), s.chainID)
+
+	s.logRange = growLogRange(s.logRange)
+	return to + 1, nil
+}
+
+// growLogRange doubles an eth_getLogs range after a success, up to
+// maxLogRange.
+func growLogRange(window uint64) uint64 {
+	window *= 2
+	if window > maxLogRange {
+		return maxLogRange
+	}
+	return window
+}
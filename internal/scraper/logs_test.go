@@ -0,0 +1,46 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsRangeTooLarge(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"result limit", errors.New("query returned more than 10000 results"), true},
+		{"block range limit", errors.New("eth_getLogs block range is too large, max is 2000"), true},
+		{"response size", errors.New("Response size exceeded"), true},
+		{"timeout", errors.New("context deadline exceeded"), false},
+		{"other error", errors.New("header not found"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRangeTooLarge(tt.err); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrowLogRange(t *testing.T) {
+	tests := []struct {
+		window uint64
+		want   uint64
+	}{
+		{minLogRange, 2 * minLogRange},
+		{maxLogRange / 2, maxLogRange},
+		{maxLogRange/2 + 1, maxLogRange},
+		{maxLogRange, maxLogRange},
+	}
+
+	for _, tt := range tests {
+		if got := growLogRange(tt.window); got != tt.want {
+			t.Errorf("growLogRange(%d) = %d, want %d", tt.window, got, tt.want)
+		}
+	}
+}
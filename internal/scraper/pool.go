@@ -0,0 +1,333 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
+)
+
+const (
+	// blocks an endpoint may trail the best head before it is considered stale
+	maxHeadLag = 5
+	// weight of the latest observation in the latency and error averages
+	healthSmoothing = 0.2
+	// time allowed for a single endpoint to report its head
+	headTimeout = 10 * time.Second
+	// time allowed for a single endpoint to answer any other call before the
+	// next one is tried
+	callTimeout = 30 * time.Second
+)
+
+// ChainClient is read access to a chain through its pool of RPC endpoints,
+// shared with the monitors that live outside the scraper.
+type ChainClient interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// endpointClient is a node endpoint together with its observed health.
+type endpointClient struct {
+	helpers.Endpoint
+	client    *ethclient.Client
+	label     string
+	calls     uint64
+	errors    uint64
+	errorRate float64
+	latency   time.Duration
+	head      uint64
+}
+
+// clientPool spreads calls over the RPC endpoints of a chain. Every call goes
+// to the healthiest endpoint first and fails over to the others in order.
+type clientPool struct {
+	mu         sync.Mutex
+	chainID    string
+	endpoints  []*endpointClient
+	bestHead   uint64
+	headQuorum int
+}
+
+// newClientPool dials every endpoint. It only fails when none of them can be
+// dialed.
+func newClientPool(ctx context.Context, chainID string, endpoints []helpers.Endpoint, headQuorum int) (*clientPool, error) {
+	p := &clientPool{chainID: chainID, headQuorum: headQuorum}
+	if p.headQuorum < 1 {
+		p.headQuorum = 1
+	}
+
+	var lastErr error
+	for _, endpoint := range endpoints {
+		client, err := ethclient.DialContext(ctx, endpoint.URL)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to connect to the node: %v", err)
+			continue
+		}
+		p.endpoints = append(p.endpoints, &endpointClient{Endpoint: endpoint, client: client, label: metrics.EndpointLabel(endpoint.URL)})
+	}
+
+	if len(p.endpoints) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no rpc endpoint configured for chain %s", chainID)
+		}
+		return nil, lastErr
+	}
+	return p, nil
+}
+
+// NewChainClient dials the RPC endpoints of a chain.
+func NewChainClient(ctx context.Context, chain helpers.ChainConfig) (ChainClient, error) {
+	pool, err := newClientPool(ctx, chain.ChainID, chain.RPC, chain.HeadQuorum)
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// score ranks an endpoint, lower is better. Stale endpoints always rank
+// behind the ones following the head.
+func (e *endpointClient) score(bestHead uint64) float64 {
+	score := float64(e.Priority) + e.errorRate*10 + e.latency.Seconds()
+	if bestHead > e.head+maxHeadLag {
+		score += 1000
+	}
+	return score
+}
+
+// ordered returns the endpoints from healthiest to least healthy.
+func (p *clientPool) ordered() []*endpointClient {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ordered := append([]*endpointClient(nil), p.endpoints...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].score(p.bestHead) < ordered[j].score(p.bestHead)
+	})
+	return ordered
+}
+
+func (p *clientPool) observe(e *endpointClient, took time.Duration, err error) {
+	metrics.RPCCalls.WithLabelValues(p.chainID, e.label).Inc()
+	metrics.RPCLatency.WithLabelValues(p.chainID, e.label).Observe(took.Seconds())
+	if err != nil {
+		metrics.RPCErrors.WithLabelValues(p.chainID, e.label).Inc()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	failed := 0.0
+	e.calls++
+	if err != nil {
+		e.errors++
+		failed = 1
+	}
+	e.errorRate = e.errorRate*(1-healthSmoothing) + failed*healthSmoothing
+	if e.latency == 0 {
+		e.latency = took
+	} else {
+		e.latency = time.Duration(float64(e.latency)*(1-healthSmoothing) + float64(took)*healthSmoothing)
+	}
+}
+
+// poolCall runs call against the endpoints in health order until one of them
+// succeeds. Each endpoint gets callTimeout, so a hanging one fails over.
+func poolCall[T any](ctx context.Context, p *clientPool, call func(context.Context, *ethclient.Client) (T, error)) (T, error) {
+	var result T
+	var err error
+
+	for _, endpoint := range p.ordered() {
+		callCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		started := time.Now()
+		result, err = call(callCtx, endpoint.client)
+		cancel()
+		if err != nil && isRevert(err) {
+			// the endpoint answered, the call itself reverted
+			p.observe(endpoint, time.Since(started), nil)
+			return result, err
+		}
+		p.observe(endpoint, time.Since(started), err)
+		if err == nil || ctx.Err() != nil {
+			return result, err
+		}
+	}
+	return result, err
+}
+
+// BlockNumber asks every endpoint for its head and returns the highest head
+// reached by at least headQuorum of them. Endpoints trailing that head are
+// deprioritised until they catch up.
+func (p *clientPool) BlockNumber(ctx context.Context) (uint64, error) {
+	p.mu.Lock()
+	endpoints := append([]*endpointClient(nil), p.endpoints...)
+	p.mu.Unlock()
+
+	heads := make([]uint64, len(endpoints))
+	errs := make([]error, len(endpoints))
+
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint *endpointClient) {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(ctx, headTimeout)
+			defer cancel()
+
+			started := time.Now()
+			heads[i], errs[i] = endpoint.client.BlockNumber(callCtx)
+			p.observe(endpoint, time.Since(started), errs[i])
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	var reported []uint64
+	p.mu.Lock()
+	for i, endpoint := range endpoints {
+		if errs[i] == nil {
+			endpoint.head = heads[i]
+			reported = append(reported, heads[i])
+		}
+	}
+	p.mu.Unlock()
+
+	if len(reported) == 0 {
+		return 0, errors.Join(errs...)
+	}
+
+	sort.Slice(reported, func(i, j int) bool { return reported[i] > reported[j] })
+	quorum := p.headQuorum
+	if quorum > len(reported) {
+		quorum = len(reported)
+	}
+	head := reported[quorum-1]
+
+	p.mu.Lock()
+	p.bestHead = head
+	p.mu.Unlock()
+	metrics.HeadBlock.WithLabelValues(p.chainID).Set(float64(head))
+
+	return head, nil
+}
+
+func (p *clientPool) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *ethclient.Client) (*types.Block, error) {
+		return c.BlockByNumber(ctx, number)
+	})
+}
+
+func (p *clientPool) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *ethclient.Client) (*types.Header, error) {
+		return c.HeaderByNumber(ctx, number)
+	})
+}
+
+func (p *clientPool) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	type result struct {
+		tx      *types.Transaction
+		pending bool
+	}
+	r, err := poolCall(ctx, p, func(ctx context.Context, c *ethclient.Client) (result, error) {
+		tx, pending, err := c.TransactionByHash(ctx, hash)
+		return result{tx, pending}, err
+	})
+	return r.tx, r.pending, err
+}
+
+func (p *clientPool) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *ethclient.Client) (*types.Receipt, error) {
+		return c.TransactionReceipt(ctx, txHash)
+	})
+}
+
+func (p *clientPool) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *ethclient.Client) (*big.Int, error) {
+		return c.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+func (p *clientPool) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *ethclient.Client) ([]byte, error) {
+		return c.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+func (p *clientPool) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return poolCall(ctx, p, func(ctx context.Context, c *ethclient.Client) ([]types.Log, error) { return c.FilterLogs(ctx, q) })
+}
+
+// FailedCalls returns the transactions in [from, to] whose top level call to
+// one of addresses failed, from trace_filter. Most providers only serve it on
+// archive or tracing nodes.
+func (p *clientPool) FailedCalls(ctx context.Context, from, to uint64, addresses []common.Address) ([]common.Hash, error) {
+	type trace struct {
+		Error           string       `json:"error"`
+		TraceAddress    []uint       `json:"traceAddress"`
+		TransactionHash *common.Hash `json:"transactionHash"`
+	}
+	filter := map[string]interface{}{
+		"fromBlock": hexutil.EncodeUint64(from),
+		"toBlock":   hexutil.EncodeUint64(to),
+		"toAddress": addresses,
+	}
+	traces, err := poolCall(ctx, p, func(ctx context.Context, c *ethclient.Client) ([]trace, error) {
+		var traces []trace
+		err := c.Client().CallContext(ctx, &traces, "trace_filter", filter)
+		return traces, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []common.Hash
+	for _, t := range traces {
+		if t.Error != "" && len(t.TraceAddress) == 0 && t.TransactionHash != nil {
+			hashes = append(hashes, *t.TransactionHash)
+		}
+	}
+	return hashes, nil
+}
+
+// Status reports the health of every endpoint in the pool.
+func (p *clientPool) Status() []helpers.EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := make([]helpers.EndpointStatus, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		var lag uint64
+		if p.bestHead > e.head {
+			lag = p.bestHead - e.head
+		}
+		status = append(status, helpers.EndpointStatus{
+			ChainID:   p.chainID,
+			URL:       e.URL,
+			Priority:  e.Priority,
+			Calls:     e.calls,
+			Errors:    e.errors,
+			ErrorRate: e.errorRate,
+			Latency:   e.latency,
+			Head:      e.head,
+			HeadLag:   lag,
+			Healthy:   lag <= maxHeadLag && e.errorRate < 0.5,
+		})
+	}
+	return status
+}
@@ -0,0 +1,233 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+// testNode is a JSON-RPC endpoint serving a head, block headers and traces.
+// A down node answers every request with a server error.
+type testNode struct {
+	mu      sync.Mutex
+	down    bool
+	head    uint64
+	headers map[uint64]*types.Header
+	traces  []map[string]interface{}
+	calls   map[string]int
+}
+
+func newTestNode(t *testing.T, head uint64) (*testNode, string) {
+	t.Helper()
+
+	node := &testNode{head: head, headers: make(map[uint64]*types.Header), calls: make(map[string]int)}
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+	return node, server.URL
+}
+
+func (n *testNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls[req.Method]++
+	if n.down {
+		http.Error(w, "node is down", http.StatusServiceUnavailable)
+		return
+	}
+
+	var result interface{}
+	var rpcErr map[string]interface{}
+	switch req.Method {
+	case "eth_blockNumber":
+		result = hexutil.Uint64(n.head)
+	case "eth_getBlockByNumber":
+		var number hexutil.Uint64
+		json.Unmarshal(req.Params[0], &number)
+		if header, ok := n.headers[uint64(number)]; ok {
+			result = header
+		}
+	case "eth_call":
+		rpcErr = map[string]interface{}{"code": 3, "message": "execution reverted", "data": "0x"}
+	case "trace_filter":
+		result = n.traces
+	default:
+		rpcErr = map[string]interface{}{"code": -32601, "message": "the method " + req.Method + " does not exist/is not available"}
+	}
+
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (n *testNode) callsOf(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+func newTestPool(t *testing.T, quorum int, urls ...string) *clientPool {
+	t.Helper()
+
+	var endpoints []helpers.Endpoint
+	for i, url := range urls {
+		endpoints = append(endpoints, helpers.Endpoint{URL: url, Priority: i})
+	}
+	pool, err := newClientPool(context.Background(), "1", endpoints, quorum)
+	if err != nil {
+		t.Fatalf("newClientPool: %v", err)
+	}
+	return pool
+}
+
+func TestPoolBlockNumber(t *testing.T) {
+	tests := []struct {
+		name   string
+		heads  []uint64
+		down   []bool
+		quorum int
+		want   uint64
+		stale  []bool
+	}{
+		{"highest head", []uint64{100, 98}, []bool{false, false}, 1, 100, []bool{false, false}},
+		{"quorum of two", []uint64{100, 98, 90}, []bool{false, false, false}, 2, 98, []bool{false, false, true}},
+		{"quorum above the answers", []uint64{100, 98}, []bool{false, true}, 2, 100, []bool{false, true}},
+		{"lagging endpoint", []uint64{80, 100}, []bool{false, false}, 1, 100, []bool{true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var urls []string
+			for i, head := range tt.heads {
+				node, url := newTestNode(t, head)
+				node.down = tt.down[i]
+				urls = append(urls, url)
+			}
+			pool := newTestPool(t, tt.quorum, urls...)
+
+			head, err := pool.BlockNumber(context.Background())
+			if err != nil {
+				t.Fatalf("BlockNumber: %v", err)
+			}
+			if head != tt.want {
+				t.Errorf("got head %d, want %d", head, tt.want)
+			}
+			for i, status := range pool.Status() {
+				if stale := status.HeadLag > maxHeadLag; stale != tt.stale[i] {
+					t.Errorf("endpoint %d lags %d blocks, stale %t", i, status.HeadLag, stale)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolBlockNumberAllDown(t *testing.T) {
+	node, url := newTestNode(t, 100)
+	node.down = true
+
+	if _, err := newTestPool(t, 1, url).BlockNumber(context.Background()); err == nil {
+		t.Error("BlockNumber succeeded without any endpoint answering")
+	}
+}
+
+func TestPoolCallFailover(t *testing.T) {
+	tests := []struct {
+		name    string
+		down    []bool
+		method  string
+		call    func(*clientPool) error
+		calls   []int
+		wantErr bool
+	}{
+		{
+			name:   "preferred endpoint",
+			down:   []bool{false, false},
+			method: "eth_blockNumber",
+			call:   func(p *clientPool) error { _, err := poolCall(context.Background(), p, blockNumberCall); return err },
+			calls:  []int{1, 0},
+		},
+		{
+			name:   "fails over",
+			down:   []bool{true, false},
+			method: "eth_blockNumber",
+			call:   func(p *clientPool) error { _, err := poolCall(context.Background(), p, blockNumberCall); return err },
+			calls:  []int{1, 1},
+		},
+		{
+			name:    "every endpoint down",
+			down:    []bool{true, true},
+			method:  "eth_blockNumber",
+			call:    func(p *clientPool) error { _, err := poolCall(context.Background(), p, blockNumberCall); return err },
+			calls:   []int{1, 1},
+			wantErr: true,
+		},
+		{
+			name:   "revert does not fail over",
+			down:   []bool{false, false},
+			method: "eth_call",
+			call: func(p *clientPool) error {
+				_, err := p.CallContract(context.Background(), ethereum.CallMsg{To: &common.Address{}}, nil)
+				return err
+			},
+			calls:   []int{1, 0},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var nodes []*testNode
+			var urls []string
+			for _, down := range tt.down {
+				node, url := newTestNode(t, 100)
+				node.down = down
+				nodes = append(nodes, node)
+				urls = append(urls, url)
+			}
+			pool := newTestPool(t, 1, urls...)
+
+			if err := tt.call(pool); (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			for i, node := range nodes {
+				if calls := node.callsOf(tt.method); calls != tt.calls[i] {
+					t.Errorf("endpoint %d got %d calls, want %d", i, calls, tt.calls[i])
+				}
+			}
+
+			// a failing endpoint is tried after the healthy ones from now on
+			if tt.down[0] && !tt.down[1] && pool.ordered()[0].URL != urls[1] {
+				t.Errorf("failing endpoint still preferred: %+v", pool.Status())
+			}
+		})
+	}
+}
+
+var blockNumberCall = func(ctx context.Context, c *ethclient.Client) (uint64, error) {
+	return c.BlockNumber(ctx)
+}
@@ -0,0 +1,154 @@
+package scraper
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+// number of recent block hashes kept to locate the fork point of a reorg
+const reorgHistorySize = 512
+
+// reorgError reports that a scanned block does not extend the chain the
+// scanner saw so far.
+type reorgError struct {
+	block uint64
+}
+
+func (e *reorgError) Error() string {
+	return fmt.Sprintf("chain reorganisation detected at block %d", e.block)
+}
+
+// blockHistory remembers the canonical hash of recently committed blocks.
+type blockHistory struct {
+	hashes map[uint64]common.Hash
+	lowest uint64
+}
+
+func newBlockHistory() *blockHistory {
+	return &blockHistory{hashes: make(map[uint64]common.Hash)}
+}
+
+func (h *blockHistory) get(number uint64) (common.Hash, bool) {
+	hash, ok := h.hashes[number]
+	return hash, ok
+}
+
+func (h *blockHistory) add(number uint64, hash common.Hash) {
+	if len(h.hashes) == 0 || number < h.lowest {
+		h.lowest = number
+	}
+	h.hashes[number] = hash
+
+	for len(h.hashes) > reorgHistorySize {
+		delete(h.hashes, h.lowest)
+		h.lowest++
+	}
+}
+
+// truncate forgets every block above number.
+func (h *blockHistory) truncate(number uint64) {
+	for n := range h.hashes {
+		if n > number {
+			delete(h.hashes, n)
+		}
+	}
+}
+
+// findForkPoint walks back from block until the remembered hash matches the
+// canonical chain and returns that last common block. When the history runs
+// out the scan is rewound by the full history size.
+func (s *scraperImpl) findForkPoint(block uint64) (uint64, error) {
+	for n := block; ; n-- {
+		known, ok := s.history.get(n)
+		if !ok {
+			break
+		}
+
+		header, err := s.client.HeaderByNumber(s.ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return 0, fmt.Errorf("failed to retrieve header %d: %v", n, err)
+		}
+		if header.Hash() == known {
+			return n, nil
+		}
+
+		if n == 0 {
+			break
+		}
+	}
+
+	if block < reorgHistorySize {
+		return 0, nil
+	}
+	return block - reorgHistorySize, nil
+}
+
+// rollback discards the updates above the fork point and moves the
+// checkpoint back so the canonical blocks get scraped again.
+func (s *scraperImpl) rollback(fork uint64) error {
+	state := helpers.OracleMetricsState{ChainID: s.chainID, LastBlock: fork}
+	if hash, ok := s.history.get(fork); ok {
+		state.LastBlockHash = hash.Hex()
+	}
+
+	if err := s.commit(helpers.MetricsBatch{State: state, Rollback: true}); err != nil {
+		return err
+	}
+
+	s.history.truncate(fork)
+	return nil
+}
+
+// handleReorg rolls back to the last block shared with the canonical chain
+// and returns the block to resume scanning from.
+func (s *scraperImpl) handleReorg(block uint64) (uint64, error) {
+	if block == 0 {
+		return 0, nil
+	}
+
+	fork, err := s.findForkPoint(block - 1)
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.Printf("reorg at block %d chainid %s, rolling back to block %d", block, s.chainID, fork)
+
+	if err := s.rollback(fork); err != nil {
+		return 0, err
+	}
+	return fork + 1, nil
+}
+
+// verifyCheckpoint makes sure the persisted checkpoint is still canonical.
+// A checkpoint orphaned while the scanner was down is rewound by the full
+// history size since the hashes in between are unknown.
+func (s *scraperImpl) verifyCheckpoint(state helpers.OracleMetricsState) (helpers.OracleMetricsState, error) {
+	if state.LastBlock == 0 || state.LastBlockHash == "" {
+		return state, nil
+	}
+
+	header, err := s.client.HeaderByNumber(s.ctx, new(big.Int).SetUint64(state.LastBlock))
+	if err != nil {
+		return state, fmt.Errorf("failed to retrieve header %d: %v", state.LastBlock, err)
+	}
+	if header.Hash() == common.HexToHash(state.LastBlockHash) {
+		s.history.add(state.LastBlock, header.Hash())
+		return state, nil
+	}
+
+	fork, err := s.findForkPoint(state.LastBlock)
+	if err != nil {
+		return state, err
+	}
+
+	s.logger.Printf("checkpoint %d chainid %s is no longer canonical, rolling back to block %d", state.LastBlock, s.chainID, fork)
+
+	if err := s.rollback(fork); err != nil {
+		return state, err
+	}
+	return helpers.OracleMetricsState{ChainID: s.chainID, LastBlock: fork}, nil
+}
@@ -0,0 +1,175 @@
+package scraper
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+func testHeader(number uint64, branch string) *types.Header {
+	return &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Difficulty: big.NewInt(0),
+		Extra:      []byte(branch),
+	}
+}
+
+func TestBlockHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		blocks   []uint64
+		truncate uint64
+		known    []uint64
+		unknown  []uint64
+	}{
+		{"recent blocks", []uint64{1, 2, 3}, 10, []uint64{1, 2, 3}, []uint64{0, 4}},
+		{"truncated", []uint64{1, 2, 3, 4}, 2, []uint64{1, 2}, []uint64{3, 4}},
+		{"bounded", blockRange(1, reorgHistorySize+10), reorgHistorySize + 10, []uint64{11, reorgHistorySize + 10}, []uint64{1, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := newBlockHistory()
+			for _, n := range tt.blocks {
+				history.add(n, common.BigToHash(new(big.Int).SetUint64(n)))
+			}
+			history.truncate(tt.truncate)
+
+			for _, n := range tt.known {
+				if hash, ok := history.get(n); !ok || hash != common.BigToHash(new(big.Int).SetUint64(n)) {
+					t.Errorf("block %d is not remembered", n)
+				}
+			}
+			for _, n := range tt.unknown {
+				if _, ok := history.get(n); ok {
+					t.Errorf("block %d is remembered", n)
+				}
+			}
+		})
+	}
+}
+
+func blockRange(from, to uint64) []uint64 {
+	var blocks []uint64
+	for n := from; n <= to; n++ {
+		blocks = append(blocks, n)
+	}
+	return blocks
+}
+
+// newReorgScraper returns a scraper that remembers blocks [from, 10] of the
+// old branch while the node serves the new branch above fork. Committed
+// batches are acknowledged and sent on the returned channel.
+func newReorgScraper(t *testing.T, from, fork uint64) (*scraperImpl, <-chan helpers.MetricsBatch) {
+	t.Helper()
+
+	node, url := newTestNode(t, 10)
+	s := &scraperImpl{
+		ctx:       context.Background(),
+		chainID:   "1",
+		client:    newTestPool(t, 1, url),
+		history:   newBlockHistory(),
+		batchChan: make(chan helpers.MetricsBatch),
+		logger:    log.New(io.Discard, "", 0),
+	}
+	for n := uint64(0); n <= 10; n++ {
+		old := testHeader(n, "old")
+		if n >= from {
+			s.history.add(n, old.Hash())
+		}
+		node.headers[n] = old
+		if n > fork {
+			node.headers[n] = testHeader(n, "new")
+		}
+	}
+
+	committed := make(chan helpers.MetricsBatch, 1)
+	go func() {
+		for batch := range s.batchChan {
+			batch.Done <- nil
+			committed <- batch
+		}
+	}()
+	t.Cleanup(func() { close(s.batchChan) })
+	return s, committed
+}
+
+func TestHandleReorg(t *testing.T) {
+	tests := []struct {
+		name   string
+		from   uint64
+		fork   uint64
+		resume uint64
+	}{
+		{"last block replaced", 0, 8, 9},
+		{"deep reorg", 0, 3, 4},
+		{"fork before the history", 6, 3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, committed := newReorgScraper(t, tt.from, tt.fork)
+
+			resume, err := s.handleReorg(10)
+			if err != nil {
+				t.Fatalf("handleReorg: %v", err)
+			}
+			if resume != tt.resume {
+				t.Errorf("resumes at block %d, want %d", resume, tt.resume)
+			}
+
+			batch := <-committed
+			if !batch.Rollback || batch.State.LastBlock != resume-1 {
+				t.Errorf("committed %+v, want a rollback to block %d", batch, resume-1)
+			}
+			if _, ok := s.history.get(tt.fork + 1); ok {
+				t.Errorf("orphaned block %d is still remembered", tt.fork+1)
+			}
+		})
+	}
+}
+
+func TestVerifyCheckpoint(t *testing.T) {
+	tests := []struct {
+		name  string
+		state helpers.OracleMetricsState
+		want  uint64
+	}{
+		{
+			name:  "canonical checkpoint",
+			state: helpers.OracleMetricsState{ChainID: "1", LastBlock: 5, LastBlockHash: testHeader(5, "old").Hash().Hex()},
+			want:  5,
+		},
+		{
+			name:  "orphaned checkpoint",
+			state: helpers.OracleMetricsState{ChainID: "1", LastBlock: 9, LastBlockHash: testHeader(9, "old").Hash().Hex()},
+			want:  7,
+		},
+		{
+			name:  "checkpoint without a hash",
+			state: helpers.OracleMetricsState{ChainID: "1", LastBlock: 9},
+			want:  9,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newReorgScraper(t, 0, 7)
+
+			state, err := s.verifyCheckpoint(tt.state)
+			if err != nil {
+				t.Fatalf("verifyCheckpoint: %v", err)
+			}
+			if state.LastBlock != tt.want {
+				t.Errorf("resumes after block %d, want %d", state.LastBlock, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// isRevert tells whether a call failed because the EVM reverted rather than
+// because the endpoint did.
+func isRevert(err error) bool {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "execution reverted")
+}
+
+// revertReason replays a reverted transaction with eth_call on the state of
+// the block before it and returns why it reverted.
+func (s *scraperImpl) revertReason(ctx context.Context, tx *types.Transaction, receipt *types.Receipt, sender common.Address) string {
+	if receipt.GasUsed >= tx.Gas() {
+		return "out of gas"
+	}
+
+	parent := new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	_, err := s.client.CallContract(ctx, ethereum.CallMsg{
+		From:  sender,
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}, parent)
+	if err == nil {
+		return "not reproduced by eth_call"
+	}
+	if !isRevert(err) {
+		s.logger.Printf("failed to replay transaction %s: %v chainid %s", tx.Hash().Hex(), err, s.chainID)
+		return ""
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if encoded, ok := dataErr.ErrorData().(string); ok {
+			data, decodeErr := hexutil.Decode(encoded)
+			if decodeErr == nil {
+				if reason, unpackErr := abi.UnpackRevert(data); unpackErr == nil {
+					return reason
+				}
+				if len(data) > 0 {
+					return fmt.Sprintf("reverted with data %s", encoded)
+				}
+			}
+		}
+	}
+	return err.Error()
+}
@@ -0,0 +1,75 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testRPCError is a JSON-RPC error response.
+type testRPCError struct {
+	code int
+	msg  string
+}
+
+func (e testRPCError) Error() string  { return e.msg }
+func (e testRPCError) ErrorCode() int { return e.code }
+
+var _ rpc.Error = testRPCError{}
+
+func TestIsUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"method not found code", testRPCError{-32601, "unknown"}, true},
+		{"method not available", errors.New("the method trace_filter does not exist/is not available"), true},
+		{"unsupported method", errors.New("Unsupported method: trace_filter"), true},
+		{"range too large", errors.New("query returned more than 10000 results"), false},
+		{"other rpc error", testRPCError{-32000, "header not found"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUnsupported(tt.err); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolFailedCalls(t *testing.T) {
+	node, url := newTestNode(t, 100)
+	reverted := common.HexToHash("0x01")
+	node.traces = []map[string]interface{}{
+		{"error": "Reverted", "traceAddress": []uint{}, "transactionHash": reverted},
+		{"error": "Reverted", "traceAddress": []uint{0}, "transactionHash": common.HexToHash("0x02")},
+		{"traceAddress": []uint{}, "transactionHash": common.HexToHash("0x03")},
+		{"error": "Reverted", "traceAddress": []uint{}},
+	}
+
+	hashes, err := newTestPool(t, 1, url).FailedCalls(context.Background(), 1, 10, []common.Address{{}})
+	if err != nil {
+		t.Fatalf("FailedCalls: %v", err)
+	}
+	if len(hashes) != 1 || hashes[0] != reverted {
+		t.Errorf("got %v, want only %s", hashes, reverted.Hex())
+	}
+}
+
+func TestPoolTracesUnsupported(t *testing.T) {
+	_, url := newTestNode(t, 100)
+	pool := newTestPool(t, 1, url)
+
+	_, err := poolCall(context.Background(), pool, func(ctx context.Context, c *ethclient.Client) (int, error) {
+		return 0, c.Client().CallContext(ctx, nil, "debug_traceBlock")
+	})
+	if err == nil || !isUnsupported(err) {
+		t.Errorf("got %v, want an unsupported method", err)
+	}
+}
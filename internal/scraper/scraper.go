@@ -1,41 +1,44 @@
 package scraper
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"math/big"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 
 	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
 	"github.com/google/uuid"
 )
 
 // Scraper is an interface that represents the scraper functionality.
 type Scraper interface {
-	UpdateHistorical() error
-	UpdateRecent() error
-	UpdateEvents(oracleaddresses []common.Address) error
+	UpdateForward(state helpers.OracleMetricsState) error
+	UpdateEvents(oracles []helpers.Oracle) error
 	UpdateDeployedDate(oracleaddresses []helpers.Oracle) error
+	Backfill(oracle common.Address, from, to uint64) (int, error)
+	Reconnects() uint64
+	EndpointStatus() []helpers.EndpointStatus
 }
 
 type scraperImpl struct {
-	nodes            map[string]*ethclient.Client
-	wsNodes          map[string]*ethclient.Client
-	rpc              map[string]string
-	wsurl            map[string]string
+	chain            helpers.ChainConfig
 	mchan            chan helpers.OracleMetrics
+	batchChan        chan helpers.MetricsBatch
 	createChan       chan helpers.OracleUpdateEvent
+	accessChan       chan helpers.AccessEvent
 	ctx              context.Context
 	minblock         *big.Int
 	maxblock         *big.Int
@@ -44,33 +47,58 @@ type scraperImpl struct {
 	chainID          string
 	oraclesaddresses []common.Address
 	oraclesmap       map[common.Address]helpers.Oracle
-	client           *ethclient.Client
+	oraclesMu        sync.RWMutex
+	client           *clientPool
 	wsClient         *ethclient.Client
-	isHistorical     bool
+	wsIndex          int
+	wsMu             sync.RWMutex
+	eventAddresses   []common.Address
+	eventsMu         sync.Mutex
+	listening        bool
+	resubscribe      chan struct{}
+	lastEventBlock   uint64
+	reconnects       atomic.Uint64
+	confirmations    uint64
+	pendingLogs      map[logID]types.Log
+	history          *blockHistory
+	logRange         uint64
+	noTraces         bool
 	logger           *log.Logger
 }
 
+const (
+	// number of blocks committed together with one checkpoint
+	scanRangeSize = 50
+	// wait between head polls once the scanner caught up
+	scanPollInterval = 15 * time.Second
+	// wait before retrying a range that failed to scan or commit
+	scanRetryInterval = 30 * time.Second
+)
+
 // NewScraper creates a new instance of the Scraper interface.
-func NewScraper(context context.Context, mchan chan helpers.OracleMetrics, createChan chan helpers.OracleUpdateEvent, rpcmap, wsmap map[string]string, minblock *big.Int, maxblock *big.Int, oracles []helpers.Oracle, wg *sync.WaitGroup, chainID string) (Scraper, error) {
+func NewScraper(context context.Context, mchan chan helpers.OracleMetrics, batchChan chan helpers.MetricsBatch, createChan chan helpers.OracleUpdateEvent, accessChan chan helpers.AccessEvent, chain helpers.ChainConfig, minblock *big.Int, maxblock *big.Int, oracles []helpers.Oracle, wg *sync.WaitGroup) (Scraper, error) {
 
 	id := uuid.Must(uuid.NewRandom()).String()
 	logger := log.New(os.Stderr, "", log.LstdFlags)
 	logger.SetPrefix(id)
 
 	s := &scraperImpl{
-		mchan:        mchan,
-		nodes:        make(map[string]*ethclient.Client),
-		wsNodes:      make(map[string]*ethclient.Client),
-		rpc:          rpcmap,
-		wsurl:        wsmap,
-		ctx:          context,
-		minblock:     minblock,
-		maxblock:     maxblock,
-		oracles:      oracles,
-		wg:           wg,
-		createChan:   createChan,
-		chainID:      chainID,
-		isHistorical: false,
+		chain:         chain,
+		mchan:         mchan,
+		batchChan:     batchChan,
+		ctx:           context,
+		minblock:      minblock,
+		maxblock:      maxblock,
+		oracles:       oracles,
+		wg:            wg,
+		createChan:    createChan,
+		accessChan:    accessChan,
+		chainID:       chain.ChainID,
+		resubscribe:   make(chan struct{}, 1),
+		confirmations: chain.Confirmations,
+		pendingLogs:   make(map[logID]types.Log),
+		history:       newBlockHistory(),
+		logRange:      maxLogRange,
 
 		logger: logger,
 	}
@@ -84,15 +112,15 @@ func NewScraper(context context.Context, mchan chan helpers.OracleMetrics, creat
 
 	}
 	var err error
-	s.client, err = s.connectToNode()
+	s.client, err = newClientPool(s.ctx, s.chainID, chain.RPC, chain.HeadQuorum)
 	if err != nil {
-		s.logger.Println("error connecting to rpc chainid ", chainID)
+		s.logger.Println("error connecting to rpc chainid ", s.chainID)
 		return s, err
 	}
 
 	s.wsClient, err = s.connectToWsNode()
 	if err != nil {
-		s.logger.Println("error connecting to ws chainid  ", chainID)
+		s.logger.Println("error connecting to ws chainid  ", s.chainID)
 		return s, err
 
 	}
@@ -100,41 +128,33 @@ func NewScraper(context context.Context, mchan chan helpers.OracleMetrics, creat
 
 }
 
+// connectToWsNode dials the WebSocket endpoints in priority order, starting
+// with the current one, and keeps the first that answers.
 func (s *scraperImpl) connectToWsNode() (*ethclient.Client, error) {
-	// Check if the client for the given URL is already connected
-
-	url := s.wsurl[s.chainID]
-
-	if client, ok := s.wsNodes[url]; ok {
-		return client, nil
+	if len(s.chain.WS) == 0 {
+		return nil, fmt.Errorf("no ws endpoint configured for chain %s", s.chainID)
 	}
 
-	client, err := ethclient.DialContext(s.ctx, url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to the node: %v", err)
-	}
-
-	// Store the connected client for future use
-	s.wsNodes[url] = client
-	return client, nil
-}
+	var lastErr error
+	for i := 0; i < len(s.chain.WS); i++ {
+		index := (s.wsIndex + i) % len(s.chain.WS)
 
-func (s *scraperImpl) connectToNode() (*ethclient.Client, error) {
-	// Check if the client for the given URL is already connected
+		client, err := ethclient.DialContext(s.ctx, s.chain.WS[index].URL)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to connect to the node: %v", err)
+			continue
+		}
 
-	url := s.rpc[s.chainID]
-	if client, ok := s.nodes[url]; ok {
+		s.wsIndex = index
 		return client, nil
 	}
 
-	client, err := ethclient.DialContext(s.ctx, url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to the node: %v", err)
-	}
+	return nil, lastErr
+}
 
-	// Store the connected client for future use
-	s.nodes[url] = client
-	return client, nil
+// EndpointStatus reports the health of the RPC endpoints of the chain.
+func (s *scraperImpl) EndpointStatus() []helpers.EndpointStatus {
+	return s.client.Status()
 }
 
 func (s *scraperImpl) getTransactionSender(tx *types.Transaction) (common.Address, error) {
@@ -162,6 +182,19 @@ func (s *scraperImpl) isContractCreation(tx *types.Transaction, receipt *types.R
 	return receipt.ContractAddress, false
 }
 
+// concernsOracle tells whether tx calls or creates a monitored oracle, the
+// only transactions whose receipt and metadata are fetched.
+func (s *scraperImpl) concernsOracle(tx *types.Transaction) bool {
+	if tx.To() != nil {
+		return contains(s.oraclesaddresses, *tx.To())
+	}
+	sender, err := s.getTransactionSender(tx)
+	if err != nil {
+		return false
+	}
+	return contains(s.oraclesaddresses, crypto.CreateAddress(sender, tx.Nonce()))
+}
+
 func contains(slice []common.Address, item common.Address) bool {
 	for _, s := range slice {
 		if s == item {
@@ -171,23 +204,28 @@ func contains(slice []common.Address, item common.Address) bool {
 	return false
 }
 
-func (s *scraperImpl) isOracleUpdate(tx *types.Transaction, oracle helpers.Oracle) bool {
-	data := tx.Data()
-	setValueSig := oracle.ContractABI.Methods["setValue"].ID
+// oracle returns a monitored oracle by address.
+func (s *scraperImpl) oracle(address common.Address) (helpers.Oracle, bool) {
+	s.oraclesMu.RLock()
+	defer s.oraclesMu.RUnlock()
+	oracle, ok := s.oraclesmap[address]
+	return oracle, ok
+}
 
-	return bytes.Equal(data[:4], setValueSig[:4])
+func (s *scraperImpl) isOracleUpdate(tx *types.Transaction, oracle helpers.Oracle) bool {
+	return oracle.Contract != nil && updateMethodOf(oracle.Contract, tx.Data()) != ""
 }
 
-func (s *scraperImpl) parseTransactionMetadata(ctx context.Context, client *ethclient.Client, block *types.Block, tx *types.Transaction, receipt *types.Receipt) (*helpers.TransactionMetadata, error) {
+func (s *scraperImpl) parseTransactionMetadata(ctx context.Context, client *clientPool, block *types.Block, tx *types.Transaction, receipt *types.Receipt) (*helpers.TransactionMetadata, error) {
 	metadata := &helpers.TransactionMetadata{}
 
 	metadata.ChainID = s.chainID
 	metadata.BlockNumber = block.Number().String()
 	metadata.BlockTimestamp = time.Unix(int64(block.Time()), 0)
 	metadata.TransactionHash = strings.ToLower(tx.Hash().String())
-	metadata.TransactionCost = new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice).String()
-	metadata.GasUsed = strconv.FormatUint(receipt.GasUsed, 10)
-	metadata.GasCost = receipt.EffectiveGasPrice.String()
+	metadata.TransactionCost = new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice)
+	metadata.GasUsed = receipt.GasUsed
+	metadata.GasCost = receipt.EffectiveGasPrice
 
 	sender, err := s.getTransactionSender(tx)
 	if err != nil {
@@ -206,23 +244,79 @@ func (s *scraperImpl) parseTransactionMetadata(ctx context.Context, client *ethc
 		s.logger.Printf("failed to get sender balance: %v", err)
 	}
 
-	metadata.SenderBalance = senderBalance.String()
+	metadata.SenderBalance = senderBalance
 
 	return metadata, nil
 }
 
-func (s *scraperImpl) parseOracleUpdate(tx *types.Transaction, receipt *types.Receipt, oracle helpers.Oracle) (*helpers.OracleUpdate, error) {
-	event := &helpers.OracleUpdate{}
-	err := oracle.ContractABI.UnpackIntoInterface(event, "OracleUpdate", tx.Data()[4:])
+// parseOracleUpdates decodes the keys updated by an update transaction from
+// its calldata and cross-checks them with the update logs of the receipt. The
+// logs are what the oracle stored, they win when the two differ. It also
+// returns the index of the log of every update, the position in the calldata
+// when the transaction emitted none.
+func (s *scraperImpl) parseOracleUpdates(tx *types.Transaction, receipt *types.Receipt, oracle helpers.Oracle) ([]helpers.OracleUpdate, []uint, error) {
+	updates, err := unpackUpdateCall(oracle.Contract, tx.Data())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var logged []helpers.OracleUpdate
+	var indexes []uint
+	topic := updateTopic(oracle.Contract)
+	for _, l := range receipt.Logs {
+		if l.Address != oracle.ContractAddress || len(l.Topics) == 0 || l.Topics[0] != topic {
+			continue
+		}
+		update, err := unpackUpdate(oracle.Contract, l.Data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unpack log %d: %v", l.Index, err)
+		}
+		logged = append(logged, *update)
+		indexes = append(indexes, l.Index)
+	}
+
+	if len(logged) == 0 {
+		s.logger.Printf("update transaction %s emitted no %s logs, using its calldata chainid %s", tx.Hash().Hex(), oracle.Contract.UpdateEvent, s.chainID)
+		for i := range updates {
+			indexes = append(indexes, uint(i))
+		}
+		return updates, indexes, nil
+	}
+	if !sameUpdates(updates, logged) {
+		s.logger.Printf("calldata of update transaction %s differs from its %s logs, using the logs chainid %s", tx.Hash().Hex(), oracle.Contract.UpdateEvent, s.chainID)
+		return logged, indexes, nil
+	}
+	return updates, indexes, nil
+}
 
-	return event, err
+func sameUpdates(a, b []helpers.OracleUpdate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || a[i].Value.Cmp(b[i].Value) != 0 || a[i].Timestamp.Cmp(b[i].Timestamp) != 0 {
+			return false
+		}
+	}
+	return true
 }
 
-func (s *scraperImpl) parseTransaction(ctx context.Context, client *ethclient.Client, block *types.Block, tx *types.Transaction, receipt *types.Receipt) (bool, error) {
+// assetDecimals returns the decimals of the values published by an oracle.
+func (s *scraperImpl) assetDecimals(address common.Address) int {
+	if oracle, ok := s.oracle(address); ok && oracle.Decimals > 0 {
+		return oracle.Decimals
+	}
+	return helpers.DefaultAssetDecimals
+}
+
+// parseTransaction returns the oracle updates carried by tx, one per updated
+// key, or the failed update when tx is an update that reverted. Oracle
+// creations are reported on createChan.
+func (s *scraperImpl) parseTransaction(ctx context.Context, client *clientPool, block *types.Block, tx *types.Transaction, receipt *types.Receipt) ([]helpers.OracleMetrics, *helpers.FailedUpdate, bool, error) {
 	done := false
 	metadata, err := s.parseTransactionMetadata(ctx, client, block, tx, receipt)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse transaction metadata: %v", err)
+		return nil, nil, false, fmt.Errorf("failed to parse transaction metadata: %v", err)
 	}
 
 	contract, iscreated := s.isContractCreation(tx, receipt, s.oraclesaddresses)
@@ -238,7 +332,10 @@ func (s *scraperImpl) parseTransaction(ctx context.Context, client *ethclient.Cl
 		ue.ChainID = s.chainID
 		ue.BlockTimestamp = metadata.BlockTimestamp
 
-		s.createChan <- ue
+		select {
+		case s.createChan <- ue:
+		case <-s.ctx.Done():
+		}
 
 		// err := s.db.UpdateOracleCreation(oracle.ContractAddress.String(), block.Number().Uint64())
 		// if err != nil {
@@ -251,279 +348,345 @@ func (s *scraperImpl) parseTransaction(ctx context.Context, client *ethclient.Cl
 
 	contract, istarget := s.isTargetingContract(tx, s.oraclesaddresses)
 
+	if istarget && receipt.Status == types.ReceiptStatusFailed {
+		oracle, _ := s.oracle(contract)
+		return nil, s.parseFailedTransaction(ctx, tx, receipt, oracle, *metadata), done, nil
+	}
+
 	if istarget {
-		if s.isOracleUpdate(tx, s.oraclesmap[contract]) {
+		oracle, _ := s.oracle(contract)
+		if s.isOracleUpdate(tx, oracle) {
 
-			oracleUpdate, err := s.parseOracleUpdate(tx, receipt, s.oraclesmap[contract])
+			updates, indexes, err := s.parseOracleUpdates(tx, receipt, oracle)
 			if err != nil {
-				return false, fmt.Errorf("failed to parse oracle update: %v", err)
+				return nil, nil, false, fmt.Errorf("failed to parse oracle update: %v", err)
 			}
 
-			metrics := &helpers.OracleMetrics{
-				TransactionMetadata: *metadata,
-				AssetKey:            oracleUpdate.Key,
-				AssetPrice:          oracleUpdate.Value.String(),
-				UpdateTimestamp:     oracleUpdate.Timestamp.String(),
+			metrics := make([]helpers.OracleMetrics, 0, len(updates))
+			for i, update := range updates {
+				metrics = append(metrics, helpers.OracleMetrics{
+					TransactionMetadata: shareCost(*metadata, i, len(updates)),
+					AssetKey:            update.Key,
+					AssetPrice:          update.Value,
+					AssetDecimals:       s.assetDecimals(contract),
+					UpdateTimestamp:     update.Timestamp.String(),
+					LogIndex:            indexes[i],
+				})
 			}
 
-			// err = s.db.InsertOracleMetrics(metrics)
-			// if err != nil {
-			// 	return false, fmt.Errorf("failed to insert oracle metrics: %v", err)
-			// }
-
 			// reached the latest block scraped on the previous run
 			// done = block.Number().Cmp(oracle.LatestScrapedBlock) <= 0 // -1 or 0 if block.Number is smaller
-			s.mchan <- *metrics
+			return metrics, nil, done, nil
 
 		}
 	}
-	return done, nil
+	return nil, nil, done, nil
 }
 
-func (s *scraperImpl) listenEvents(addresses []common.Address) {
-
-	ctx := context.Background()
-
-	updateeventchan := make(chan types.Log)
+// parseFailedTransaction describes a reverted transaction to an oracle,
+// replaying it for the revert reason.
+func (s *scraperImpl) parseFailedTransaction(ctx context.Context, tx *types.Transaction, receipt *types.Receipt, oracle helpers.Oracle, metadata helpers.TransactionMetadata) *helpers.FailedUpdate {
+	failed := &helpers.FailedUpdate{TransactionMetadata: metadata}
 
-	if len(s.oraclesaddresses) <= 0 {
-		return
+	data := tx.Data()
+	if len(data) >= 4 {
+		failed.Method = hexutil.Encode(data[:4])
+		if oracle.Contract != nil {
+			if method, err := oracle.Contract.ABI.MethodById(data[:4]); err == nil {
+				failed.Method = method.Name
+			}
+		}
 	}
-
-	topic := s.oraclesmap[s.oraclesaddresses[0]].ContractABI.Events["OracleUpdate"].ID
-
-	latestBlock, err := s.wsClient.BlockNumber(ctx)
-	if err != nil {
-		s.logger.Printf("Failed to get latest BlockNumber : %v chainid %s ", err, s.chainID)
-		return
+	if oracle.Contract != nil && updateMethodOf(oracle.Contract, data) != "" {
+		// the calldata of a reverted call may be malformed too
+		if updates, err := unpackUpdateCall(oracle.Contract, data); err == nil {
+			for _, update := range updates {
+				failed.AssetKeys = append(failed.AssetKeys, update.Key)
+			}
+		}
 	}
 
-	latestBlock = latestBlock - 500
+	failed.RevertReason = s.revertReason(ctx, tx, receipt, metadata.TransactionFrom)
+	s.logger.Printf("transaction %s to oracle %s reverted: %s chainid %s", tx.Hash().Hex(), metadata.TransactionTo.Hex(), failed.RevertReason, s.chainID)
+	return failed
+}
 
-	subscription, err := s.wsClient.SubscribeFilterLogs(ctx, ethereum.FilterQuery{
-		Addresses: addresses,
-		FromBlock: big.NewInt(int64(latestBlock)),
-		Topics:    [][]common.Hash{{topic}},
-	}, updateeventchan)
-	if err != nil {
-		s.logger.Printf("Failed to subscribe to event logs: %v chainid %s ", err, s.chainID)
-		return
+// shareCost returns the metadata of the i-th of n keys updated by one
+// transaction, charged with its share of the gas. The first key also takes
+// the remainder so the shares add up to the transaction.
+func shareCost(metadata helpers.TransactionMetadata, i, n int) helpers.TransactionMetadata {
+	if n <= 1 {
+		return metadata
 	}
 
-	defer subscription.Unsubscribe()
-
-	for {
-		select {
-		case err := <-subscription.Err():
-			s.logger.Printf("subscription error: %v chainID %s", err, s.chainID)
-		case eventLog := <-updateeventchan:
-
-			eventData := make(map[string]interface{})
-
-			err := s.oraclesmap[eventLog.Address].ContractABI.UnpackIntoMap(eventData, "OracleUpdate", eventLog.Data)
-			if err != nil {
-				s.logger.Printf("failed to unpack log data: %v", err)
-			}
-
-			receipt, err := s.client.TransactionReceipt(s.ctx, eventLog.TxHash)
-			if err != nil {
-				fmt.Printf("failed to get transaction receipt: %v", err)
-				continue
-			}
-			metadata := helpers.TransactionMetadata{}
-
-			block, err := s.client.BlockByNumber(s.ctx, new(big.Int).SetUint64(eventLog.BlockNumber))
-			if err != nil {
-				s.logger.Printf("failed to retrieve a block: %v, %v, chainid %s ", eventLog.BlockNumber, err, s.chainID)
-			} else {
-				metadata.BlockTimestamp = time.Unix(int64(block.Time()), 0)
-
-			}
-
-			fmt.Printf("chainid: %v", s.chainID)
-
-			// fmt.Printf("eventLog: %v", eventLog)
-
-			metadata.BlockNumber = strconv.Itoa(int(eventLog.BlockNumber))
-			// metadata.BlockTimestamp = eventLog.
-			metadata.TransactionHash = eventLog.TxHash.Hex()
-
-			fmt.Printf("receipt: %v", receipt)
-
-			fmt.Printf("receipt.GasUsed: %v", receipt.GasUsed)
-			fmt.Printf("receipt.EffectiveGasPrice: %v", receipt.EffectiveGasPrice)
-			fmt.Printf("chainid: %v", s.chainID)
-
-			metadata.TransactionCost = new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice).String()
-			metadata.GasUsed = strconv.FormatUint(receipt.GasUsed, 10)
-			metadata.GasCost = receipt.EffectiveGasPrice.String()
-			metadata.ChainID = s.chainID
-
-			tx, _, err := s.client.TransactionByHash(context.Background(), eventLog.TxHash)
-			if err != nil {
-				fmt.Printf("failed to get sender: %v", err)
-			}
-
-			sender, err := s.getTransactionSender(tx)
-			if err != nil {
-				fmt.Printf("failed to get sender: %v", err)
-			}
-
-			metadata.TransactionFrom = sender
-
-			if tx.To() != nil {
-				metadata.TransactionTo = *tx.To()
-			}
-
-			// use lates balance instead of block number as that need archieve node
-			senderBalance, err := s.client.BalanceAt(ctx, sender, nil)
-			if err != nil {
-				s.logger.Printf("failed to get sender balance: %v", err)
-			}
-
-			metadata.SenderBalance = senderBalance.String()
-
-			metadata.TransactionTo = eventLog.Address
-
-			metrics := &helpers.OracleMetrics{
-				TransactionMetadata: metadata,
-				AssetKey:            eventData["key"].(string),
-				AssetPrice:          eventData["value"].(*big.Int).String(),
-				UpdateTimestamp:     eventData["timestamp"].(*big.Int).String(),
-			}
-
-			s.mchan <- *metrics
+	gasUsed := metadata.GasUsed / uint64(n)
+	if i == 0 {
+		gasUsed += metadata.GasUsed % uint64(n)
+	}
+	metadata.GasUsed = gasUsed
 
+	if metadata.TransactionCost != nil {
+		cost, remainder := new(big.Int).QuoRem(metadata.TransactionCost, big.NewInt(int64(n)), new(big.Int))
+		if i == 0 {
+			cost.Add(cost, remainder)
 		}
+		metadata.TransactionCost = cost
 	}
+	return metadata
 }
 
-func (s *scraperImpl) parseBlock(block *types.Block) (bool, error) {
+// parseBlock adds the oracle updates, failed updates and access control
+// events found in block to batch.
+func (s *scraperImpl) parseBlock(block *types.Block, batch *helpers.MetricsBatch) (bool, error) {
 	done := false
 
 	s.logger.Printf(" parsing block  %s, for chain  %s", block.Number(), s.chainID)
 
 	for _, tx := range block.Transactions() {
+		if !s.concernsOracle(tx) {
+			continue
+		}
 
 		receipt, err := s.client.TransactionReceipt(s.ctx, tx.Hash())
 		if err != nil {
 			return false, fmt.Errorf("failed to get transaction receipt: %v", err)
 		}
 
-		creation, err := s.parseTransaction(s.ctx, s.client, block, tx, receipt)
+		m, f, creation, err := s.parseTransaction(s.ctx, s.client, block, tx, receipt)
 		if err != nil {
 			return false, fmt.Errorf("failed to scrape transaction: %v", err)
 		}
+		batch.Metrics = append(batch.Metrics, m...)
+		if f != nil {
+			batch.Failed = append(batch.Failed, *f)
+		}
 
 		done = done || creation
 	}
 
-	s.logger.Printf("parsed block  %s, for chain  %s isHistorical %t", block.Number().String(), s.chainID, s.isHistorical)
+	access, err := s.parseBlockAccess(block)
+	if err != nil {
+		return false, err
+	}
+	batch.Access = append(batch.Access, access...)
+
+	s.logger.Printf("parsed block  %s, for chain  %s", block.Number().String(), s.chainID)
 
 	return done, nil
 }
 
-func (s *scraperImpl) recent(current uint64) (err error) {
+// startBlock resolves the first block to scan. It resumes after the persisted
+// checkpoint, falls back to the lowest block already stored for the oracles,
+// then to the earliest known oracle creation block and otherwise starts at
+// the current head.
+func (s *scraperImpl) startBlock(state helpers.OracleMetricsState) (uint64, error) {
+	if state.LastBlock > 0 {
+		return state.LastBlock + 1, nil
+	}
+	if s.minblock != nil && s.minblock.Sign() > 0 {
+		return s.minblock.Uint64(), nil
+	}
 
-	if current == 0 {
-		current, err = s.client.BlockNumber(s.ctx)
-		if err != nil {
-			return fmt.Errorf("failed to retrieve the latest block: %v", err)
+	var creation uint64
+	for _, oracle := range s.oracles {
+		if oracle.CreationBlock > 0 && (creation == 0 || oracle.CreationBlock < creation) {
+			creation = oracle.CreationBlock
 		}
+	}
+	if creation > 0 {
+		return creation, nil
+	}
 
+	head, err := s.client.BlockNumber(s.ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve the latest block: %v", err)
 	}
+	return head, nil
+}
+
+// scanRange parses every block in [from, to] and returns the resulting batch
+// with a checkpoint at to, along with the hashes of the scanned blocks. Any
+// failure aborts the whole range so that it is retried from the start. A block
+// whose parent differs from the remembered hash yields a reorgError.
+func (s *scraperImpl) scanRange(from, to uint64) (helpers.MetricsBatch, []common.Hash, error) {
+	batch := helpers.MetricsBatch{
+		State: helpers.OracleMetricsState{ChainID: s.chainID, LastBlock: to},
+	}
+	hashes := make([]common.Hash, 0, to-from+1)
 
-	for current > s.maxblock.Uint64() {
+	for current := from; current <= to; current++ {
 		block, err := s.client.BlockByNumber(s.ctx, new(big.Int).SetUint64(current))
 		if err != nil {
-			s.logger.Printf("failed to retrieve a block: %v, %v, chainid %s ", current, err, s.chainID)
-			continue
+			return batch, nil, fmt.Errorf("failed to retrieve block %d: %v", current, err)
 		}
 
-		_, err = s.parseBlock(block)
-		if err != nil {
-			s.logger.Printf("failed to scrape block: %v", err)
+		parent, known := s.history.get(current - 1)
+		if current > from {
+			parent, known = hashes[len(hashes)-1], true
 		}
+		if known && block.ParentHash() != parent {
+			return batch, nil, &reorgError{block: current}
+		}
+
+		if _, err := s.parseBlock(block, &batch); err != nil {
+			return batch, nil, fmt.Errorf("failed to scrape block %d: %v", current, err)
+		}
+		hashes = append(hashes, block.Hash())
+	}
+
+	batch.State.LastBlockHash = hashes[len(hashes)-1].Hex()
+	return batch, hashes, nil
+}
 
-		current = current - 1
+// commit hands a batch to the writer and waits until its metrics and
+// checkpoint are stored.
+func (s *scraperImpl) commit(batch helpers.MetricsBatch) error {
+	batch.Done = make(chan error, 1)
 
+	select {
+	case s.batchChan <- batch:
+	case <-s.ctx.Done():
+		return s.ctx.Err()
 	}
-	s.wg.Done()
-	s.logger.Printf("done  oracles  ")
 
-	return nil
+	select {
+	case err := <-batch.Done:
+		return err
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
 }
 
-func (s *scraperImpl) historical() error {
+// sleep waits for d and reports false if the scraper was cancelled meanwhile.
+func (s *scraperImpl) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-	current, err := s.client.BlockNumber(s.ctx)
-	if err != nil {
-		return fmt.Errorf("failed to retrieve the latest block: %v", err)
+	select {
+	case <-timer.C:
+		return true
+	case <-s.ctx.Done():
+		return false
 	}
-	// switch s.chainID {
-	// case "80001":
-	// 	current = 34889087
+}
 
-	// case "5":
-	// 	current = 8263129
+// forward scans from the checkpoint towards the head in ranges of at most
+// scanRangeSize blocks, staying confirmations blocks behind the head. The
+// checkpoint only moves once a range is committed, a failed range is retried
+// so no block is skipped.
+func (s *scraperImpl) forward(state helpers.OracleMetricsState) error {
+	defer s.wg.Done()
 
-	// }
-	// change it to minimum
+	var next uint64
+	for {
+		verified, err := s.verifyCheckpoint(state)
+		if err == nil {
+			next, err = s.startBlock(verified)
+		}
+		if err == nil {
+			break
+		}
 
-	for current > 0 && current > s.minblock.Uint64() {
-		block, err := s.client.BlockByNumber(s.ctx, new(big.Int).SetUint64(current))
+		s.logger.Printf("failed to resolve start block: %v chainid %s", err, s.chainID)
+		if !s.sleep(scanRetryInterval) {
+			return s.ctx.Err()
+		}
+	}
+
+	s.logger.Printf("forward scan for chain %s starting at block %d, confirmations %d", s.chainID, next, s.confirmations)
+
+	for {
+		head, err := s.client.BlockNumber(s.ctx)
 		if err != nil {
-			s.logger.Printf("failed to retrieve a block: %v, %v, chainid %s ", current, err, s.chainID)
+			s.logger.Printf("failed to retrieve the latest block: %v chainid %s", err, s.chainID)
+			if !s.sleep(scanRetryInterval) {
+				return s.ctx.Err()
+			}
 			continue
 		}
 
-		_, err = s.parseBlock(block)
-		if err != nil {
-			s.logger.Printf("failed to scrape block: %v", err)
+		if head < s.confirmations || next > head-s.confirmations {
+			if !s.sleep(scanPollInterval) {
+				return s.ctx.Err()
+			}
+			continue
 		}
+		head -= s.confirmations
 
-		current = current - 1
-		s.logger.Printf("decrease current %d  ", current)
+		if head-next+1 > backfillThreshold {
+			next, err = s.backfill(next, head)
+			if err != nil {
+				s.logger.Printf("%v chainid %s", err, s.chainID)
+				if !s.sleep(scanRetryInterval) {
+					return s.ctx.Err()
+				}
+			}
+			continue
+		}
 
-	}
+		to := next + scanRangeSize - 1
+		if to > head {
+			to = head
+		}
 
-	s.wg.Done()
+		batch, hashes, err := s.scanRange(next, to)
+		if reorg, ok := err.(*reorgError); ok {
+			resume, err := s.handleReorg(reorg.block)
+			if err != nil {
+				s.logger.Printf("failed to roll back reorg at block %d: %v chainid %s", reorg.block, err, s.chainID)
+				if !s.sleep(scanRetryInterval) {
+					return s.ctx.Err()
+				}
+				continue
+			}
+			next = resume
+			continue
+		}
+		if err == nil {
+			err = s.commit(batch)
+		}
+		if err != nil {
+			s.logger.Printf("failed to scan blocks %d-%d: %v chainid %s", next, to, err, s.chainID)
+			if !s.sleep(scanRetryInterval) {
+				return s.ctx.Err()
+			}
+			continue
+		}
 
-	s.logger.Printf(" done  oracles  ")
+		for i, hash := range hashes {
+			s.history.add(next+uint64(i), hash)
+		}
 
-	return nil
+		s.logger.Printf("committed blocks %d-%d with %d updates for chain %s", next, to, len(batch.Metrics), s.chainID)
+		s.reportProgress(to, head)
+		next = to + 1
+	}
 }
 
-func (s *scraperImpl) UpdateHistorical() error {
-	s.logger.Printf("Scrapping started for chain %s, up to minimum block %s, maximum block %s and total oracles %d UpdateHistorical ", s.chainID, s.minblock, s.maxblock, len(s.oracles))
-	s.isHistorical = true
-	s.wg.Add(1)
-	go s.historical()
-	return nil
+// reportProgress exports the last committed block and its distance to the
+// chain head. head is the confirmed head the scanner is allowed to reach.
+func (s *scraperImpl) reportProgress(block, head uint64) {
+	metrics.ScrapedBlock.WithLabelValues(s.chainID).Set(float64(block))
+	metrics.ScrapeLag.WithLabelValues(s.chainID).Set(float64(head + s.confirmations - block))
 }
 
-func (s *scraperImpl) UpdateRecent() error {
-	s.logger.Printf("Scrapping started for chain %s, up to minimum block %s, maximum block %s and total oracles %d UpdateRecent ", s.chainID, s.minblock, s.maxblock, len(s.oracles))
+// UpdateForward starts scanning forward from the given checkpoint. It keeps
+// following the head until the scraper context is cancelled.
+func (s *scraperImpl) UpdateForward(state helpers.OracleMetricsState) error {
+	s.logger.Printf("Scrapping started for chain %s, checkpoint %d, minimum block %s and total oracles %d UpdateForward ", s.chainID, state.LastBlock, s.minblock, len(s.oracles))
 	s.wg.Add(1)
-
-	go s.recent(0)
-
-	return nil
-}
-
-func (s *scraperImpl) UpdateEvents(oracleaddresses []common.Address) error {
-	s.logger.Printf("UpdateEvents started for chain %s, up to minimum block %s, maximum block %s and total oracles %d ", s.chainID, s.minblock, s.maxblock, len(s.oracles))
-
-	go s.listenEvents(oracleaddresses)
-
+	go s.forward(state)
 	return nil
 }
 
 func (s *scraperImpl) UpdateDeployedDate(oracleaddresses []helpers.Oracle) error {
-	fmt.Println("UpdateDeployedDate total ", len(oracleaddresses))
+	s.logger.Printf("UpdateDeployedDate total %d chainid %s", len(oracleaddresses), s.chainID)
 
 	for _, oracle := range oracleaddresses {
-		data, _ := oracle.ContractABI.Pack("deployedBlockNumber")
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+		data, _ := oracle.Contract.ABI.Pack("deployedBlockNumber")
 
 		// Create a call message
 		msg := ethereum.CallMsg{
@@ -531,7 +694,7 @@ func (s *scraperImpl) UpdateDeployedDate(oracleaddresses []helpers.Oracle) error
 			Data: data,
 		}
 
-		result, err := s.wsClient.CallContract(context.Background(), msg, nil)
+		result, err := s.client.CallContract(s.ctx, msg, nil)
 		if err != nil {
 			s.logger.Printf("error calling contract %s err %s", &oracle.ContractAddress, err)
 
@@ -554,7 +717,11 @@ func (s *scraperImpl) UpdateDeployedDate(oracleaddresses []helpers.Oracle) error
 
 		ue.ChainID = s.chainID
 
-		s.createChan <- ue
+		select {
+		case s.createChan <- ue:
+		case <-s.ctx.Done():
+			return nil
+		}
 
 	}
 
@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+func TestShareCost(t *testing.T) {
+	tests := []struct {
+		name  string
+		gas   uint64
+		cost  int64
+		n     int
+		gases []uint64
+		costs []int64
+	}{
+		{"single key", 100, 1000, 1, []uint64{100}, []int64{1000}},
+		{"even split", 100, 1000, 4, []uint64{25, 25, 25, 25}, []int64{250, 250, 250, 250}},
+		{"remainder on the first key", 101, 1001, 3, []uint64{35, 33, 33}, []int64{335, 333, 333}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := helpers.TransactionMetadata{GasUsed: tt.gas, TransactionCost: big.NewInt(tt.cost)}
+
+			var gas uint64
+			cost := new(big.Int)
+			for i := 0; i < tt.n; i++ {
+				share := shareCost(metadata, i, tt.n)
+				if share.GasUsed != tt.gases[i] || share.TransactionCost.Int64() != tt.costs[i] {
+					t.Errorf("key %d is charged %d gas and %s wei, want %d and %d", i, share.GasUsed, share.TransactionCost, tt.gases[i], tt.costs[i])
+				}
+				gas += share.GasUsed
+				cost.Add(cost, share.TransactionCost)
+			}
+			if gas != tt.gas || cost.Int64() != tt.cost {
+				t.Errorf("shares add up to %d gas and %s wei, want %d and %d", gas, cost, tt.gas, tt.cost)
+			}
+			if metadata.TransactionCost.Int64() != tt.cost {
+				t.Errorf("the transaction cost was modified")
+			}
+		})
+	}
+}
+
+func TestSameUpdates(t *testing.T) {
+	update := func(key string, value int64) helpers.OracleUpdate {
+		return helpers.OracleUpdate{Key: key, Value: big.NewInt(value), Timestamp: big.NewInt(1)}
+	}
+
+	tests := []struct {
+		name string
+		a, b []helpers.OracleUpdate
+		want bool
+	}{
+		{"equal", []helpers.OracleUpdate{update("BTC/USD", 1)}, []helpers.OracleUpdate{update("BTC/USD", 1)}, true},
+		{"both empty", nil, nil, true},
+		{"other value", []helpers.OracleUpdate{update("BTC/USD", 1)}, []helpers.OracleUpdate{update("BTC/USD", 2)}, false},
+		{"other key", []helpers.OracleUpdate{update("BTC/USD", 1)}, []helpers.OracleUpdate{update("ETH/USD", 1)}, false},
+		{"missing update", []helpers.OracleUpdate{update("BTC/USD", 1), update("ETH/USD", 1)}, []helpers.OracleUpdate{update("BTC/USD", 1)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameUpdates(tt.a, tt.b); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
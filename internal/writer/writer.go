@@ -0,0 +1,191 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
+)
+
+const (
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = 2 * time.Second
+
+	// attempts of a write before it is given up until the next flush
+	maxAttempts = 5
+	// bounds of the exponential backoff between attempts
+	minRetryDelay = 500 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+// Store is where the writer puts the updates.
+type Store interface {
+	InsertOracleMetricsBatch(metrics []helpers.OracleMetrics) error
+	DeleteOracleMetrics(chainID string, transactionHash string) error
+}
+
+// Writer collects updates from a channel and stores them in batches of at
+// most batchSize rows, flushed at least every flushInterval. The channel is
+// only read while the writer is not busy storing, so a slow database makes
+// the senders wait rather than piling updates up in memory.
+type Writer struct {
+	store         Store
+	batchSize     int
+	flushInterval time.Duration
+	stored        func(helpers.OracleMetrics)
+}
+
+// New creates a writer, stored is called for every update once it is stored
+// and may be nil.
+func New(store Store, batchSize int, flushInterval time.Duration, stored func(helpers.OracleMetrics)) *Writer {
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	return &Writer{store: store, batchSize: batchSize, flushInterval: flushInterval, stored: stored}
+}
+
+// Run stores the updates received on in until it is closed. Removed updates
+// are deleted in order, after the inserts received before them. Updates that
+// could not be stored are kept and written again on the next flush, while the
+// writer holds a full batch of them it stops reading in so the senders wait.
+// Once ctx is cancelled writes are no longer retried.
+func (w *Writer) Run(ctx context.Context, in <-chan helpers.OracleMetrics) {
+	pending := make([]helpers.OracleMetrics, 0, w.batchSize)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		input := in
+		if len(pending) >= w.batchSize {
+			input = nil
+		}
+
+		select {
+		case update, ok := <-input:
+			if !ok {
+				if pending = w.flush(ctx, pending); len(pending) > 0 {
+					metrics.DBErrors.WithLabelValues("insert").Inc()
+					log.Printf("dropping %d oracle metrics that could not be stored before shutdown", len(pending))
+				}
+				return
+			}
+			pending = append(pending, update)
+			if update.Removed || len(pending) >= w.batchSize {
+				pending = w.flush(ctx, pending)
+			}
+		case <-ticker.C:
+			pending = w.flush(ctx, pending)
+		}
+	}
+}
+
+// flush stores the pending updates in order and returns the ones it could
+// not store, starting with the write that failed.
+func (w *Writer) flush(ctx context.Context, pending []helpers.OracleMetrics) []helpers.OracleMetrics {
+	for len(pending) > 0 {
+		if pending[0].Removed {
+			if err := w.delete(ctx, pending[0]); err != nil {
+				return pending
+			}
+			pending = pending[1:]
+			continue
+		}
+
+		n := 0
+		for n < len(pending) && !pending[n].Removed {
+			n++
+		}
+		if err := w.insert(ctx, pending[:n]); err != nil {
+			return pending
+		}
+		pending = pending[n:]
+	}
+	return nil
+}
+
+func (w *Writer) insert(ctx context.Context, batch []helpers.OracleMetrics) error {
+	err := Retry(ctx, func() error { return w.store.InsertOracleMetricsBatch(batch) })
+	if err != nil {
+		metrics.DBErrors.WithLabelValues("insert").Inc()
+		log.Printf("failed to store %d oracle metrics, keeping them for the next flush: %v", len(batch), err)
+		return err
+	}
+
+	if w.stored != nil {
+		for _, update := range batch {
+			w.stored(update)
+		}
+	}
+	return nil
+}
+
+func (w *Writer) delete(ctx context.Context, update helpers.OracleMetrics) error {
+	err := Retry(ctx, func() error { return w.store.DeleteOracleMetrics(update.ChainID, update.TransactionHash) })
+	if err != nil {
+		metrics.DBErrors.WithLabelValues("delete").Inc()
+		log.Printf("failed to delete removed oracle metrics %s, keeping it for the next flush: %v", update.TransactionHash, err)
+	}
+	return err
+}
+
+// Retry runs write until it succeeds, fails with a permanent error or ran
+// maxAttempts times, backing off exponentially between attempts. Once ctx is
+// cancelled it stops retrying and returns the last error.
+func Retry(ctx context.Context, write func() error) error {
+	delay := minRetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = write()
+		if err == nil || ctx.Err() != nil || attempt == maxAttempts || !transient(err) {
+			return err
+		}
+
+		log.Printf("write failed (attempt %d), retrying in %s: %v", attempt, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// transient reports whether a write may succeed when retried: lost
+// connections, server shutdowns, serialization failures and deadlocks.
+// Cancelled or expired contexts are not, although they pass as net errors.
+func transient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code[:2] == "08", pgErr.Code[:2] == "57":
+			return true
+		case pgErr.Code == "40001", pgErr.Code == "40P01":
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return pgconn.SafeToRetry(err) || pgconn.Timeout(err)
+}
@@ -0,0 +1,198 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/diadata-org/oracle-monitoring/internal/database"
+	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+)
+
+var testOracle = common.HexToAddress("0x1000000000000000000000000000000000000001")
+
+func testUpdate(tx string, block uint64, removed bool) helpers.OracleMetrics {
+	return helpers.OracleMetrics{
+		TransactionMetadata: helpers.TransactionMetadata{
+			BlockNumber:     strconv.FormatUint(block, 10),
+			ChainID:         "1",
+			BlockTimestamp:  time.Unix(int64(block), 0),
+			TransactionTo:   testOracle,
+			TransactionHash: tx,
+		},
+		AssetKey:   "BTC/USD",
+		AssetPrice: big.NewInt(int64(block)),
+		Removed:    removed,
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"wrapped connection failure", errors.Join(errors.New("insert"), &pgconn.PgError{Code: "08003"}), true},
+		{"plain error", errors.New("invalid input"), false},
+		{"expired context", fmt.Errorf("failed to insert: %w", context.DeadlineExceeded), false},
+		{"cancelled context", fmt.Errorf("failed to insert: %w", context.Canceled), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transient(tt.err); got != tt.want {
+				t.Errorf("transient(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errPermanent := errors.New("invalid input")
+	errTransient := &pgconn.PgError{Code: "40001"}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		cancelled bool
+		attempts  int
+		wantErr   error
+	}{
+		{"success", []error{nil}, false, 1, nil},
+		{"permanent failure", []error{errPermanent, nil}, false, 1, errPermanent},
+		{"transient failure", []error{errTransient, nil}, false, 2, nil},
+		{"cancelled", []error{errTransient, nil}, true, 1, errTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			attempts := 0
+			err := Retry(ctx, func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			if err != tt.wantErr {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("ran %d attempts, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+// failingStore fails the first failures inserts and passes the rest on.
+type failingStore struct {
+	Store
+	failures int
+}
+
+func (s *failingStore) InsertOracleMetricsBatch(metrics []helpers.OracleMetrics) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("invalid input")
+	}
+	return s.Store.InsertOracleMetricsBatch(metrics)
+}
+
+func TestWriterRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		updates  []helpers.OracleMetrics
+		batch    int
+		failures int
+		want     []string
+	}{
+		{
+			name:    "inserts",
+			updates: []helpers.OracleMetrics{testUpdate("0xa", 1, false), testUpdate("0xb", 2, false), testUpdate("0xc", 3, false)},
+			batch:   2,
+			want:    []string{"0xc", "0xb", "0xa"},
+		},
+		{
+			name:    "removal after its insert",
+			updates: []helpers.OracleMetrics{testUpdate("0xa", 1, false), testUpdate("0xb", 2, false), testUpdate("0xb", 2, true)},
+			batch:   10,
+			want:    []string{"0xa"},
+		},
+		{
+			name:    "insert after a removal",
+			updates: []helpers.OracleMetrics{testUpdate("0xb", 2, false), testUpdate("0xb", 2, true), testUpdate("0xc", 2, false)},
+			batch:   10,
+			want:    []string{"0xc"},
+		},
+		{
+			name:     "failed batch kept for the next flush",
+			updates:  []helpers.OracleMetrics{testUpdate("0xa", 1, false), testUpdate("0xb", 2, false)},
+			batch:    1,
+			failures: 1,
+			want:     []string{"0xb", "0xa"},
+		},
+		{
+			name:     "failing until shutdown",
+			updates:  []helpers.OracleMetrics{testUpdate("0xa", 1, false)},
+			batch:    10,
+			failures: 100,
+			want:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := database.NewMemoryDB("", false, 0)
+			var stored []string
+			w := New(&failingStore{Store: db, failures: tt.failures}, tt.batch, time.Millisecond, func(update helpers.OracleMetrics) {
+				stored = append(stored, update.TransactionHash)
+			})
+
+			in := make(chan helpers.OracleMetrics)
+			done := make(chan struct{})
+			go func() {
+				w.Run(context.Background(), in)
+				close(done)
+			}()
+			for _, update := range tt.updates {
+				in <- update
+			}
+			// let a ticker flush retry the kept updates
+			time.Sleep(20 * time.Millisecond)
+			close(in)
+			<-done
+
+			updates, err := db.SelectUpdates(helpers.UpdateFilter{ChainID: "1", OracleAddress: testOracle.Hex()})
+			if err != nil {
+				t.Fatalf("SelectUpdates: %v", err)
+			}
+			if len(updates) != len(tt.want) {
+				t.Fatalf("stored %d updates, want %v", len(updates), tt.want)
+			}
+			for i, update := range updates {
+				if update.TransactionHash != tt.want[i] {
+					t.Errorf("update %d is %s, want %s", i, update.TransactionHash, tt.want[i])
+				}
+			}
+			if len(stored) < len(tt.want) {
+				t.Errorf("stored callback ran %d times, want at least %d", len(stored), len(tt.want))
+			}
+		})
+	}
+}
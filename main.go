@@ -5,61 +5,192 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 
+	"github.com/diadata-org/oracle-monitoring/internal/alerts"
+	"github.com/diadata-org/oracle-monitoring/internal/api"
 	"github.com/diadata-org/oracle-monitoring/internal/config"
 	"github.com/diadata-org/oracle-monitoring/internal/database"
 	"github.com/diadata-org/oracle-monitoring/internal/helpers"
+	"github.com/diadata-org/oracle-monitoring/internal/metrics"
 	"github.com/diadata-org/oracle-monitoring/internal/scraper"
+	"github.com/diadata-org/oracle-monitoring/internal/writer"
 )
 
 var allOracles []string
 
+// contract versions by ABI name, loaded once at startup
+var contracts *config.ABIRegistry
+
+const (
+	// how often the alert checks are evaluated
+	alertInterval = 1 * time.Minute
+	// feeder runway below which an alert fires unless FEEDER_RUNWAY_DAYS is set
+	defaultRunwayDays = 7.0
+	// address of the Prometheus endpoint unless METRICS_ADDR is set
+	defaultMetricsAddr = ":9090"
+	// address of the REST API unless API_ADDR is set
+	defaultAPIAddr = ":8080"
+	// updates that may wait for the writer before the scraper blocks
+	channelBuffer = 100
+	// how often updates older than the retention of their chain are deleted
+	pruneInterval = 1 * time.Hour
+	// time the scrapers and writers get to stop unless SHUTDOWN_TIMEOUT is
+	// set, below the 10s grace period of docker stop
+	defaultShutdownTimeout = 8 * time.Second
+)
+
 func main() {
-	db := database.NewPostgresDB()
+	shutdownTimeout := defaultShutdownTimeout
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid SHUTDOWN_TIMEOUT: %v", err)
+		}
+		shutdownTimeout = timeout
+	}
+
+	// cancelled on the first SIGINT or SIGTERM, the scrapers stop and the
+	// writers store what they still hold
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.NewDatabase()
+	if err != nil {
+		log.Fatalf("failed to select the database: %v", err)
+	}
 
 	if err := db.Connect(); err != nil {
 		log.Fatalf("failed to connect to the database: %v", err)
 	}
 	defer db.Close()
 
-	rpcmap, err := db.GetRPCByChainID()
+	contracts, err = config.LoadABIRegistryFromEnv()
 	if err != nil {
-		log.Printf("failed to get rpcmap: %v", err)
-		return
+		log.Fatalf("failed to load the contract ABIs: %v", err)
 	}
+	log.Printf("loaded contract ABIs %s", strings.Join(contracts.Names(), ", "))
 
-	wsurlmap, err := db.GetWSByChainID()
+	chains, err := db.GetChainConfigs([]string{})
 	if err != nil {
-		log.Printf("failed to get rpcmap: %v", err)
+		log.Printf("failed to get chain configs: %v", err)
 		return
 	}
 
-	log.Println("starting historical")
-	for chainid := range rpcmap {
-		go runScraper(db, chainid, true, rpcmap, wsurlmap)
-		go runEventScraper(db, chainid, true, rpcmap, wsurlmap)
+	clients := make(map[string]scraper.ChainClient)
+	for _, chain := range chains {
+		client, err := scraper.NewChainClient(ctx, chain)
+		if err != nil {
+			log.Printf("failed to connect to chain %s: %v", chain.ChainID, err)
+			continue
+		}
+		clients[chain.ChainID] = client
+	}
 
+	engine, reconciler, err := newAlertEngine(db, chains, clients)
+	if err != nil {
+		log.Printf("failed to start alerting: %v", err)
+		return
+	}
+	var alerting sync.WaitGroup
+	alerting.Add(1)
+	go func() {
+		defer alerting.Done()
+		engine.Run(ctx, alertInterval)
+	}()
+	backfillers := newBackfillers()
+	go runRepairs(ctx, reconciler.Repairs(), backfillers)
+	go runRetention(ctx, db, chains)
+
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	if metricsAddr == "" {
+		metricsAddr = defaultMetricsAddr
 	}
+	metricsServer := metrics.NewServer(metricsAddr)
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("failed to serve metrics on %s: %v", metricsAddr, err)
+		}
+	}()
 
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+	server := api.NewServer(db)
+	apiAddr := os.Getenv("API_ADDR")
+	if apiAddr == "" {
+		apiAddr = defaultAPIAddr
+	}
+	apiServer := server.HTTPServer(apiAddr)
+	go func() {
+		if err := apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("failed to serve the API on %s: %v", apiAddr, err)
+		}
+	}()
+
+	log.Println("starting scrapers")
+	var running sync.WaitGroup
+	for _, chain := range chains {
+		running.Add(2)
+		go func(chain helpers.ChainConfig) {
+			defer running.Done()
+			runScraper(ctx, db, chain, engine, backfillers, server)
+		}(chain)
+		go func(chain helpers.ChainConfig) {
+			defer running.Done()
+			runEventScraper(ctx, db, chain, true, engine, server)
+		}(chain)
+	}
 
-	for range ticker.C {
-		for chainid := range rpcmap {
-			go runScraper(db, chainid, false, rpcmap, wsurlmap)
+	<-ctx.Done()
+	// a second signal kills the process right away
+	stop()
+	log.Printf("shutting down, waiting up to %s for the scrapers and writers", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		running.Wait()
+		alerting.Wait()
+		// the servers read the database until they are shut down
+		if err := apiServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shut down the API: %v", err)
 		}
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shut down the metrics server: %v", err)
+		}
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		log.Println("shutdown complete")
+	case <-shutdownCtx.Done():
+		log.Printf("shutdown timed out after %s, pending writes may be lost", shutdownTimeout)
+		db.Close()
+		os.Exit(1)
 	}
 }
 
-func runEventScraper(db database.Database, chainID string, isHistorical bool, rpcmap, wsurlmap map[string]string) {
+// runEventScraper follows the oracle events until ctx is cancelled, then
+// waits for the listener and stores what the writers still hold.
+func runEventScraper(ctx context.Context, db database.Database, chain helpers.ChainConfig, isHistorical bool, engine *alerts.Engine, server *api.Server) {
+	chainID := chain.ChainID
 	var wg sync.WaitGroup
-	metricsChan := make(chan helpers.OracleMetrics)
-	updateEventChan := make(chan helpers.OracleUpdateEvent)
+	metricsChan := make(chan helpers.OracleMetrics, channelBuffer)
+	updateEventChan := make(chan helpers.OracleUpdateEvent, channelBuffer)
+	accessChan := make(chan helpers.AccessEvent, channelBuffer)
+	metrics.ChannelDepth("metrics", chainID, func() int { return len(metricsChan) })
+	metrics.ChannelDepth("events_creation", chainID, func() int { return len(updateEventChan) })
+	metrics.ChannelDepth("access", chainID, func() int { return len(accessChan) })
 
 	oracles, err := getOracles(db, chainID)
 
@@ -71,17 +202,28 @@ func runEventScraper(db database.Database, chainID string, isHistorical bool, rp
 
 		fmt.Printf("\n Event based Scrapping started for chain %s,  total oracles %d isHistorical %t", chainID, len(oracles), isHistorical)
 
-		ctx := context.Background()
-		sc := scraper.NewScraper(ctx, metricsChan, updateEventChan, rpcmap, wsurlmap, big.NewInt(0), big.NewInt(0), oracles, &wg, chainID)
+		sc, err := scraper.NewScraper(ctx, metricsChan, nil, updateEventChan, accessChan, chain, big.NewInt(0), big.NewInt(0), oracles, &wg)
+		if err != nil {
+			log.Printf("failed to start the event scraper for chain %s: %v", chainID, err)
+			return
+		}
+		server.Register(chainID, "events", sc)
 
-		oraclesArray := []common.Address{}
+		oraclesArray := []helpers.Oracle{}
 
 		var latest time.Time
 
+		wg.Add(1)
 		go func() {
+			defer wg.Done()
 			ticker := time.NewTicker(1 * time.Minute)
 			defer ticker.Stop()
-			for range ticker.C {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
 
 				oracles, err := getOraclesByCreationTime(db, chainID, latest)
 				if err != nil {
@@ -89,7 +231,7 @@ func runEventScraper(db database.Database, chainID string, isHistorical bool, rp
 					continue
 				}
 
-				fmt.Println("oracles added", len(oracles))
+				log.Printf("oracles added %d for chain %s, ws reconnects %d", len(oracles), chainID, sc.Reconnects())
 
 				for _, oracle := range oracles {
 
@@ -97,7 +239,7 @@ func runEventScraper(db database.Database, chainID string, isHistorical bool, rp
 						latest = oracle.CreatedDate
 					}
 
-					oraclesArray = append(oraclesArray, oracle.ContractAddress)
+					oraclesArray = append(oraclesArray, oracle)
 
 				}
 
@@ -108,16 +250,42 @@ func runEventScraper(db database.Database, chainID string, isHistorical bool, rp
 
 		}()
 
-		go processMetrics(db, metricsChan)
-		go processCreation(db, updateEventChan)
+		var writers sync.WaitGroup
+		writers.Add(3)
+		go func() {
+			defer writers.Done()
+			processMetrics(ctx, db, metricsChan, engine)
+		}()
+		go func() {
+			defer writers.Done()
+			processCreation(db, updateEventChan, nil)
+		}()
+		go func() {
+			defer writers.Done()
+			processAccess(ctx, db, accessChan)
+		}()
+
+		<-ctx.Done()
+		wg.Wait()
+		close(metricsChan)
+		close(updateEventChan)
+		close(accessChan)
+		writers.Wait()
+		log.Printf("event scraper for chain %s stopped", chainID)
 	}
 
 }
 
-func runScraper(db database.Database, chainID string, isHistorical bool, rpcmap, wsurlmap map[string]string) {
+// runScraper scans the chain forward from its persisted checkpoint until ctx
+// is cancelled. The batches handed over by then are stored with their
+// checkpoints before it returns.
+func runScraper(ctx context.Context, db database.Database, chain helpers.ChainConfig, engine *alerts.Engine, backfillers *backfillers, server *api.Server) {
+	chainID := chain.ChainID
 	var wg sync.WaitGroup
-	metricsChan := make(chan helpers.OracleMetrics)
-	updateEventChan := make(chan helpers.OracleUpdateEvent)
+	batchChan := make(chan helpers.MetricsBatch, channelBuffer)
+	updateEventChan := make(chan helpers.OracleUpdateEvent, channelBuffer)
+	metrics.ChannelDepth("batches", chainID, func() int { return len(batchChan) })
+	metrics.ChannelDepth("scanner_creation", chainID, func() int { return len(updateEventChan) })
 
 	oracles, err := getOracles(db, chainID)
 
@@ -128,21 +296,189 @@ func runScraper(db database.Database, chainID string, isHistorical bool, rpcmap,
 	if len(oracles) > 0 {
 		minimum, maximum := calculateMinMaxBlocks(oracles)
 
-		fmt.Printf("\n Scrapping started for chain %s, up to minimum block %s, maximum block %s and total oracles %d isHistorical %t", chainID, minimum, maximum, len(oracles), isHistorical)
+		state, err := db.GetState(chainID)
+		if err != nil {
+			log.Printf("failed to get state for chain %s: %v", chainID, err)
+			return
+		}
 
-		ctx := context.Background()
-		sc := scraper.NewScraper(ctx, metricsChan, updateEventChan, rpcmap, wsurlmap, minimum, maximum, oracles, &wg, chainID)
-		if isHistorical {
-			go sc.UpdateHistorical()
+		log.Printf("scraping started for chain %s, checkpoint %d, minimum block %s, maximum block %s and total oracles %d", chainID, state.LastBlock, minimum, maximum, len(oracles))
 
-		} else {
-			sc.UpdateRecent()
+		sc, err := scraper.NewScraper(ctx, nil, batchChan, updateEventChan, nil, chain, minimum, maximum, oracles, &wg)
+		if err != nil {
+			log.Printf("failed to start the scraper for chain %s: %v", chainID, err)
+			return
+		}
+		server.Register(chainID, "forward", sc)
+		backfillers.register(chainID, sc)
+
+		// the channels stay open, a repair backfill may still send
+		stopWriters := make(chan struct{})
+		var writers sync.WaitGroup
+		writers.Add(2)
+		go func() {
+			defer writers.Done()
+			processBatches(ctx, db, batchChan, stopWriters, engine)
+		}()
+		go func() {
+			defer writers.Done()
+			processCreation(db, updateEventChan, stopWriters)
+		}()
 
+		sc.UpdateForward(state)
+
+		<-ctx.Done()
+		wg.Wait()
+		close(stopWriters)
+		writers.Wait()
+		log.Printf("forward scraper for chain %s stopped", chainID)
+	}
+
+}
+
+// newAlertEngine sets up the alert checks. Transitions are logged and, when
+// ALERT_WEBHOOK_URL is set, posted to that URL. Values are compared to the
+// JSON endpoint REFERENCE_PRICE_URL when it is set, {key} being replaced by
+// the asset key and the price read from REFERENCE_PRICE_FIELD. Feeder wallets
+// alert when their balance lasts less than FEEDER_RUNWAY_DAYS at the current
+// spend. The oracles are reconciled with their on-chain state every
+// RECONCILE_INTERVAL, RECONCILE_BACKFILL=true rescans the block ranges of the
+// mismatches.
+func newAlertEngine(db database.Database, chains map[string]helpers.ChainConfig, clients map[string]scraper.ChainClient) (*alerts.Engine, *alerts.StateChecker, error) {
+	notifiers := []alerts.Notifier{alerts.LogNotifier{}}
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, alerts.NewWebhookNotifier(url))
+	}
+
+	manager, err := alerts.NewManager(db, notifiers...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reference alerts.ReferencePriceProvider
+	if url := os.Getenv("REFERENCE_PRICE_URL"); url != "" {
+		field := os.Getenv("REFERENCE_PRICE_FIELD")
+		if field == "" {
+			field = "Price"
+		}
+		reference = alerts.NewHTTPReferenceProvider(url, field)
+	}
+
+	deviation, err := alerts.NewDeviationChecker(db, manager, reference)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	engine := alerts.NewEngine(manager)
+	engine.Register(alerts.NewStalenessChecker(db, manager))
+	engine.Register(alerts.NewCatalogueChecker(db, manager))
+	engine.Register(deviation)
+
+	revertThreshold := alerts.DefaultRevertThreshold
+	if value := os.Getenv("REVERT_ALERT_THRESHOLD"); value != "" {
+		revertThreshold, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid REVERT_ALERT_THRESHOLD: %v", err)
+		}
+	}
+	engine.Register(alerts.NewRevertChecker(db, manager, revertThreshold, alerts.DefaultRevertWindow))
+	engine.Register(alerts.NewAccessChecker(db, manager, alerts.DefaultAccessWindow))
+
+	feeders, err := alerts.NewFeederChecker(db, manager, alerts.DefaultFeederWindow)
+	if err != nil {
+		return nil, nil, err
+	}
+	engine.Register(feeders)
+
+	runwayDays := float64(defaultRunwayDays)
+	if value := os.Getenv("FEEDER_RUNWAY_DAYS"); value != "" {
+		runwayDays, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid FEEDER_RUNWAY_DAYS: %v", err)
 		}
-		go processMetrics(db, metricsChan)
-		go processCreation(db, updateEventChan)
 	}
+	minRunway := time.Duration(runwayDays * float64(24*time.Hour))
+	balances := make(map[string]alerts.BalanceReader, len(clients))
+	readers := make(map[string]alerts.ContractReader, len(clients))
+	for chainID, client := range clients {
+		balances[chainID] = client
+		readers[chainID] = client
+	}
+	engine.Register(alerts.NewBalanceTracker(db, manager, balances, minRunway))
+
+	reconcileInterval := alerts.DefaultReconcileInterval
+	if value := os.Getenv("RECONCILE_INTERVAL"); value != "" {
+		reconcileInterval, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid RECONCILE_INTERVAL: %v", err)
+		}
+	}
+	backfill := false
+	if value := os.Getenv("RECONCILE_BACKFILL"); value != "" {
+		backfill, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid RECONCILE_BACKFILL: %v", err)
+		}
+	}
+	confirmations := make(map[string]uint64, len(chains))
+	for chainID, chain := range chains {
+		confirmations[chainID] = chain.Confirmations
+	}
+	reconciler := alerts.NewStateChecker(db, manager, func(chainID string) ([]helpers.Oracle, error) {
+		return getOracles(db, chainID)
+	}, readers, confirmations, reconcileInterval, backfill)
+	engine.Register(reconciler)
+
+	return engine, reconciler, nil
+}
+
+// backfillers holds the forward scanner of every chain, which rescans the
+// block ranges of the state mismatches.
+type backfillers struct {
+	mu       sync.Mutex
+	scrapers map[string]scraper.Scraper
+}
+
+func newBackfillers() *backfillers {
+	return &backfillers{scrapers: make(map[string]scraper.Scraper)}
+}
+
+func (b *backfillers) register(chainID string, sc scraper.Scraper) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scrapers[chainID] = sc
+}
 
+func (b *backfillers) get(chainID string) (scraper.Scraper, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sc, ok := b.scrapers[chainID]
+	return sc, ok
+}
+
+// runRepairs backfills the repairs requested by the reconciler one at a time
+// until ctx is cancelled.
+func runRepairs(ctx context.Context, repairs <-chan alerts.Repair, backfillers *backfillers) {
+	for {
+		var repair alerts.Repair
+		select {
+		case <-ctx.Done():
+			return
+		case repair = <-repairs:
+		}
+
+		oracle := repair.Oracle.Hex()
+		sc, ok := backfillers.get(repair.ChainID)
+		if !ok {
+			log.Printf("no scraper to backfill oracle %s chainid %s", oracle, repair.ChainID)
+			continue
+		}
+		metrics.Backfills.WithLabelValues(repair.ChainID, strings.ToLower(oracle)).Inc()
+		log.Printf("backfilling blocks %d-%d of oracle %s chainid %s", repair.From, repair.To, oracle, repair.ChainID)
+		if _, err := sc.Backfill(repair.Oracle, repair.From, repair.To); err != nil {
+			log.Printf("failed to backfill oracle %s chainid %s: %v", oracle, repair.ChainID, err)
+		}
+	}
 }
 
 func getOraclesByCreationTime(db database.Database, chainID string, createdtime time.Time) (oracles []helpers.Oracle, err error) {
@@ -153,18 +489,12 @@ func getOraclesByCreationTime(db database.Database, chainID string, createdtime
 	}
 
 	for _, oracleconfig := range oracleConfigs {
-		var oracle helpers.Oracle
-		var oracleABI *abi.ABI
-		oracleABI, err := config.LoadContractAbi(fmt.Sprintf("internal/abi/%s.json", "oracle-v2"))
+		oracle, err := newOracle(oracleconfig)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load the oracle contract ABI: %v", err)
+			log.Printf("skipping oracle: %v", err)
+			continue
 		}
-		oracle.NodeUrl = oracleconfig.NodeUrl
-		oracle.ContractABI = oracleABI
 		oracle.CreatedDate = oracleconfig.CreatedDate
-		oracle.ChainID = oracleconfig.ChainId
-		oracle.ContractAddress = common.HexToAddress(oracleconfig.ContractAddress)
-		oracle.LatestScrapedBlock = new(big.Int).SetUint64(oracleconfig.LatestScrapedBlock)
 		oracles = append(oracles, oracle)
 	}
 	return oracles, nil
@@ -177,23 +507,34 @@ func getOracles(db database.Database, chainID string) (oracles []helpers.Oracle,
 	}
 
 	for _, oracleconfig := range oracleConfigs {
-		var oracle helpers.Oracle
-		var oracleABI *abi.ABI
-		oracleABI, err := config.LoadContractAbi(fmt.Sprintf("internal/abi/%s.json", "oracle-v2"))
+		oracle, err := newOracle(oracleconfig)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load the oracle contract ABI: %v", err)
+			log.Printf("skipping oracle: %v", err)
+			continue
 		}
-		oracle.NodeUrl = oracleconfig.NodeUrl
-		oracle.ContractABI = oracleABI
-		oracle.ChainID = oracleconfig.ChainId
-		oracle.ContractAddress = common.HexToAddress(oracleconfig.ContractAddress)
-		oracle.LatestScrapedBlock = new(big.Int).SetUint64(oracleconfig.LatestScrapedBlock)
 		oracles = append(oracles, oracle)
 	}
 
 	return oracles, nil
 }
 
+// newOracle resolves the contract version of a configured oracle.
+func newOracle(oracleconfig helpers.Target) (helpers.Oracle, error) {
+	var oracle helpers.Oracle
+	contract, err := contracts.Contract(oracleconfig.ContractABI)
+	if err != nil {
+		return oracle, fmt.Errorf("failed to resolve the ABI of oracle %s: %v", oracleconfig.ContractAddress, err)
+	}
+	oracle.NodeUrl = oracleconfig.NodeUrl
+	oracle.Contract = contract
+	oracle.ChainID = oracleconfig.ChainId
+	oracle.ContractAddress = common.HexToAddress(oracleconfig.ContractAddress)
+	oracle.LatestScrapedBlock = new(big.Int).SetUint64(oracleconfig.LatestScrapedBlock)
+	oracle.CreationBlock = oracleconfig.CreationBlock
+	oracle.Decimals = oracleconfig.Decimals
+	return oracle, nil
+}
+
 func calculateMinMaxBlocks(oracles []helpers.Oracle) (*big.Int, *big.Int) {
 	minimum := new(big.Int).Set(oracles[0].LatestScrapedBlock)
 	maximum := new(big.Int).Set(oracles[0].LatestScrapedBlock)
@@ -211,19 +552,204 @@ func calculateMinMaxBlocks(oracles []helpers.Oracle) (*big.Int, *big.Int) {
 	return minimum, maximum
 }
 
-func processMetrics(db database.Database, metricsChan chan helpers.OracleMetrics) {
-	for metrics := range metricsChan {
-		if err := db.InsertOracleMetrics(&metrics); err != nil {
-			log.Println("Error inserting oracle metrics:", err)
+// processMetrics stores the updates of the event listener in batches.
+func processMetrics(ctx context.Context, db database.Database, metricsChan chan helpers.OracleMetrics, engine *alerts.Engine) {
+	batchSize := writer.DefaultBatchSize
+	if value := os.Getenv("WRITER_BATCH_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("invalid WRITER_BATCH_SIZE: %v", err)
+		}
+		batchSize = size
+	}
+
+	flushInterval := writer.DefaultFlushInterval
+	if value := os.Getenv("WRITER_FLUSH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid WRITER_FLUSH_INTERVAL: %v", err)
+		}
+		flushInterval = interval
+	}
+
+	w := writer.New(db, batchSize, flushInterval, func(update helpers.OracleMetrics) {
+		observeUpdate(update)
+		engine.Observe(update)
+	})
+	w.Run(ctx, metricsChan)
+}
+
+// processBatches stores each batch and then its checkpoint, reporting the
+// outcome back to the scraper that sent it. Once stop is closed it stores the
+// batches already queued and returns.
+func processBatches(ctx context.Context, db database.Database, batchChan chan helpers.MetricsBatch, stop chan struct{}, engine *alerts.Engine) {
+	for {
+		var batch helpers.MetricsBatch
+		select {
+		case batch = <-batchChan:
+		case <-stop:
+			for {
+				select {
+				case batch := <-batchChan:
+					storeBatch(ctx, db, batch, engine)
+				default:
+					return
+				}
+			}
 		}
+		storeBatch(ctx, db, batch, engine)
+	}
+}
+
+func storeBatch(ctx context.Context, db database.Database, batch helpers.MetricsBatch, engine *alerts.Engine) {
+	err := writeBatch(ctx, db, batch)
+	batch.Done <- err
+	if err != nil {
+		metrics.DBErrors.WithLabelValues("batch").Inc()
+		return
+	}
+	if batch.Repair {
+		// a repair stores updates that were missed, not new ones
+		return
+	}
+	for _, update := range batch.Metrics {
+		observeUpdate(update)
+		engine.Observe(update)
+	}
+	for _, failed := range batch.Failed {
+		metrics.ObserveFailedUpdate(failed.ChainID, failed.TransactionTo.Hex(), failed.TransactionCost)
 	}
 }
 
-func processCreation(db database.Database, updateEvent chan helpers.OracleUpdateEvent) {
-	for ue := range updateEvent {
-		log.Println("updating oracle creation date", ue)
-		if err := db.UpdateOracleCreation(ue.Address, ue.Block, ue.BlockTimestamp, ue.ChainID); err != nil {
-			log.Println("Error inserting oracle creation:", err)
+func observeUpdate(update helpers.OracleMetrics) {
+	metrics.ObserveUpdate(update.ChainID, update.TransactionTo.Hex(), update.AssetKey, update.BlockTimestamp, update.TransactionCost)
+}
+
+func writeBatch(ctx context.Context, db database.Database, batch helpers.MetricsBatch) error {
+	if batch.Rollback {
+		err := writer.Retry(ctx, func() error { return db.RollbackOracleMetrics(batch.State.ChainID, batch.State.LastBlock) })
+		if err != nil {
+			return err
+		}
+	}
+	err := writer.Retry(ctx, func() error { return db.InsertOracleMetricsBatch(batch.Metrics) })
+	if err != nil {
+		return err
+	}
+	err = writer.Retry(ctx, func() error { return db.InsertFailedUpdates(batch.Failed) })
+	if err != nil {
+		return err
+	}
+	err = writer.Retry(ctx, func() error { return db.InsertAccessEvents(batch.Access) })
+	if err != nil {
+		return err
+	}
+	err = writer.Retry(ctx, func() error { return db.InsertUncoveredRanges(batch.Uncovered) })
+	if err != nil {
+		return err
+	}
+	if batch.Repair {
+		return nil
+	}
+	return writer.Retry(ctx, func() error { return db.SetState(batch.State) })
+}
+
+// processCreation stores oracle creation dates until updateEvent is closed or,
+// for senders that never close it, stop is closed and the queued ones are
+// stored.
+func processCreation(db database.Database, updateEvent chan helpers.OracleUpdateEvent, stop chan struct{}) {
+	for {
+		select {
+		case ue, ok := <-updateEvent:
+			if !ok {
+				return
+			}
+			storeCreation(db, ue)
+		case <-stop:
+			for {
+				select {
+				case ue := <-updateEvent:
+					storeCreation(db, ue)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func storeCreation(db database.Database, ue helpers.OracleUpdateEvent) {
+	log.Println("updating oracle creation date", ue)
+	if err := db.UpdateOracleCreation(ue.Address, ue.Block, ue.BlockTimestamp, ue.ChainID); err != nil {
+		log.Println("Error inserting oracle creation:", err)
+	}
+}
+
+// processAccess stores the access control events of the event listener and
+// deletes the ones orphaned by a reorg.
+func processAccess(ctx context.Context, db database.Database, accessChan chan helpers.AccessEvent) {
+	for event := range accessChan {
+		var err error
+		if event.Removed {
+			err = db.DeleteAccessEvent(event.ChainID, event.TransactionHash, event.LogIndex)
+		} else {
+			log.Printf("access control %s on oracle %s chainid %s in transaction %s", event.Kind, event.OracleAddress, event.ChainID, event.TransactionHash)
+			err = writer.Retry(ctx, func() error { return db.InsertAccessEvents([]helpers.AccessEvent{event}) })
+		}
+		if err != nil {
+			metrics.DBErrors.WithLabelValues("access").Inc()
+			log.Printf("failed to store access event %s: %v", event.TransactionHash, err)
+		}
+	}
+}
+
+// runRetention deletes the updates older than the retention of their chain,
+// taken from retentionconfig, every pruneInterval until ctx is cancelled. A
+// TimescaleDB hypertable drops old chunks with its retention policy instead.
+func runRetention(ctx context.Context, db database.Database, chains map[string]helpers.ChainConfig) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		pruneExpired(db, chains)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func pruneExpired(db database.Database, chains map[string]helpers.ChainConfig) {
+	policies, err := db.SelectRetentionPolicies()
+	if err != nil {
+		log.Printf("failed to get retention policies: %v", err)
+		return
+	}
+
+	retention := make(map[string]time.Duration)
+	for _, policy := range policies {
+		retention[policy.ChainID] = policy.Retention
+	}
+
+	for chainID := range chains {
+		keep, ok := retention[chainID]
+		if !ok {
+			keep = retention[""]
+		}
+		if keep <= 0 {
+			continue
+		}
+
+		pruned, err := db.PruneOracleMetrics(chainID, time.Now().Add(-keep))
+		if err != nil {
+			log.Printf("failed to prune updates of chain %s: %v", chainID, err)
+			metrics.DBErrors.WithLabelValues("prune").Inc()
+			continue
+		}
+		if pruned > 0 {
+			log.Printf("pruned %d updates of chain %s older than %s", pruned, chainID, keep)
 		}
 	}
 }